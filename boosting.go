@@ -0,0 +1,206 @@
+package confeito
+
+import (
+	"fmt"
+	"math"
+)
+
+// Loss is the interface for a twice-differentiable loss function of gradient boosting.
+type Loss interface {
+	// InitialScore returns the constant score minimizing the loss over targets y.
+	InitialScore(y []float32) float32
+	// Gradient returns the first and second derivatives of the loss with respect to score f for target y.
+	Gradient(y, f float32) (grad, hess float64)
+}
+
+// SquaredLoss is the squared error loss (y - f)^2/2 for regression.
+// This implements interface Loss.
+type SquaredLoss struct{}
+
+// InitialScore is for interface Loss.
+// This returns the mean of y.
+func (SquaredLoss) InitialScore(y []float32) float32 {
+	sum := 0.0
+	for _, yi := range y {
+		sum += float64(yi)
+	}
+	return float32(sum / float64(len(y)))
+}
+
+// Gradient is for interface Loss.
+func (SquaredLoss) Gradient(y, f float32) (grad, hess float64) {
+	return float64(f - y), 1.0
+}
+
+// LogisticLoss is the logistic loss for binary classification with targets 0 or 1.
+// The score is the log-odds of the positive class.
+// This implements interface Loss.
+type LogisticLoss struct{}
+
+// InitialScore is for interface Loss.
+// This returns the log-odds of the mean of y.
+func (LogisticLoss) InitialScore(y []float32) float32 {
+	p := float64(SquaredLoss{}.InitialScore(y))
+	p = math.Min(math.Max(p, 1e-15), 1.0-1e-15)
+	return float32(math.Log(p / (1.0 - p)))
+}
+
+// Gradient is for interface Loss.
+func (LogisticLoss) Gradient(y, f float32) (grad, hess float64) {
+	p := 1.0 / (1.0 + math.Exp(-float64(f)))
+	return p - float64(y), math.Max(p*(1.0-p), 1e-16)
+}
+
+// gradientCriterion is the criterion for regression trees fitting the Newton step of a loss.
+// The statistics vector is (the sum of the gradients, the sum of the hessians).
+type gradientCriterion struct {
+	grad, hess []float64
+	lambda     float64
+	shrinkage  float32
+}
+
+func (c *gradientCriterion) statsLen() int {
+	return 2
+}
+
+func (c *gradientCriterion) addSample(stats []float64, i int) {
+	stats[0] += c.grad[i]
+	stats[1] += c.hess[i]
+}
+
+// The loss is approximately decreased by G^2/(H + lambda)/2 with the Newton step -G/(H + lambda).
+func (c *gradientCriterion) score(stats []float64) float64 {
+	if stats[1]+c.lambda <= 0.0 {
+		return 0.0
+	}
+	return stats[0] * stats[0] / (stats[1] + c.lambda)
+}
+
+func (c *gradientCriterion) value(stats []float64) interface{} {
+	if stats[1]+c.lambda <= 0.0 {
+		return float32(0.0)
+	}
+	return c.shrinkage * float32(-stats[0]/(stats[1]+c.lambda))
+}
+
+// GradientBoostingTrainer is a trainer of gradient boosting trees.
+type GradientBoostingTrainer struct {
+	// Loss is the loss function to be minimized.
+	Loss Loss
+	// NumTrees is the number of boosted trees.
+	NumTrees int
+	// Shrinkage is the learning rate multiplied to the values of each tree.
+	Shrinkage float32
+	// Lambda is the L2 regularization parameter of the values of terminal leaves.
+	Lambda float64
+	// MaxDepth is the maximum depth of each tree.
+	// If it is 0, then the depth is unlimited.
+	MaxDepth int
+	// MinSamplesLeaf is the minimum number of samples in each terminal leaf.
+	MinSamplesLeaf int
+	// MaxLeaves is the maximum number of terminal leaves in each tree.
+	// If it is 0, then the number is unlimited.
+	MaxLeaves int
+	// MaxBins is the maximum number of bins of each feature for histogram-based split finding.
+	// If it is 0, then the exact split finding is used.
+	MaxBins int
+}
+
+// NewGradientBoostingTrainer returns a new GradientBoostingTrainer with loss.
+// The default parameters are 100 trees, shrinkage 0.1, lambda 1.0, and at most 8 leaves and 1 sample in each terminal leaf.
+func NewGradientBoostingTrainer(loss Loss) *GradientBoostingTrainer {
+	return &GradientBoostingTrainer{
+		Loss:           loss,
+		NumTrees:       100,
+		Shrinkage:      0.1,
+		Lambda:         1.0,
+		MaxDepth:       0,
+		MinSamplesLeaf: 1,
+		MaxLeaves:      8,
+		MaxBins:        0,
+	}
+}
+
+// validate returns an error if trainer has an illegal parameter.
+func (trainer *GradientBoostingTrainer) validate() error {
+	if trainer.Loss == nil {
+		return fmt.Errorf("Loss must not be nil")
+	}
+	if trainer.NumTrees < 0 {
+		return fmt.Errorf("NumTrees must not be negative")
+	}
+	if !(trainer.Shrinkage > 0.0) {
+		return fmt.Errorf("Shrinkage must be positive")
+	}
+	if trainer.Lambda < 0.0 {
+		return fmt.Errorf("Lambda must not be negative")
+	}
+	return (&CARTTrainer{
+		MaxDepth:       trainer.MaxDepth,
+		MinSamplesLeaf: trainer.MinSamplesLeaf,
+		MaxLeaves:      trainer.MaxLeaves,
+		MaxBins:        trainer.MaxBins,
+	}).validate()
+}
+
+// Train returns the trees trained on dataset X with targets y.
+// The first tree is a terminal leaf having the initial score, and the following NumTrees trees are boosted.
+// Each terminal leaf has float32 value, and the sum of the values predicted by the trees is the score.
+// Thus, the trees can be enqueued to Forest directly, and the score is given by Forest.PredictSum.
+//
+// This function returns an error if trainer has an illegal parameter, the lengths of X and y are different, or at getting feature values of X.
+func (trainer *GradientBoostingTrainer) Train(X []FeatureVector, y []float32) ([]*Leaf, error) {
+	if err := trainer.validate(); err != nil {
+		return nil, err
+	}
+	if len(X) != len(y) {
+		return nil, fmt.Errorf("the number of samples and targets must be equal")
+	}
+	set, err := newTrainingSet(X)
+	if err != nil {
+		return nil, err
+	}
+	bins, err := newHistogramBins(set, trainer.MaxBins)
+	if err != nil {
+		return nil, err
+	}
+	initialScore := trainer.Loss.InitialScore(y)
+	initialTree, _ := NewTerminalLeaf(initialScore)
+	trees := []*Leaf{initialTree}
+	scores := make([]float32, len(X))
+	for i := range scores {
+		scores[i] = initialScore
+	}
+	criterion := &gradientCriterion{
+		grad:      make([]float64, len(X)),
+		hess:      make([]float64, len(X)),
+		lambda:    trainer.Lambda,
+		shrinkage: trainer.Shrinkage,
+	}
+	grower := &treeGrower{
+		set:            set,
+		criterion:      criterion,
+		maxDepth:       trainer.MaxDepth,
+		minSamplesLeaf: trainer.MinSamplesLeaf,
+		maxLeaves:      trainer.MaxLeaves,
+		bins:           bins,
+	}
+	samples := make([]int, len(X))
+	for i := range samples {
+		samples[i] = i
+	}
+	for m := 0; m < trainer.NumTrees; m++ {
+		for i := range scores {
+			criterion.grad[i], criterion.hess[i] = trainer.Loss.Gradient(y[i], scores[i])
+		}
+		tree, err := grower.grow(samples)
+		if err != nil {
+			return nil, err
+		}
+		for i := range scores {
+			scores[i] += set.predict(tree, i).(float32)
+		}
+		trees = append(trees, tree)
+	}
+	return trees, nil
+}
@@ -0,0 +1,93 @@
+package confeito
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestSquaredLoss(t *testing.T) {
+	goassert.New(t, float32(2.0)).Equal(SquaredLoss{}.InitialScore([]float32{1.0, 2.0, 3.0}))
+	goassert.New(t, 1.5, 1.0).Equal(SquaredLoss{}.Gradient(1.0, 2.5))
+}
+
+func TestLogisticLoss(t *testing.T) {
+	goassert.New(t, float32(0.0)).Equal(LogisticLoss{}.InitialScore([]float32{0.0, 1.0}))
+	goassert.New(t, float32(math.Log(3.0))).Equal(LogisticLoss{}.InitialScore([]float32{0.0, 1.0, 1.0, 1.0}))
+	goassert.New(t, -0.5, 0.25).Equal(LogisticLoss{}.Gradient(1.0, 0.0))
+	goassert.New(t, 0.5, 0.25).Equal(LogisticLoss{}.Gradient(0.0, 0.0))
+}
+
+func TestGradientBoostingTrainerSquaredLoss(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	X, y := make([]FeatureVector, 500), make([]float32, 500)
+	for i := range X {
+		x := DenseFeatureVector{rng.Float32(), rng.Float32(), rng.Float32()}
+		X[i], y[i] = x, 2.0*x[0]-x[1]
+	}
+	trainer := NewGradientBoostingTrainer(SquaredLoss{})
+	trees := goassert.New(t).SucceedNew(trainer.Train(X, y)).([]*Leaf)
+	goassert.New(t, 101).Equal(len(trees))
+	goassert.New(t, SquaredLoss{}.InitialScore(y)).EqualWithoutError(trees[0].Value())
+	forest := goassert.New(t).SucceedNew(NewForestFromTrees(trees...)).(*Forest)
+	mse := 0.0
+	for i, x := range X {
+		score := goassert.New(t).SucceedNew(forest.PredictSum(x)).(float32)
+		sum := float32(0.0)
+		for _, tree := range trees {
+			value, _ := tree.Predict(x)
+			sum += value.(float32)
+		}
+		goassert.New(t, sum).Equal(score)
+		mse += math.Pow(float64(score-y[i]), 2.0) / float64(len(X))
+	}
+	if mse > 0.01 {
+		t.Errorf("too large training MSE: %g", mse)
+	}
+}
+
+func TestGradientBoostingTrainerLogisticLoss(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	X, y := make([]FeatureVector, 500), make([]float32, 500)
+	for i := range X {
+		x := SparseFeatureVector{KeyValue{1, rng.Float32()}, KeyValue{3, rng.Float32()}}
+		X[i] = x
+		if x[0].Value+x[1].Value > 1.0 {
+			y[i] = 1.0
+		}
+	}
+	trainer := NewGradientBoostingTrainer(LogisticLoss{})
+	trainer.MaxLeaves = ForestMaxLeaves
+	trees := goassert.New(t).SucceedNew(trainer.Train(X, y)).([]*Leaf)
+	forest := goassert.New(t).SucceedNew(NewForestFromTrees(trees...)).(*Forest)
+	nerrors := 0
+	for i, x := range X {
+		score := goassert.New(t).SucceedNew(forest.PredictSum(x)).(float32)
+		if (score > 0.0) != (y[i] == 1.0) {
+			nerrors++
+		}
+	}
+	if nerrors > 25 {
+		t.Errorf("too many training errors: %d", nerrors)
+	}
+}
+
+func TestGradientBoostingTrainerError(t *testing.T) {
+	X, y := []FeatureVector{DenseFeatureVector{0.0}}, []float32{0.0}
+	goassert.New(t, "Loss must not be nil").ExpectError(NewGradientBoostingTrainer(nil).Train(X, y))
+	trainer := NewGradientBoostingTrainer(SquaredLoss{})
+	trainer.NumTrees = -1
+	goassert.New(t, "NumTrees must not be negative").ExpectError(trainer.Train(X, y))
+	trainer = NewGradientBoostingTrainer(SquaredLoss{})
+	trainer.Shrinkage = 0.0
+	goassert.New(t, "Shrinkage must be positive").ExpectError(trainer.Train(X, y))
+	trainer = NewGradientBoostingTrainer(SquaredLoss{})
+	trainer.Lambda = -1.0
+	goassert.New(t, "Lambda must not be negative").ExpectError(trainer.Train(X, y))
+	trainer = NewGradientBoostingTrainer(SquaredLoss{})
+	trainer.MinSamplesLeaf = 0
+	goassert.New(t, "MinSamplesLeaf must be positive").ExpectError(trainer.Train(X, y))
+	goassert.New(t, "the number of samples and targets must be equal").ExpectError(NewGradientBoostingTrainer(SquaredLoss{}).Train(X, []float32{}))
+}
@@ -0,0 +1,238 @@
+package confeito
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Criterion is the type of split criteria for growing trees.
+type Criterion int
+
+const (
+	// CriterionMSE is the mean squared error for regression trees.
+	CriterionMSE Criterion = iota
+	// CriterionGini is the Gini impurity for classification trees.
+	CriterionGini
+	// CriterionEntropy is the entropy for classification trees.
+	CriterionEntropy
+)
+
+// String returns the name of c.
+func (c Criterion) String() string {
+	switch c {
+	case CriterionMSE:
+		return "mse"
+	case CriterionGini:
+		return "gini"
+	case CriterionEntropy:
+		return "entropy"
+	}
+	return fmt.Sprintf("Criterion(%d)", int(c))
+}
+
+// mseCriterion is the criterion for regression trees.
+// The statistics vector is (the number of samples, the sum of the targets).
+type mseCriterion struct {
+	y []float32
+}
+
+func (c *mseCriterion) statsLen() int {
+	return 2
+}
+
+func (c *mseCriterion) addSample(stats []float64, i int) {
+	stats[0]++
+	stats[1] += float64(c.y[i])
+}
+
+// The sum of the squared errors is sum(y^2) - sum(y)^2/n, and sum(y^2) does not change by splits.
+func (c *mseCriterion) score(stats []float64) float64 {
+	if stats[0] == 0.0 {
+		return 0.0
+	}
+	return stats[1] * stats[1] / stats[0]
+}
+
+func (c *mseCriterion) value(stats []float64) interface{} {
+	if stats[0] == 0.0 {
+		return float32(0.0)
+	}
+	return float32(stats[1] / stats[0])
+}
+
+// classCriterion is the criterion for classification trees.
+// The statistics vector is the number of samples of each class, where the classes are the distinct labels in ascending order.
+type classCriterion struct {
+	labels  []int
+	classes []float32
+	entropy bool
+}
+
+// newClassCriterion returns a new classCriterion with labels y.
+// The labels are remapped to the indices of the distinct labels, so the statistics vectors do not depend on the magnitude of the labels.
+//
+// This function returns an error if y has a label which is not a non-negative integer.
+func newClassCriterion(y []float32, entropy bool) (*classCriterion, error) {
+	indices := map[float32]int{}
+	for i, yi := range y {
+		if !(yi >= 0.0) || float32(int(yi)) != yi {
+			return nil, fmt.Errorf("class label must be a non-negative integer: y[%d]=%g", i, yi)
+		}
+		indices[yi] = 0
+	}
+	classes := make([]float32, 0, len(indices))
+	for class := range indices {
+		classes = append(classes, class)
+	}
+	sort.Slice(classes, func(i, j int) bool { return classes[i] < classes[j] })
+	for k, class := range classes {
+		indices[class] = k
+	}
+	labels := make([]int, len(y))
+	for i, yi := range y {
+		labels[i] = indices[yi]
+	}
+	return &classCriterion{
+		labels:  labels,
+		classes: classes,
+		entropy: entropy,
+	}, nil
+}
+
+func (c *classCriterion) statsLen() int {
+	return len(c.classes)
+}
+
+func (c *classCriterion) addSample(stats []float64, i int) {
+	stats[c.labels[i]]++
+}
+
+// The Gini impurity multiplied by n is n - sum(c^2)/n, and the entropy multiplied by n is n*log(n) - sum(c*log(c)).
+func (c *classCriterion) score(stats []float64) float64 {
+	n, s := 0.0, 0.0
+	for _, count := range stats {
+		n += count
+		if count > 0.0 {
+			if c.entropy {
+				s += count * math.Log(count)
+			} else {
+				s += count * count
+			}
+		}
+	}
+	if n == 0.0 {
+		return 0.0
+	}
+	if c.entropy {
+		return s - n*math.Log(n)
+	}
+	return s / n
+}
+
+func (c *classCriterion) value(stats []float64) interface{} {
+	label := 0
+	for k, count := range stats {
+		if count > stats[label] {
+			label = k
+		}
+	}
+	return c.classes[label]
+}
+
+// CARTTrainer is a trainer of a CART (Classification And Regression Tree).
+type CARTTrainer struct {
+	// Criterion is the split criterion.
+	Criterion Criterion
+	// MaxDepth is the maximum depth of the tree.
+	// If it is 0, then the depth is unlimited.
+	MaxDepth int
+	// MinSamplesLeaf is the minimum number of samples in each terminal leaf.
+	MinSamplesLeaf int
+	// MaxLeaves is the maximum number of terminal leaves in the tree.
+	// If it is 0, then the number is unlimited.
+	// Set ForestMaxLeaves in order to enqueue the tree to Forest.
+	MaxLeaves int
+	// MaxBins is the maximum number of bins of each feature for histogram-based split finding, which is much faster on large datasets.
+	// The thresholds are restricted to the quantiles of each feature.
+	// If it is 0, then the exact split finding on the sorted feature values is used.
+	MaxBins int
+}
+
+// NewCARTTrainer returns a new CARTTrainer with criterion.
+// The depth and the number of leaves are unlimited, and the minimum number of samples in each leaf is 1.
+func NewCARTTrainer(criterion Criterion) *CARTTrainer {
+	return &CARTTrainer{
+		Criterion:      criterion,
+		MaxDepth:       0,
+		MinSamplesLeaf: 1,
+		MaxLeaves:      0,
+		MaxBins:        0,
+	}
+}
+
+// newCriterion returns a new splitCriterion for targets y.
+func (trainer *CARTTrainer) newCriterion(y []float32) (splitCriterion, error) {
+	switch trainer.Criterion {
+	case CriterionMSE:
+		return &mseCriterion{y: y}, nil
+	case CriterionGini:
+		return newClassCriterion(y, false)
+	case CriterionEntropy:
+		return newClassCriterion(y, true)
+	}
+	return nil, fmt.Errorf("unknown criterion: %s", trainer.Criterion)
+}
+
+// validate returns an error if trainer has an illegal parameter.
+func (trainer *CARTTrainer) validate() error {
+	if trainer.MaxDepth < 0 {
+		return fmt.Errorf("MaxDepth must not be negative")
+	}
+	if trainer.MinSamplesLeaf < 1 {
+		return fmt.Errorf("MinSamplesLeaf must be positive")
+	}
+	if trainer.MaxLeaves < 0 {
+		return fmt.Errorf("MaxLeaves must not be negative")
+	}
+	return validateMaxBins(trainer.MaxBins)
+}
+
+// Train returns a new tree trained on dataset X with targets y.
+// For CriterionMSE, each terminal leaf has the mean of the targets as float32.
+// For CriterionGini and CriterionEntropy, y should be class labels 0, 1, ..., and each terminal leaf has the majority label as float32.
+//
+// This function returns an error if trainer has an illegal parameter, the lengths of X and y are different, y has an illegal label, or at getting feature values of X.
+func (trainer *CARTTrainer) Train(X []FeatureVector, y []float32) (*Leaf, error) {
+	if err := trainer.validate(); err != nil {
+		return nil, err
+	}
+	if len(X) != len(y) {
+		return nil, fmt.Errorf("the number of samples and targets must be equal")
+	}
+	set, err := newTrainingSet(X)
+	if err != nil {
+		return nil, err
+	}
+	criterion, err := trainer.newCriterion(y)
+	if err != nil {
+		return nil, err
+	}
+	bins, err := newHistogramBins(set, trainer.MaxBins)
+	if err != nil {
+		return nil, err
+	}
+	grower := &treeGrower{
+		set:            set,
+		criterion:      criterion,
+		maxDepth:       trainer.MaxDepth,
+		minSamplesLeaf: trainer.MinSamplesLeaf,
+		maxLeaves:      trainer.MaxLeaves,
+		bins:           bins,
+	}
+	samples := make([]int, len(X))
+	for i := range samples {
+		samples[i] = i
+	}
+	return grower.grow(samples)
+}
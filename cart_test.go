@@ -0,0 +1,150 @@
+package confeito
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestCriterion(t *testing.T) {
+	goassert.New(t, "mse").Equal(CriterionMSE.String())
+	goassert.New(t, "gini").Equal(CriterionGini.String())
+	goassert.New(t, "entropy").Equal(CriterionEntropy.String())
+	goassert.New(t, "Criterion(3)").Equal(Criterion(3).String())
+}
+
+func TestCARTTrainerRegression(t *testing.T) {
+	X := []FeatureVector{
+		DenseFeatureVector{0.0, 5.0},
+		DenseFeatureVector{1.0, 5.0},
+		DenseFeatureVector{2.0, 5.0},
+		DenseFeatureVector{3.0, 6.0},
+		DenseFeatureVector{4.0, 6.0},
+		DenseFeatureVector{5.0, 6.0},
+	}
+	y := []float32{1.0, 1.0, 1.0, 3.0, 3.0, 5.0}
+	trainer := NewCARTTrainer(CriterionMSE)
+	tree := goassert.New(t).SucceedNew(trainer.Train(X, y)).(*Leaf)
+	goassert.New(t, "(feature[0] <= 2.5 ? 1 : (feature[0] <= 4.5 ? 3 : 5))").Equal(fmt.Sprintf("%s", tree))
+	for i, x := range X {
+		goassert.New(t, y[i]).EqualWithoutError(tree.Predict(x))
+	}
+	trainer.MaxDepth = 1
+	tree = goassert.New(t).SucceedNew(trainer.Train(X, y)).(*Leaf)
+	goassert.New(t, "(feature[0] <= 2.5 ? 1 : 3.6666667)").Equal(fmt.Sprintf("%s", tree))
+	trainer.MaxDepth, trainer.MinSamplesLeaf = 0, 2
+	tree = goassert.New(t).SucceedNew(trainer.Train(X, y)).(*Leaf)
+	goassert.New(t, "(feature[0] <= 2.5 ? 1 : 3.6666667)").Equal(fmt.Sprintf("%s", tree))
+	trainer.MinSamplesLeaf, trainer.MaxLeaves = 1, 1
+	tree = goassert.New(t).SucceedNew(trainer.Train(X, y)).(*Leaf)
+	goassert.New(t, "2.3333333").Equal(fmt.Sprintf("%s", tree))
+}
+
+func TestCARTTrainerClassification(t *testing.T) {
+	X := []FeatureVector{
+		SparseFeatureVector{KeyValue{2, 1.0}},
+		SparseFeatureVector{KeyValue{2, 2.0}},
+		SparseFeatureVector{KeyValue{0, 1.0}, KeyValue{2, 3.0}},
+		SparseFeatureVector{KeyValue{0, 1.0}, KeyValue{2, 4.0}},
+		SparseFeatureVector{KeyValue{0, 2.0}, KeyValue{2, 5.0}},
+	}
+	y := []float32{0.0, 0.0, 1.0, 1.0, 2.0}
+	for _, criterion := range []Criterion{CriterionGini, CriterionEntropy} {
+		tree := goassert.New(t).SucceedNew(NewCARTTrainer(criterion).Train(X, y)).(*Leaf)
+		for i, x := range X {
+			goassert.New(t, y[i]).EqualWithoutError(tree.Predict(x))
+		}
+	}
+	goassert.New(t, "class label must be a non-negative integer: y[1]=0.5").ExpectError(NewCARTTrainer(CriterionGini).Train(X, []float32{0.0, 0.5, 1.0, 1.0, 2.0}))
+	goassert.New(t, "class label must be a non-negative integer: y[0]=-1").ExpectError(NewCARTTrainer(CriterionEntropy).Train(X, []float32{-1.0, 0.0, 1.0, 1.0, 2.0}))
+}
+
+func TestCARTTrainerSparseIDs(t *testing.T) {
+	// The huge feature IDs and class labels are remapped, so they do not allocate the columns and the statistics up to them.
+	X := []FeatureVector{
+		SparseFeatureVector{KeyValue{4000000000, -1.0}},
+		SparseFeatureVector{KeyValue{4000000000, -2.0}},
+		SparseFeatureVector{KeyValue{4000000000, 1.0}},
+		SparseFeatureVector{KeyValue{4000000000, 2.0}},
+	}
+	y := []float32{3e9, 3e9, 0.0, 0.0}
+	tree := goassert.New(t).SucceedNew(NewCARTTrainer(CriterionGini).Train(X, y)).(*Leaf)
+	goassert.New(t, "(feature[4000000000] <= 0 ? 3e+09 : 0)").Equal(tree.String())
+	goassert.New(t, "class label must be a non-negative integer: y[0]=NaN").ExpectError(NewCARTTrainer(CriterionGini).Train(X, []float32{float32(math.NaN()), 0.0, 0.0, 0.0}))
+}
+
+func TestCARTTrainerMaxLeaves(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	X, y := make([]FeatureVector, 1000), make([]float32, 1000)
+	for i := range X {
+		x := DenseFeatureVector{rng.Float32(), rng.Float32()}
+		X[i], y[i] = x, x[0]*x[1]
+	}
+	trainer := NewCARTTrainer(CriterionMSE)
+	trainer.MaxLeaves = ForestMaxLeaves
+	tree := goassert.New(t).SucceedNew(trainer.Train(X, y)).(*Leaf)
+	forest := NewForest()
+	goassert.New(t).SucceedWithoutError(forest.Enqueue(tree))
+	for _, x := range X[:10] {
+		value := goassert.New(t).SucceedNew(tree.Predict(x))
+		goassert.New(t, []interface{}{value}).EqualWithoutError(forest.Predict(x))
+	}
+	trainer.MaxLeaves = 0
+	tree = goassert.New(t).SucceedNew(trainer.Train(X, y)).(*Leaf)
+	goassert.New(t, "the number of leaves in the tree must not be greater than 64").ExpectError(NewForest().Enqueue(tree))
+}
+
+func TestCARTTrainerError(t *testing.T) {
+	X := []FeatureVector{DenseFeatureVector{0.0}}
+	goassert.New(t, "the number of samples and targets must be equal").ExpectError(NewCARTTrainer(CriterionMSE).Train(X, []float32{}))
+	goassert.New(t, "dataset must not be empty").ExpectError(NewCARTTrainer(CriterionMSE).Train([]FeatureVector{}, []float32{}))
+	goassert.New(t, "unknown criterion: Criterion(3)").ExpectError(NewCARTTrainer(Criterion(3)).Train(X, []float32{0.0}))
+	goassert.New(t, "MaxDepth must not be negative").ExpectError((&CARTTrainer{MaxDepth: -1, MinSamplesLeaf: 1}).Train(X, []float32{0.0}))
+	goassert.New(t, "MinSamplesLeaf must be positive").ExpectError((&CARTTrainer{}).Train(X, []float32{0.0}))
+	goassert.New(t, "MaxLeaves must not be negative").ExpectError((&CARTTrainer{MinSamplesLeaf: 1, MaxLeaves: -1}).Train(X, []float32{0.0}))
+}
+
+func TestCARTTrainerMissingValues(t *testing.T) {
+	// The missing values go to the left leaf as in Leaf.Predict.
+	nan := float32(math.NaN())
+	X := []FeatureVector{
+		DenseFeatureVector{nan, 0.0},
+		DenseFeatureVector{0.0, nan},
+		DenseFeatureVector{1.0, 1.0},
+		DenseFeatureVector{2.0, nan},
+		DenseFeatureVector{3.0, 1.0},
+		DenseFeatureVector{nan, 1.0},
+	}
+	y := []float32{1.0, 1.0, 1.0, 5.0, 5.0, 1.0}
+	trainer := NewCARTTrainer(CriterionMSE)
+	for _, maxBins := range []int{0, 16} {
+		trainer.MaxBins = maxBins
+		tree := goassert.New(t).SucceedNew(trainer.Train(X, y)).(*Leaf)
+		goassert.New(t, "(feature[0] <= 1.5 ? 1 : 5)").Equal(tree.String())
+		for i, x := range X {
+			goassert.New(t, y[i]).EqualWithoutError(tree.Predict(x))
+		}
+	}
+	// The missing values cannot be separated from the smallest value, but the training and the prediction agree.
+	rng := rand.New(rand.NewSource(0))
+	X, y = make([]FeatureVector, 30), make([]float32, 30)
+	for i := range X {
+		if i < 20 {
+			X[i], y[i] = DenseFeatureVector{rng.Float32()}, 0.0
+		} else {
+			X[i], y[i] = DenseFeatureVector{nan}, 10.0
+		}
+	}
+	// The bin of the smallest values has 2 values.
+	for _, c := range []struct {
+		maxBins  int
+		expected float32
+	}{{0, 100.0 / 11.0}, {16, 100.0 / 12.0}} {
+		trainer.MaxBins = c.maxBins
+		tree := goassert.New(t).SucceedNew(trainer.Train(X, y)).(*Leaf)
+		goassert.New(t, c.expected).EqualWithoutError(tree.Predict(DenseFeatureVector{nan}))
+	}
+}
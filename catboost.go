@@ -0,0 +1,121 @@
+package confeito
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// catboostJSON is the JSON schema of a CatBoost model saved with format "json".
+type catboostJSON struct {
+	FeaturesInfo struct {
+		FloatFeatures []struct {
+			FeatureIndex      int    `json:"feature_index"`
+			FlatFeatureIndex  int    `json:"flat_feature_index"`
+			NanValueTreatment string `json:"nan_value_treatment"`
+		} `json:"float_features"`
+	} `json:"features_info"`
+	ObliviousTrees []struct {
+		LeafValues []float64 `json:"leaf_values"`
+		Splits     []struct {
+			Border            float64 `json:"border"`
+			FloatFeatureIndex int     `json:"float_feature_index"`
+			SplitType         string  `json:"split_type"`
+		} `json:"splits"`
+	} `json:"oblivious_trees"`
+	ScaleAndBias []json.RawMessage `json:"scale_and_bias"`
+}
+
+// UnmarshalCatBoostJSON returns a new ObliviousForest represented by JSON data of a CatBoost model saved with format "json".
+//
+// Each tree in oblivious_trees has the splits "x > border" giving the bits of the leaf index from the lowest one, which are converted into ObliviousTree.
+// The feature ID of a split is flat_feature_index of the float feature in features_info, or float_feature_index if features_info has no float features.
+// The trees have weight scale, and the bias is added as a tree of depth 0 with weight 1, where scale_and_bias is [scale, [bias, ...]].
+// For multi-dimensional models (multi-class classification), the trees have the values for class.
+// class is ignored for one-dimensional models.
+// The splits are exact, because the float64 borders are rounded down to float32, but the summation is in float32, so the predictions may differ by the rounding errors.
+//
+// Missing (NaN) values go to the left like Leaf, which is nan_value_treatment AsIs or AsFalse of CatBoost.
+//
+// This function returns an error if data is malformed, a split is not of a float feature, nan_value_treatment AsTrue is used, or class is out of range.
+func UnmarshalCatBoostJSON(data []byte, class int) (*ObliviousForest, error) {
+	var cj catboostJSON
+	if err := json.Unmarshal(data, &cj); err != nil {
+		return nil, err
+	}
+	if len(cj.ObliviousTrees) == 0 {
+		return nil, fmt.Errorf("oblivious_trees must not be empty")
+	}
+	featureIDs := map[int]FeatureID{}
+	for _, feature := range cj.FeaturesInfo.FloatFeatures {
+		if feature.NanValueTreatment == "AsTrue" {
+			// NaN goes to the right, which Leaf does not support.
+			featureIDs[feature.FeatureIndex] = _FEATURE_ID_ILLEGAL
+		} else {
+			featureIDs[feature.FeatureIndex] = FeatureID(feature.FlatFeatureIndex)
+		}
+	}
+	scale, bias := 1.0, []float64{}
+	if len(cj.ScaleAndBias) > 0 {
+		if len(cj.ScaleAndBias) != 2 {
+			return nil, fmt.Errorf("scale_and_bias must be [scale, [bias, ...]]")
+		}
+		if err := json.Unmarshal(cj.ScaleAndBias[0], &scale); err != nil {
+			return nil, fmt.Errorf("scale_and_bias: %s", err)
+		}
+		if err := json.Unmarshal(cj.ScaleAndBias[1], &bias); err != nil {
+			return nil, fmt.Errorf("scale_and_bias: %s", err)
+		}
+	}
+	ndims := 0
+	trees, weights := []*ObliviousTree{}, []float32{}
+	for t, ctree := range cj.ObliviousTrees {
+		tree := &ObliviousTree{FeatureIDs: []FeatureID{}, Thresholds: []float32{}}
+		for s, split := range ctree.Splits {
+			if split.SplitType != "FloatFeature" {
+				return nil, fmt.Errorf("tree %d: split %d: unsupported split_type %q", t, s, split.SplitType)
+			}
+			featureID, ok := FeatureID(split.FloatFeatureIndex), len(cj.FeaturesInfo.FloatFeatures) == 0
+			if !ok {
+				featureID, ok = featureIDs[split.FloatFeatureIndex]
+			}
+			if !ok || split.FloatFeatureIndex < 0 {
+				return nil, fmt.Errorf("tree %d: split %d: unknown float_feature_index %d", t, s, split.FloatFeatureIndex)
+			}
+			if featureID == _FEATURE_ID_ILLEGAL {
+				return nil, fmt.Errorf("tree %d: split %d: nan_value_treatment AsTrue is not supported", t, s)
+			}
+			tree.FeatureIDs, tree.Thresholds = append(tree.FeatureIDs, featureID), append(tree.Thresholds, float32Floor(split.Border))
+		}
+		if len(tree.FeatureIDs) > ObliviousMaxDepth {
+			return nil, fmt.Errorf("tree %d: depth must not be greater than %d", t, ObliviousMaxDepth)
+		}
+		nleaves := 1 << uint(len(tree.FeatureIDs))
+		if len(ctree.LeafValues) == 0 || len(ctree.LeafValues)%nleaves != 0 {
+			return nil, fmt.Errorf("tree %d: the number of leaf_values must be a positive multiple of %d", t, nleaves)
+		}
+		if t == 0 {
+			ndims = len(ctree.LeafValues) / nleaves
+			if ndims == 1 {
+				class = 0
+			} else if !(0 <= class && class < ndims) {
+				return nil, fmt.Errorf("class %d is out of range [0, %d)", class, ndims)
+			}
+		} else if len(ctree.LeafValues) != ndims*nleaves {
+			return nil, fmt.Errorf("tree %d: the number of leaf_values must be %d", t, ndims*nleaves)
+		}
+		// leaf_values has the values of all dimensions for each leaf index.
+		tree.Values = make([]float32, nleaves)
+		for i := range tree.Values {
+			tree.Values[i] = float32(ctree.LeafValues[i*ndims+class])
+		}
+		trees, weights = append(trees, tree), append(weights, float32(scale))
+	}
+	if len(bias) > 0 {
+		if len(bias) != ndims {
+			return nil, fmt.Errorf("scale_and_bias must have %d biases", ndims)
+		}
+		trees = append(trees, &ObliviousTree{FeatureIDs: []FeatureID{}, Thresholds: []float32{}, Values: []float32{float32(bias[class])}})
+		weights = append(weights, 1.0)
+	}
+	return NewObliviousForest(trees, weights)
+}
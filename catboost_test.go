@@ -0,0 +1,67 @@
+package confeito
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+// catboostModel is a CatBoost model of two trees on the float features at flat feature indices 0 and 2, where the flat feature index 1 is a categorical feature.
+const catboostModel = `{
+	"features_info": {"float_features": [
+		{"feature_index": 0, "flat_feature_index": 0, "borders": [0.5], "nan_value_treatment": "AsFalse"},
+		{"feature_index": 1, "flat_feature_index": 2, "borders": [0.1, 1], "nan_value_treatment": "AsIs"}
+	]},
+	"oblivious_trees": [
+		{"leaf_values": [1, 2, 3, 4], "splits": [
+			{"border": 0.5, "float_feature_index": 0, "split_index": 0, "split_type": "FloatFeature"},
+			{"border": 0.1, "float_feature_index": 1, "split_index": 1, "split_type": "FloatFeature"}
+		]},
+		{"leaf_values": [-1, 1], "splits": [
+			{"border": 1, "float_feature_index": 1, "split_index": 2, "split_type": "FloatFeature"}
+		]}
+	],
+	"scale_and_bias": [2, [10]]
+}`
+
+// catboostMultiClassModel is a CatBoost model of a single tree for 3 classes.
+const catboostMultiClassModel = `{
+	"oblivious_trees": [
+		{"leaf_values": [1, 2, 3, 4, 5, 6], "splits": [{"border": 0, "float_feature_index": 1, "split_type": "FloatFeature"}]}
+	],
+	"scale_and_bias": [1, [0.5, 0, -0.5]]
+}`
+
+func TestUnmarshalCatBoostJSON(t *testing.T) {
+	forest := goassert.New(t).SucceedNew(UnmarshalCatBoostJSON([]byte(catboostModel), 0)).(*ObliviousForest)
+	goassert.New(t, []float32{2.0, 2.0, 1.0}).Equal(forest.Weights())
+	goassert.New(t, &ObliviousTree{
+		FeatureIDs: []FeatureID{0, 2},
+		Thresholds: []float32{0.5, float32Floor(0.1)},
+		Values:     []float32{1.0, 2.0, 3.0, 4.0},
+	}).EqualWithoutError(forest.Tree(0))
+	goassert.New(t, &ObliviousTree{FeatureIDs: []FeatureID{}, Thresholds: []float32{}, Values: []float32{10.0}}).EqualWithoutError(forest.Tree(2))
+	goassert.New(t, float32(10.0)).EqualWithoutError(forest.PredictSum(DenseFeatureVector{0.5, 0.0, 0.0}))
+	// float32(0.1) > 0.1, so it goes to the right as CatBoost does.
+	goassert.New(t, float32(16.0)).EqualWithoutError(forest.PredictSum(DenseFeatureVector{1.0, 0.0, float32(0.1)}))
+	goassert.New(t, float32(20.0)).EqualWithoutError(forest.PredictSum(DenseFeatureVector{1.0, 0.0, 2.0}))
+	goassert.New(t, float32(10.0)).EqualWithoutError(forest.PredictSum(DenseFeatureVector{float32(math.NaN()), 0.0, float32(math.NaN())}))
+
+	forest = goassert.New(t).SucceedNew(UnmarshalCatBoostJSON([]byte(catboostMultiClassModel), 2)).(*ObliviousForest)
+	goassert.New(t, []float32{6.0, -0.5}).EqualWithoutError(forest.Predict(DenseFeatureVector{0.0, 1.0}))
+	goassert.New(t, float32(5.5)).EqualWithoutError(forest.PredictSum(DenseFeatureVector{0.0, 1.0}))
+	forest = goassert.New(t).SucceedNew(UnmarshalCatBoostJSON([]byte(catboostMultiClassModel), 0)).(*ObliviousForest)
+	goassert.New(t, float32(1.5)).EqualWithoutError(forest.PredictSum(DenseFeatureVector{0.0, 0.0}))
+	goassert.New(t, "class 3 is out of range [0, 3)").ExpectError(UnmarshalCatBoostJSON([]byte(catboostMultiClassModel), 3))
+
+	goassert.New(t, "oblivious_trees must not be empty").ExpectError(UnmarshalCatBoostJSON([]byte(`{}`), 0))
+	goassert.New(t, "scale_and_bias must be [scale, [bias, ...]]").ExpectError(UnmarshalCatBoostJSON([]byte(strings.Replace(catboostModel, "[2, [10]]", "[2]", 1)), 0))
+	goassert.New(t, "scale_and_bias must have 1 biases").ExpectError(UnmarshalCatBoostJSON([]byte(strings.Replace(catboostModel, "[2, [10]]", "[2, [10, 0]]", 1)), 0))
+	goassert.New(t, `tree 1: split 0: unsupported split_type "OnlineCtr"`).ExpectError(UnmarshalCatBoostJSON([]byte(strings.Replace(catboostModel, `"split_index": 2, "split_type": "FloatFeature"`, `"split_index": 2, "split_type": "OnlineCtr"`, 1)), 0))
+	goassert.New(t, "tree 1: split 0: unknown float_feature_index 2").ExpectError(UnmarshalCatBoostJSON([]byte(strings.Replace(catboostModel, `"border": 1, "float_feature_index": 1`, `"border": 1, "float_feature_index": 2`, 1)), 0))
+	goassert.New(t, "tree 0: split 0: nan_value_treatment AsTrue is not supported").ExpectError(UnmarshalCatBoostJSON([]byte(strings.Replace(catboostModel, "AsFalse", "AsTrue", 1)), 0))
+	goassert.New(t, "tree 1: the number of leaf_values must be a positive multiple of 2").ExpectError(UnmarshalCatBoostJSON([]byte(strings.Replace(catboostModel, "[-1, 1]", "[-1]", 1)), 0))
+	goassert.New(t, "tree 1: the number of leaf_values must be 2").ExpectError(UnmarshalCatBoostJSON([]byte(strings.Replace(catboostModel, "[-1, 1]", "[-1, 1, 0, 0]", 1)), 0))
+}
@@ -0,0 +1,303 @@
+package confeito
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CSourceForm is the type of the form of the C source code written by WriteCSource.
+type CSourceForm int
+
+const (
+	// CSourceIfElse implements each tree as nested if/else statements.
+	CSourceIfElse = CSourceForm(iota)
+	// CSourceQuickScorer implements the forest as the QuickScorer tables, which is the same algorithm as Forest.
+	// The trees are scored in blocks of 64 trees, so the stack usage does not depend on the number of trees.
+	CSourceQuickScorer
+)
+
+// String returns the name of form.
+func (form CSourceForm) String() string {
+	switch form {
+	case CSourceIfElse:
+		return "if-else"
+	case CSourceQuickScorer:
+		return "quickscorer"
+	default:
+		return fmt.Sprintf("CSourceForm(%d)", int(form))
+	}
+}
+
+// CSourceOptions is the options of generating C source code by WriteCSource.
+type CSourceOptions struct {
+	// Function is the name of the generated scoring function.
+	Function string
+	// Header is the file name of the header included by the source file.
+	// If it is empty, then Function+".h" is used.
+	Header string
+	// Form is the form of the implementation.
+	Form CSourceForm
+}
+
+// cIdentifierPattern is the pattern of C identifiers.
+var cIdentifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// formatCFloat returns the C expression of float value v.
+func formatCFloat(v float32) string {
+	switch {
+	case math.IsNaN(float64(v)):
+		return "NAN"
+	case math.IsInf(float64(v), 1):
+		return "INFINITY"
+	case math.IsInf(float64(v), -1):
+		return "-INFINITY"
+	}
+	s := strconv.FormatFloat(float64(v), 'g', -1, 32)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s + "f"
+}
+
+// writeCTree writes the nested if/else statements of the tree whose root is l.
+func writeCTree(w *bufio.Writer, l *Leaf, function, indent string, path LeafPath) error {
+	if l.IsTerminal() {
+		value, err := treeFloat32Value(l, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%sreturn %s;\n", indent, formatCFloat(value))
+		return nil
+	}
+	fmt.Fprintf(w, "%sif (%s_feature(x, n, %d) %s %s) {\n", indent, function, l.featureID, rightComparison(l), formatCFloat(l.threshold))
+	if err := writeCTree(w, l.right, function, indent+"\t", append(path, true)); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%s}\n", indent)
+	return writeCTree(w, l.left, function, indent, append(path, false))
+}
+
+// writeCArray writes the static constant array of the elements formatted by format.
+func writeCArray(w *bufio.Writer, typ, name string, n int, format func(i int) string) {
+	fmt.Fprintf(w, "static const %s %s[%d] = {", typ, name, n)
+	for i := 0; i < n; i++ {
+		if i%8 == 0 {
+			w.WriteString("\n\t")
+		} else {
+			w.WriteString(" ")
+		}
+		w.WriteString(format(i))
+		w.WriteString(",")
+	}
+	w.WriteString("\n};\n")
+}
+
+// cQuickScorerBlockSize is the number of trees in a block of the QuickScorer tables written by writeCQuickScorer.
+const cQuickScorerBlockSize = 64
+
+// writeCQuickScorer writes the QuickScorer tables of forest and the scoring function using them.
+// The trees are scored in blocks of cQuickScorerBlockSize trees, so that the scoring function keeps only the bit vectors of a block on the stack regardless of the number of trees.
+// The entries are grouped by the block and then by the feature, and have the tree indices in the block.
+func writeCQuickScorer(w *bufio.Writer, forest *Forest, function string, weights []float32) {
+	ntrees := len(forest.trees)
+	nblocks := (ntrees + cQuickScorerBlockSize - 1) / cQuickScorerBlockSize
+	featureIDs := make([]int, 0, len(forest.features))
+	for featureID := range forest.features {
+		featureIDs = append(featureIDs, int(featureID))
+	}
+	sort.Ints(featureIDs)
+	var thresholds []float32
+	var operators []SplitOperator
+	var treeIDs []int
+	var bvs []uint64
+	hasLess := false
+	offsets := make([]int, nblocks*len(featureIDs)+1)
+	for b := 0; b < nblocks; b++ {
+		for i, featureID := range featureIDs {
+			feature := forest.features[FeatureID(featureID)]
+			for p, treeID := range feature.treeIDs {
+				if treeID/cQuickScorerBlockSize != b {
+					continue
+				}
+				thresholds = append(thresholds, feature.thresholds[p])
+				operators = append(operators, feature.operators[p])
+				treeIDs = append(treeIDs, treeID%cQuickScorerBlockSize)
+				bvs = append(bvs, feature.bvs[p])
+			}
+			offsets[b*len(featureIDs)+i+1] = len(thresholds)
+		}
+	}
+	var values []float32
+	valueOffsets := make([]int, ntrees)
+	for t, tree := range forest.trees {
+		valueOffsets[t] = len(values)
+		for _, value := range tree.values {
+			values = append(values, value.(float32))
+		}
+	}
+	writeCArray(w, "float", function+"_weights", ntrees, func(t int) string {
+		return formatCFloat(weights[t])
+	})
+	writeCArray(w, "uint32_t", function+"_value_offsets", ntrees, func(t int) string {
+		return strconv.Itoa(valueOffsets[t])
+	})
+	writeCArray(w, "uint32_t", function+"_num_values", ntrees, func(t int) string {
+		return strconv.Itoa(len(forest.trees[t].values))
+	})
+	writeCArray(w, "float", function+"_values", len(values), func(i int) string {
+		return formatCFloat(values[i])
+	})
+	if len(featureIDs) > 0 {
+		writeCArray(w, "uint32_t", function+"_feature_ids", len(featureIDs), func(i int) string {
+			return strconv.Itoa(featureIDs[i])
+		})
+		writeCArray(w, "uint32_t", function+"_feature_offsets", len(offsets), func(i int) string {
+			return strconv.Itoa(offsets[i])
+		})
+		writeCArray(w, "float", function+"_thresholds", len(thresholds), func(p int) string {
+			return formatCFloat(thresholds[p])
+		})
+		// The operators are written only if some split has operator Less.
+		for _, operator := range operators {
+			if operator == Less {
+				hasLess = true
+			}
+		}
+		if hasLess {
+			writeCArray(w, "uint8_t", function+"_less", len(operators), func(p int) string {
+				if operators[p] == Less {
+					return "1"
+				}
+				return "0"
+			})
+		}
+		writeCArray(w, "uint8_t", function+"_tree_ids", len(treeIDs), func(p int) string {
+			return strconv.Itoa(treeIDs[p])
+		})
+		writeCArray(w, "uint64_t", function+"_bvs", len(bvs), func(p int) string {
+			return fmt.Sprintf("UINT64_C(0x%016x)", bvs[p])
+		})
+	}
+	w.WriteString("\n")
+	fmt.Fprintf(w, "static int %s_exit_leaf(uint64_t bv) {\n", function)
+	w.WriteString("\tint leaf = -1;\n")
+	w.WriteString("\twhile (bv != 0) {\n\t\tbv >>= 1;\n\t\tleaf++;\n\t}\n")
+	w.WriteString("\treturn leaf;\n}\n\n")
+	fmt.Fprintf(w, "float %s(const float *x, size_t n) {\n", function)
+	fmt.Fprintf(w, "\tuint64_t bvs[%d];\n", cQuickScorerBlockSize)
+	w.WriteString("\tfloat score = 0.0f;\n")
+	w.WriteString("\tsize_t b, t;\n")
+	if len(featureIDs) > 0 {
+		w.WriteString("\tsize_t i, p;\n")
+	}
+	fmt.Fprintf(w, "\tfor (b = 0; b < %d; b++) {\n", nblocks)
+	fmt.Fprintf(w, "\t\tsize_t begin = b * %d;\n", cQuickScorerBlockSize)
+	fmt.Fprintf(w, "\t\tsize_t ntrees = b + 1 < %d ? %d : %d;\n", nblocks, cQuickScorerBlockSize, ntrees-(nblocks-1)*cQuickScorerBlockSize)
+	w.WriteString("\t\tfor (t = 0; t < ntrees; t++) {\n")
+	fmt.Fprintf(w, "\t\t\tbvs[t] = %s_num_values[begin + t] == 64 ? ~UINT64_C(0) : (UINT64_C(1) << %s_num_values[begin + t]) - 1;\n", function, function)
+	w.WriteString("\t\t}\n")
+	if len(featureIDs) > 0 {
+		fmt.Fprintf(w, "\t\tfor (i = 0; i < %d; i++) {\n", len(featureIDs))
+		fmt.Fprintf(w, "\t\t\tfloat value = %s_feature(x, n, %s_feature_ids[i]);\n", function, function)
+		applies := fmt.Sprintf("%s_thresholds[p] < value", function)
+		if hasLess {
+			applies = fmt.Sprintf("(%s_thresholds[p] < value || (%s_thresholds[p] == value && %s_less[p]))", function, function, function)
+		}
+		fmt.Fprintf(w, "\t\t\tfor (p = %s_feature_offsets[b * %d + i]; p < %s_feature_offsets[b * %d + i + 1] && %s; p++) {\n", function, len(featureIDs), function, len(featureIDs), applies)
+		fmt.Fprintf(w, "\t\t\t\tbvs[%s_tree_ids[p]] &= %s_bvs[p];\n", function, function)
+		w.WriteString("\t\t\t}\n\t\t}\n")
+	}
+	w.WriteString("\t\tfor (t = 0; t < ntrees; t++) {\n")
+	fmt.Fprintf(w, "\t\t\tscore += %s_weights[begin + t] * %s_values[%s_value_offsets[begin + t] + %s_exit_leaf(bvs[t])];\n", function, function, function, function)
+	w.WriteString("\t\t}\n\t}\n")
+	w.WriteString("\treturn score;\n}\n")
+}
+
+// WriteCSource writes the self-contained C99 source file and header implementing the summed score of forest (see Forest.PredictSum) to source and header respectively.
+// The generated function has signature float f(const float *x, size_t n), where the features not in x[0:n] are regarded as 0.
+// A forest of Leaf trees can be compiled by NewForestFromTrees.
+//
+// This function returns an error if options has an illegal identifier or form, a value of forest is not float32, or at writing to source or header.
+func WriteCSource(source, header io.Writer, forest *Forest, options *CSourceOptions) error {
+	function := options.Function
+	if !cIdentifierPattern.MatchString(function) {
+		return fmt.Errorf("illegal function name: %q", function)
+	}
+	headerName := options.Header
+	if headerName == "" {
+		headerName = function + ".h"
+	}
+	if strings.ContainsAny(headerName, "\"\n") {
+		return fmt.Errorf("illegal header name: %q", headerName)
+	}
+	if options.Form != CSourceIfElse && options.Form != CSourceQuickScorer {
+		return fmt.Errorf("illegal form: %s", options.Form)
+	}
+	forest.mutex.RLock()
+	defer forest.mutex.RUnlock()
+	trees := forest.rebuildTrees(0, len(forest.trees))
+	for t, tree := range trees {
+		if err := tree.Walk(PreOrder, func(leaf *Leaf, path LeafPath) error {
+			if !leaf.IsTerminal() {
+				return nil
+			}
+			_, err := treeFloat32Value(leaf, path)
+			return err
+		}); err != nil {
+			return fmt.Errorf("tree %d: %s", t, err)
+		}
+	}
+	guard := strings.ToUpper(function) + "_H"
+	hw := bufio.NewWriter(header)
+	hw.WriteString("/* Code generated by confeito; DO NOT EDIT. */\n\n")
+	fmt.Fprintf(hw, "#ifndef %s\n#define %s\n\n", guard, guard)
+	hw.WriteString("#include <stddef.h>\n\n")
+	hw.WriteString("#ifdef __cplusplus\nextern \"C\" {\n#endif\n\n")
+	hw.WriteString("/* Returns the score of the model for feature vector x[0:n].\n * The features not in x[0:n] are regarded as 0. */\n")
+	fmt.Fprintf(hw, "float %s(const float *x, size_t n);\n\n", function)
+	hw.WriteString("#ifdef __cplusplus\n}\n#endif\n\n")
+	fmt.Fprintf(hw, "#endif /* %s */\n", guard)
+	if err := hw.Flush(); err != nil {
+		return err
+	}
+	weights := forest.weights
+	sw := bufio.NewWriter(source)
+	sw.WriteString("/* Code generated by confeito; DO NOT EDIT. */\n\n")
+	fmt.Fprintf(sw, "#include \"%s\"\n\n", headerName)
+	sw.WriteString("#include <math.h>\n#include <stdint.h>\n\n")
+	// The feature accessor is written only if some tree has a split, because C compilers warn the unused static functions.
+	if len(forest.features) > 0 {
+		fmt.Fprintf(sw, "static float %s_feature(const float *x, size_t n, size_t i) {\n", function)
+		sw.WriteString("\treturn i < n ? x[i] : 0.0f;\n}\n\n")
+	}
+	switch options.Form {
+	case CSourceIfElse:
+		for t, tree := range trees {
+			fmt.Fprintf(sw, "static float %s_tree%d(const float *x, size_t n) {\n", function, t)
+			if err := writeCTree(sw, tree, function, "\t", LeafPath{}); err != nil {
+				return fmt.Errorf("tree %d: %s", t, err)
+			}
+			sw.WriteString("}\n\n")
+		}
+		fmt.Fprintf(sw, "float %s(const float *x, size_t n) {\n", function)
+		sw.WriteString("\tfloat score = 0.0f;\n")
+		for t := range forest.trees {
+			fmt.Fprintf(sw, "\tscore += %s * %s_tree%d(x, n);\n", formatCFloat(weights[t]), function, t)
+		}
+		sw.WriteString("\treturn score;\n}\n")
+	case CSourceQuickScorer:
+		if len(forest.trees) == 0 {
+			fmt.Fprintf(sw, "float %s(const float *x, size_t n) {\n", function)
+			sw.WriteString("\t(void)x;\n\t(void)n;\n\treturn 0.0f;\n}\n")
+			break
+		}
+		writeCQuickScorer(sw, forest, function, weights)
+	}
+	return sw.Flush()
+}
@@ -0,0 +1,162 @@
+package confeito
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestCSourceForm(t *testing.T) {
+	goassert.New(t, "if-else").Equal(CSourceIfElse.String())
+	goassert.New(t, "quickscorer").Equal(CSourceQuickScorer.String())
+	goassert.New(t, "CSourceForm(2)").Equal(CSourceForm(2).String())
+}
+
+func TestWriteCSource(t *testing.T) {
+	tree := goassert.New(t).SucceedNew(ParseLeaf("(feature[0] <= 0.5 ? 1 : (feature[2] < -1e-05 ? 2 : 3))")).(*Leaf)
+	forest := goassert.New(t).SucceedNew(NewForestFromTrees(tree)).(*Forest)
+	var source, header bytes.Buffer
+	goassert.New(t).SucceedWithoutError(WriteCSource(&source, &header, forest, &CSourceOptions{Function: "score"}))
+	goassert.New(t, `/* Code generated by confeito; DO NOT EDIT. */
+
+#ifndef SCORE_H
+#define SCORE_H
+
+#include <stddef.h>
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+/* Returns the score of the model for feature vector x[0:n].
+ * The features not in x[0:n] are regarded as 0. */
+float score(const float *x, size_t n);
+
+#ifdef __cplusplus
+}
+#endif
+
+#endif /* SCORE_H */
+`).Equal(header.String())
+	goassert.New(t, `/* Code generated by confeito; DO NOT EDIT. */
+
+#include "score.h"
+
+#include <math.h>
+#include <stdint.h>
+
+static float score_feature(const float *x, size_t n, size_t i) {
+	return i < n ? x[i] : 0.0f;
+}
+
+static float score_tree0(const float *x, size_t n) {
+	if (score_feature(x, n, 0) > 0.5f) {
+		if (score_feature(x, n, 2) >= -1e-05f) {
+			return 3.0f;
+		}
+		return 2.0f;
+	}
+	return 1.0f;
+}
+
+float score(const float *x, size_t n) {
+	float score = 0.0f;
+	score += 1.0f * score_tree0(x, n);
+	return score;
+}
+`).Equal(source.String())
+	source.Reset()
+	goassert.New(t).SucceedWithoutError(WriteCSource(&source, &header, forest, &CSourceOptions{Function: "score", Header: "model.h", Form: CSourceQuickScorer}))
+	goassert.New(t, true).Equal(strings.Contains(source.String(), "#include \"model.h\"\n"))
+	goassert.New(t, true).Equal(strings.Contains(source.String(), "static const float score_thresholds[2] = {\n\t0.5f, -1e-05f,\n};\n"))
+	goassert.New(t, true).Equal(strings.Contains(source.String(), "static const uint8_t score_less[2] = {\n\t0, 1,\n};\n"))
+	goassert.New(t, "illegal function name: \"1score\"").ExpectError(WriteCSource(&source, &header, forest, &CSourceOptions{Function: "1score"}))
+	goassert.New(t, "illegal header name: \"a\\\"b.h\"").ExpectError(WriteCSource(&source, &header, forest, &CSourceOptions{Function: "score", Header: "a\"b.h"}))
+	goassert.New(t, "illegal form: CSourceForm(2)").ExpectError(WriteCSource(&source, &header, forest, &CSourceOptions{Function: "score", Form: CSourceForm(2)}))
+	goassert.New(t).SucceedWithoutError(forest.Enqueue(goassert.New(t).SucceedNew(NewLeaf(0, 0.0, float32(1.0), "b")).(*Leaf)))
+	goassert.New(t, "tree 1: root.right: value must be float32: \"b\"").ExpectError(WriteCSource(&source, &header, forest, &CSourceOptions{Function: "score", Form: CSourceQuickScorer}))
+}
+
+func TestFormatCFloat(t *testing.T) {
+	goassert.New(t, "1.0f").Equal(formatCFloat(1))
+	goassert.New(t, "-0.25f").Equal(formatCFloat(-0.25))
+	goassert.New(t, "1e+10f").Equal(formatCFloat(1e10))
+	goassert.New(t, "NAN").Equal(formatCFloat(float32(math.NaN())))
+	goassert.New(t, "-INFINITY").Equal(formatCFloat(float32(math.Inf(-1))))
+}
+
+// TestWriteCSourceHarness compiles the generated source in each form, and verifies that it matches Forest.PredictSum on random inputs.
+func TestWriteCSourceHarness(t *testing.T) {
+	ccPath, err := exec.LookPath("cc")
+	if err != nil || testing.Short() {
+		t.Skip("cc command is not available, or -short is given")
+	}
+	X, y := newRandomForestDataset(500, false)
+	trainer := NewGradientBoostingTrainer(SquaredLoss{})
+	trainer.NumTrees, trainer.MaxLeaves = 20, ForestMaxLeaves
+	trees := goassert.New(t).SucceedNew(trainer.Train(X, y)).([]*Leaf)
+	thresholds := setAlternateOperators(t, trees)
+	// The forest has more trees than a block of the QuickScorer tables.
+	forest := goassert.New(t).SucceedNew(NewForestFromTrees(append(append(append(append([]*Leaf{}, trees...), trees...), trees...), trees...)...)).(*Forest)
+	goassert.New(t).SucceedWithoutError(forest.SetDecay(0.9))
+	rng := rand.New(rand.NewSource(1))
+	inputs := make([][]float32, 1000)
+	var main bytes.Buffer
+	main.WriteString("#include <math.h>\n#include <stdio.h>\n#include <string.h>\n#include <stdint.h>\n#include \"model.h\"\n\n")
+	for i := range inputs {
+		inputs[i] = newHarnessInput(rng, thresholds)
+		elems := []string{"0.0f"}
+		for _, value := range inputs[i] {
+			elems = append(elems, formatCFloat(value))
+		}
+		fmt.Fprintf(&main, "static const float x%d[] = {%s};\n", i, strings.Join(elems, ", "))
+	}
+	main.WriteString("\nint main(void) {\n\tfloat s;\n\tuint32_t bits;\n")
+	for i := range inputs {
+		fmt.Fprintf(&main, "\ts = model(x%d + 1, %d);\n\tmemcpy(&bits, &s, sizeof(bits));\n\tprintf(\"%%u\\n\", bits);\n", i, len(inputs[i]))
+	}
+	main.WriteString("\treturn 0;\n}\n")
+	// The empty forest and the forest of terminal trees have no split.
+	constantForest := goassert.New(t).SucceedNew(NewForestFromTrees(goassert.New(t).SucceedNew(NewTerminalLeaf(float32(0.5))).(*Leaf))).(*Forest)
+	for _, forest := range []*Forest{forest, NewForest(), constantForest} {
+		for _, form := range []CSourceForm{CSourceIfElse, CSourceQuickScorer} {
+			runCSourceHarness(t, ccPath, forest, form, inputs, main.Bytes())
+		}
+	}
+}
+
+// runCSourceHarness compiles the source of forest in form with main, and verifies that it matches Forest.PredictSum on inputs.
+func runCSourceHarness(t *testing.T, ccPath string, forest *Forest, form CSourceForm, inputs [][]float32, main []byte) {
+	dir := t.TempDir()
+	var source, header bytes.Buffer
+	goassert.New(t).SucceedWithoutError(WriteCSource(&source, &header, forest, &CSourceOptions{Function: "model", Form: form}))
+	goassert.New(t).SucceedWithoutError(os.WriteFile(filepath.Join(dir, "model.c"), source.Bytes(), 0644))
+	goassert.New(t).SucceedWithoutError(os.WriteFile(filepath.Join(dir, "model.h"), header.Bytes(), 0644))
+	goassert.New(t).SucceedWithoutError(os.WriteFile(filepath.Join(dir, "main.c"), main, 0644))
+	binPath := filepath.Join(dir, "main")
+	cmd := exec.Command(ccPath, "-std=c99", "-Wall", "-Werror", "-O2", "-ffp-contract=off", "-o", binPath, "main.c", "model.c")
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("%s: cc failed: %s\n%s", form, err, output)
+	}
+	output, err := exec.Command(binPath).Output()
+	if err != nil {
+		t.Fatalf("%s: %s", form, err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	goassert.New(t, len(inputs)).Equal(len(lines))
+	for i, x := range inputs {
+		expected := goassert.New(t).SucceedNew(forest.PredictSum(DenseFeatureVector(x))).(float32)
+		bits := goassert.New(t).SucceedNew(strconv.ParseUint(lines[i], 10, 32)).(uint64)
+		goassert.New(t, expected).Equal(math.Float32frombits(uint32(bits)))
+	}
+}
@@ -0,0 +1,66 @@
+// Command confeito-gogen generates the standalone Go source file implementing the summed score of a forest.
+//
+// The model is read in the native model format (see confeito.Forest.MarshalJSON).
+// This command is intended to be used with go:generate like:
+//
+//	//go:generate confeito-gogen -model model.json -package mypkg -func Score -o model_gen.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hiro4bbh/confeito"
+)
+
+// run runs this command with the arguments args (without the command name), and writes the generated source to stdout if -o is not given.
+//
+// This function returns an error if args is illegal, or at reading the model, generating the source or writing it.
+func run(args []string, stdout io.Writer) error {
+	flagSet := flag.NewFlagSet("confeito-gogen", flag.ContinueOnError)
+	flagSet.SetOutput(io.Discard)
+	modelPath := flagSet.String("model", "", "path of the model in the native model format (required)")
+	packageName := flagSet.String("package", os.Getenv("GOPACKAGE"), "package name of the generated file (default is $GOPACKAGE)")
+	functionName := flagSet.String("func", "Score", "name of the generated scoring function")
+	outputPath := flagSet.String("o", "", "path of the generated file (default is the standard output)")
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if flagSet.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %q", flagSet.Args())
+	}
+	if *modelPath == "" {
+		return fmt.Errorf("-model must be given")
+	}
+	data, err := os.ReadFile(*modelPath)
+	if err != nil {
+		return err
+	}
+	forest := confeito.NewForest()
+	if err := json.Unmarshal(data, forest); err != nil {
+		return fmt.Errorf("%s: %s", *modelPath, err)
+	}
+	var buf bytes.Buffer
+	if err := confeito.WriteGoSource(&buf, forest, &confeito.GoSourceOptions{
+		Package:  *packageName,
+		Function: *functionName,
+	}); err != nil {
+		return err
+	}
+	if *outputPath == "" {
+		_, err := stdout.Write(buf.Bytes())
+		return err
+	}
+	return os.WriteFile(*outputPath, buf.Bytes(), 0644)
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "confeito-gogen: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hiro4bbh/confeito"
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	tree := goassert.New(t).SucceedNew(confeito.ParseLeaf("(feature[0] <= 0.5 ? 1 : 2)")).(*confeito.Leaf)
+	forest := goassert.New(t).SucceedNew(confeito.NewForestFromTrees(tree)).(*confeito.Forest)
+	modelPath := filepath.Join(dir, "model.json")
+	goassert.New(t).SucceedWithoutError(os.WriteFile(modelPath, goassert.New(t).SucceedNew(json.Marshal(forest)).([]byte), 0644))
+	var stdout bytes.Buffer
+	goassert.New(t).SucceedWithoutError(run([]string{"-model", modelPath, "-package", "model", "-func", "Predict"}, &stdout))
+	goassert.New(t, true).Equal(strings.Contains(stdout.String(), "package model\n"))
+	goassert.New(t, true).Equal(strings.Contains(stdout.String(), "func Predict(x []float32) float32 {"))
+	outputPath := filepath.Join(dir, "model_gen.go")
+	goassert.New(t).SucceedWithoutError(run([]string{"-model", modelPath, "-package", "model", "-func", "Predict", "-o", outputPath}, &bytes.Buffer{}))
+	goassert.New(t, stdout.String()).Equal(string(goassert.New(t).SucceedNew(os.ReadFile(outputPath)).([]byte)))
+	goassert.New(t, "-model must be given").ExpectError(run([]string{"-package", "model"}, &stdout))
+	goassert.New(t, "unexpected arguments: [\"extra\"]").ExpectError(run([]string{"-model", modelPath, "extra"}, &stdout))
+	goassert.New(t, "illegal package name: \"\"").ExpectError(run([]string{"-model", modelPath}, &stdout))
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"github.com/hiro4bbh/confeito"
+)
+
+// benchTolerance is the relative tolerance of the difference between the predictions compared by runBench.
+// The scorers may sum the values in different orders, so the predictions can differ in the last bits.
+const benchTolerance = 1e-5
+
+// equalScores returns true if score and expected are equal within benchTolerance, or both are NaN.
+func equalScores(score, expected float32) bool {
+	if math.IsNaN(float64(score)) || math.IsNaN(float64(expected)) {
+		return math.IsNaN(float64(score)) && math.IsNaN(float64(expected))
+	}
+	if score == expected {
+		return true
+	}
+	if math.IsInf(float64(score), 0) || math.IsInf(float64(expected), 0) {
+		return false
+	}
+	return math.Abs(float64(score)-float64(expected)) <= benchTolerance*math.Max(1.0, math.Abs(float64(expected)))
+}
+
+// benchmarkPredict returns the mean time of predicting the data points in X by predictSum, which is measured by repeating over X for at least duration.
+//
+// This function returns an error if predictSum returns an error.
+func benchmarkPredict(X []confeito.FeatureVector, predictSum func(x confeito.FeatureVector) (float32, error), duration time.Duration) (time.Duration, error) {
+	n := 0
+	start := time.Now()
+	for {
+		for _, x := range X {
+			if _, err := predictSum(x); err != nil {
+				return 0, err
+			}
+		}
+		n += len(X)
+		if elapsed := time.Since(start); elapsed >= duration {
+			return elapsed / time.Duration(n), nil
+		}
+	}
+}
+
+// runBench runs the subcommand bench with the arguments args, which reads the data from the file given as the argument or stdin, and writes to stdout the throughputs of predicting the data by Forest (or ObliviousForest for the format catboost) and by traversing each tree (see confeito.Ensemble.PredictSum).
+//
+// This function returns an error if args is illegal, at reading the model or the data, or if the predictions differ (see equalScores).
+func runBench(args []string, stdin io.Reader, stdout io.Writer) error {
+	flagSet := flag.NewFlagSet("confeito bench", flag.ContinueOnError)
+	flagSet.SetOutput(io.Discard)
+	modelPath, options := newModelFlags(flagSet, "class (or target) whose score is predicted")
+	dataFormat := flagSet.String("data-format", "libsvm", fmt.Sprintf("format of the data (one of %q)", dataFormats))
+	labelColumn := flagSet.String("label", "", "name of the label column in CSV")
+	queryIDColumn := flagSet.String("qid", "", "name of the query ID column in CSV")
+	duration := flagSet.Duration("duration", time.Second, "minimum duration of each benchmark")
+	if ok, err := parseFlags(flagSet, args, stdout); !ok {
+		return err
+	}
+	if flagSet.NArg() > 1 {
+		return fmt.Errorf("unexpected arguments: %q", flagSet.Args()[1:])
+	}
+	if *modelPath == "" {
+		return fmt.Errorf("-model must be given")
+	}
+	if *duration <= 0 {
+		return fmt.Errorf("-duration must be positive")
+	}
+	m, err := loadModel(*modelPath, options)
+	if err != nil {
+		return err
+	}
+	s, err := m.Scorer()
+	if err != nil {
+		return err
+	}
+	input := stdin
+	if dataPath := flagSet.Arg(0); dataPath != "" && dataPath != "-" {
+		file, err := os.Open(dataPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+	}
+	reader, err := newDataReader(input, &dataOptions{
+		format:        *dataFormat,
+		labelColumn:   *labelColumn,
+		queryIDColumn: *queryIDColumn,
+		featureIDs:    m.featureIDs,
+	})
+	if err != nil {
+		return err
+	}
+	X, _, _, err := confeito.ReadAllData(reader)
+	if err != nil {
+		return err
+	}
+	if len(X) == 0 {
+		return fmt.Errorf("data must not be empty")
+	}
+	for i, x := range X {
+		score, err := s.PredictSum(x)
+		if err != nil {
+			return fmt.Errorf("data point %d: %s", i, err)
+		}
+		expected, err := m.ensemble.PredictSum(x)
+		if err != nil {
+			return fmt.Errorf("data point %d: %s", i, err)
+		}
+		if !equalScores(score, expected) {
+			return fmt.Errorf("data point %d: %s predicts %g, but trees predict %g", i, m.scorerName(), score, expected)
+		}
+	}
+	forestTime, err := benchmarkPredict(X, s.PredictSum, *duration)
+	if err != nil {
+		return err
+	}
+	treesTime, err := benchmarkPredict(X, m.ensemble.PredictSum, *duration)
+	if err != nil {
+		return err
+	}
+	w := &errWriter{w: stdout}
+	w.printf("data points: %d\n", len(X))
+	w.printf("%s: %d ns/op (%.0f ops/s)\n", m.scorerName(), forestTime.Nanoseconds(), float64(time.Second)/float64(forestTime))
+	w.printf("trees: %d ns/op (%.0f ops/s)\n", treesTime.Nanoseconds(), float64(time.Second)/float64(treesTime))
+	w.printf("speedup: %.2fx\n", float64(treesTime)/float64(forestTime))
+	return w.err
+}
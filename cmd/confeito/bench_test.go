@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestEqualScores(t *testing.T) {
+	nan := float32(math.NaN())
+	goassert.New(t, true).Equal(equalScores(1.0, 1.0))
+	goassert.New(t, true).Equal(equalScores(nan, nan))
+	goassert.New(t, true).Equal(equalScores(float32(math.Inf(1)), float32(math.Inf(1))))
+	goassert.New(t, true).Equal(equalScores(1e6, math.Nextafter32(1e6, 2e6)))
+	goassert.New(t, true).Equal(equalScores(0.0, 1e-6))
+	goassert.New(t, false).Equal(equalScores(1.0, 1.001))
+	goassert.New(t, false).Equal(equalScores(nan, 0.0))
+	goassert.New(t, false).Equal(equalScores(0.0, nan))
+	goassert.New(t, false).Equal(equalScores(float32(math.Inf(1)), float32(math.Inf(-1))))
+}
+
+func TestRunBench(t *testing.T) {
+	path := writeTestForest(t, t.TempDir(), "(feature[0] <= 0.5 ? 1 : (feature[1] < 1 ? 2 : 3))", "(feature[1] <= 0 ? 0.5 : -0.5)")
+	var stdout bytes.Buffer
+	goassert.New(t).SucceedWithoutError(runBench([]string{"-model", path, "-duration", "1ms"}, strings.NewReader("0 0:0\n0 0:1 1:0.5\n"), &stdout))
+	lines := strings.Split(stdout.String(), "\n")
+	goassert.New(t, 5).Equal(len(lines))
+	goassert.New(t, "data points: 2").Equal(lines[0])
+	for i, prefix := range []string{"Forest: ", "trees: ", "speedup: "} {
+		goassert.New(t, true).Equal(strings.HasPrefix(lines[i+1], prefix))
+	}
+
+	// CatBoost models are benchmarked with ObliviousForest.
+	stdout.Reset()
+	catboostPath := writeTestDeepCatBoostModel(t, t.TempDir())
+	goassert.New(t).SucceedWithoutError(runBench([]string{"-model", catboostPath, "-model-format", "catboost", "-duration", "1ms"}, strings.NewReader("0 0:1 6:1\n"), &stdout))
+	goassert.New(t, true).Equal(strings.Contains(stdout.String(), "\nObliviousForest: "))
+
+	goassert.New(t, "-model must be given").ExpectError(runBench(nil, strings.NewReader(""), &stdout))
+	goassert.New(t, "-duration must be positive").ExpectError(runBench([]string{"-model", path, "-duration", "0s"}, strings.NewReader(""), &stdout))
+	goassert.New(t, "unexpected arguments: [\"extra\"]").ExpectError(runBench([]string{"-model", path, "data.txt", "extra"}, strings.NewReader(""), &stdout))
+	goassert.New(t, "data must not be empty").ExpectError(runBench([]string{"-model", path}, strings.NewReader(""), &stdout))
+	goassert.New(t, "line 1: illegal label \"x\"").ExpectError(runBench([]string{"-model", path}, strings.NewReader("x 0:1\n"), &stdout))
+}
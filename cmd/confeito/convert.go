@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hiro4bbh/confeito"
+)
+
+// convertFormats is the list of the supported output model formats of convert.
+var convertFormats = []string{"native", "onnx"}
+
+// runConvert runs the subcommand convert with the arguments args, which converts the model into the output format and writes it to stdout if -o is not given.
+//
+// This function returns an error if args is illegal, or at reading, converting or writing the model.
+func runConvert(args []string, stdin io.Reader, stdout io.Writer) error {
+	flagSet := flag.NewFlagSet("confeito convert", flag.ContinueOnError)
+	flagSet.SetOutput(io.Discard)
+	modelPath, options := newModelFlags(flagSet, "class (or target) whose score is converted")
+	outputFormat := flagSet.String("to", "native", fmt.Sprintf("format of the converted model (one of %q)", convertFormats))
+	outputPath := flagSet.String("o", "", "path of the converted model (default is the standard output)")
+	if ok, err := parseFlags(flagSet, args, stdout); !ok {
+		return err
+	}
+	if flagSet.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %q", flagSet.Args())
+	}
+	if *modelPath == "" {
+		return fmt.Errorf("-model must be given")
+	}
+	m, err := loadModel(*modelPath, options)
+	if err != nil {
+		return err
+	}
+	var data []byte
+	switch *outputFormat {
+	case "native":
+		forest, err := m.Forest()
+		if err != nil {
+			return err
+		}
+		if data, err = json.Marshal(forest); err != nil {
+			return err
+		}
+	case "onnx":
+		if data, err = confeito.MarshalONNX(m.ensemble); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported output format %q (supported formats are %q)", *outputFormat, convertFormats)
+	}
+	if *outputPath == "" {
+		_, err := stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(*outputPath, data, 0644)
+}
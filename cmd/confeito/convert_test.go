@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hiro4bbh/confeito"
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestRunConvert(t *testing.T) {
+	dir := t.TempDir()
+	sklearnPath := filepath.Join(dir, "sklearn.json")
+	goassert.New(t).SucceedWithoutError(os.WriteFile(sklearnPath, []byte(testSklearnModel), 0644))
+	var stdout bytes.Buffer
+	goassert.New(t).SucceedWithoutError(runConvert([]string{"-model", sklearnPath, "-model-format", "sklearn", "-class", "1"}, nil, &stdout))
+	forest := confeito.NewForest()
+	goassert.New(t).SucceedWithoutError(json.Unmarshal(stdout.Bytes(), forest))
+	goassert.New(t, float32(0.75)).EqualWithoutError(forest.PredictSum(confeito.DenseFeatureVector{1.0}))
+
+	onnxPath := filepath.Join(dir, "model.onnx")
+	goassert.New(t).SucceedWithoutError(runConvert([]string{"-model", sklearnPath, "-model-format", "sklearn", "-class", "1", "-to", "onnx", "-o", onnxPath}, nil, &bytes.Buffer{}))
+	stdout.Reset()
+	goassert.New(t).SucceedWithoutError(runConvert([]string{"-model", onnxPath, "-model-format", "onnx"}, nil, &stdout))
+	forest = confeito.NewForest()
+	goassert.New(t).SucceedWithoutError(json.Unmarshal(stdout.Bytes(), forest))
+	goassert.New(t, float32(0.25)).EqualWithoutError(forest.PredictSum(confeito.DenseFeatureVector{0.0}))
+
+	goassert.New(t, "-model must be given").ExpectError(runConvert(nil, nil, &stdout))
+	goassert.New(t, "unexpected arguments: [\"extra\"]").ExpectError(runConvert([]string{"-model", sklearnPath, "extra"}, nil, &stdout))
+	goassert.New(t, "unsupported output format \"pmml\" (supported formats are [\"native\" \"onnx\"])").ExpectError(runConvert([]string{"-model", sklearnPath, "-model-format", "sklearn", "-to", "pmml"}, nil, &stdout))
+	goassert.New(t, sklearnPath+": unsupported model format \"h2o\" (supported formats are [\"native\" \"sklearn\" \"onnx\" \"pmml\" \"catboost\" \"lightgbm\" \"lightgbm-json\" \"xgboost-json\"])").ExpectError(runConvert([]string{"-model", sklearnPath, "-model-format", "h2o"}, nil, &stdout))
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/hiro4bbh/confeito"
+)
+
+// dataFormats is the list of the supported data formats.
+var dataFormats = []string{"libsvm", "libsvm1", "csv"}
+
+// dataOptions is the options of reading data by newDataReader.
+type dataOptions struct {
+	format        string
+	labelColumn   string
+	queryIDColumn string
+	// featureIDs is the feature IDs of the CSV columns, which is passed to confeito.CSVOptions.
+	featureIDs map[string]confeito.FeatureID
+}
+
+// newDataReader returns a new confeito.DataReader reading r with options.
+// The format libsvm has the 0-based indices, and the format libsvm1 has the 1-based indices.
+//
+// This function returns an error if the format is unsupported, or at reading the CSV header.
+func newDataReader(r io.Reader, options *dataOptions) (confeito.DataReader, error) {
+	switch options.format {
+	case "libsvm", "libsvm1":
+		reader := confeito.NewLibSVMReader(r)
+		reader.OneBased = options.format == "libsvm1"
+		return reader, nil
+	case "csv":
+		return confeito.NewCSVReader(r, &confeito.CSVOptions{
+			LabelColumn:   options.labelColumn,
+			QueryIDColumn: options.queryIDColumn,
+			FeatureIDs:    options.featureIDs,
+		})
+	default:
+		return nil, fmt.Errorf("unsupported data format %q (supported formats are %q)", options.format, dataFormats)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/hiro4bbh/confeito"
+)
+
+// modelInfo is the statistics of the trees of a model.
+type modelInfo struct {
+	ntrees, nleaves, minLeaves, maxLeaves int
+	// depths is the number of trees of each depth.
+	depths map[int]int
+	// nsplits is the number of splits on each feature.
+	nsplits map[confeito.FeatureID]int
+}
+
+// newModelInfo returns a new modelInfo of ensemble.
+func newModelInfo(ensemble *confeito.Ensemble) *modelInfo {
+	info := &modelInfo{
+		ntrees:  len(ensemble.Trees),
+		depths:  map[int]int{},
+		nsplits: map[confeito.FeatureID]int{},
+	}
+	for t, tree := range ensemble.Trees {
+		nleaves := tree.NumLeaves()
+		info.nleaves += nleaves
+		if t == 0 || info.minLeaves > nleaves {
+			info.minLeaves = nleaves
+		}
+		if t == 0 || info.maxLeaves < nleaves {
+			info.maxLeaves = nleaves
+		}
+		info.depths[tree.Depth()]++
+		tree.Walk(confeito.PreOrder, func(leaf *confeito.Leaf, path confeito.LeafPath) error {
+			if featureID, _, err := leaf.Threshold(); err == nil {
+				info.nsplits[featureID]++
+			}
+			return nil
+		})
+	}
+	return info
+}
+
+// runInfo runs the subcommand info with the arguments args, which writes the statistics of the model to stdout.
+// The statistics are the numbers of trees and terminal leaves, the histogram of the tree depths, the number of splits on each feature, and the memory footprint of the Forest of the model (see confeito.Forest.MemoryFootprint).
+//
+// This function returns an error if args is illegal, or at reading the model or writing the statistics.
+func runInfo(args []string, stdin io.Reader, stdout io.Writer) error {
+	flagSet := flag.NewFlagSet("confeito info", flag.ContinueOnError)
+	flagSet.SetOutput(io.Discard)
+	modelPath, options := newModelFlags(flagSet, "class (or target) whose trees are inspected")
+	if ok, err := parseFlags(flagSet, args, stdout); !ok {
+		return err
+	}
+	if flagSet.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %q", flagSet.Args())
+	}
+	if *modelPath == "" {
+		return fmt.Errorf("-model must be given")
+	}
+	m, err := loadModel(*modelPath, options)
+	if err != nil {
+		return err
+	}
+	info := newModelInfo(m.ensemble)
+	names := map[confeito.FeatureID]string{}
+	for name, featureID := range m.featureIDs {
+		names[featureID] = name
+	}
+	w := &errWriter{w: stdout}
+	w.printf("trees: %d\n", info.ntrees)
+	if info.ntrees > 0 {
+		w.printf("leaves: %d (min %d, max %d, mean %.2f per tree)\n", info.nleaves, info.minLeaves, info.maxLeaves, float64(info.nleaves)/float64(info.ntrees))
+	} else {
+		w.printf("leaves: 0\n")
+	}
+	depths := make([]int, 0, len(info.depths))
+	for depth := range info.depths {
+		depths = append(depths, depth)
+	}
+	sort.Ints(depths)
+	w.printf("depths:\n")
+	for _, depth := range depths {
+		w.printf("  %d: %d trees\n", depth, info.depths[depth])
+	}
+	featureIDs := make([]int, 0, len(info.nsplits))
+	for featureID := range info.nsplits {
+		featureIDs = append(featureIDs, int(featureID))
+	}
+	sort.Ints(featureIDs)
+	w.printf("features: %d used\n", len(featureIDs))
+	for _, featureID := range featureIDs {
+		if name, ok := names[confeito.FeatureID(featureID)]; ok {
+			w.printf("  feature[%d] (%s): %d splits\n", featureID, name, info.nsplits[confeito.FeatureID(featureID)])
+		} else {
+			w.printf("  feature[%d]: %d splits\n", featureID, info.nsplits[confeito.FeatureID(featureID)])
+		}
+	}
+	if forest, err := m.Forest(); err != nil {
+		w.printf("forest: unsupported: %s\n", err)
+	} else {
+		w.printf("forest: %d bytes\n", forest.MemoryFootprint())
+	}
+	return w.err
+}
+
+// errWriter is a writer keeping the first error at writing.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+// printf writes the formatted string to w.w if no error occurred.
+func (w *errWriter) printf(format string, args ...interface{}) {
+	if w.err == nil {
+		_, w.err = fmt.Fprintf(w.w, format, args...)
+	}
+}
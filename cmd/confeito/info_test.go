@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestRunInfo(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestForest(t, dir, "(feature[0] <= 0.5 ? 1 : (feature[2] < 1 ? 2 : 3))", "(feature[2] <= 0 ? 0.5 : -0.5)", "1")
+	var stdout bytes.Buffer
+	goassert.New(t).SucceedWithoutError(runInfo([]string{"-model", path}, nil, &stdout))
+	lines := strings.Split(stdout.String(), "\n")
+	goassert.New(t, []string{
+		"trees: 3",
+		"leaves: 6 (min 1, max 3, mean 2.00 per tree)",
+		"depths:",
+		"  0: 1 trees",
+		"  1: 1 trees",
+		"  2: 1 trees",
+		"features: 2 used",
+		"  feature[0]: 1 splits",
+		"  feature[2]: 2 splits",
+	}).Equal(lines[:9])
+	goassert.New(t, true).Equal(strings.HasPrefix(lines[9], "forest: ") && strings.HasSuffix(lines[9], " bytes"))
+
+	pmmlPath := filepath.Join(dir, "model.pmml")
+	goassert.New(t).SucceedWithoutError(os.WriteFile(pmmlPath, []byte(testPMMLModel), 0644))
+	stdout.Reset()
+	goassert.New(t).SucceedWithoutError(runInfo([]string{"-model", pmmlPath, "-model-format", "pmml"}, nil, &stdout))
+	goassert.New(t, true).Equal(strings.Contains(stdout.String(), "  feature[1] (b): 1 splits\n"))
+
+	goassert.New(t, "-model must be given").ExpectError(runInfo(nil, nil, &stdout))
+	goassert.New(t, "unexpected arguments: [\"extra\"]").ExpectError(runInfo([]string{"-model", path, "extra"}, nil, &stdout))
+}
@@ -0,0 +1,76 @@
+// Command confeito runs the tree ensemble models without writing Go.
+//
+// The usage is:
+//
+//	confeito <command> [arguments]
+//
+// The commands are:
+//
+//	bench      compares the throughputs of Forest and traversing each tree on data points
+//	convert    converts a model into the native model format or ONNX
+//	info       writes the statistics of a model
+//	predict    writes the predictions of a model for the data points in LibSVM or CSV
+//	serve      serves the predictions of a model over HTTP, reloading the model when it is modified
+//
+// Run "confeito <command> -h" for the arguments of each command.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// commands is the map from the name of each command to the function running it with the arguments.
+var commands = map[string]func(args []string, stdin io.Reader, stdout io.Writer) error{
+	"bench":   runBench,
+	"convert": runConvert,
+	"info":    runInfo,
+	"predict": runPredict,
+	"serve":   runServe,
+}
+
+// run runs the command given as the first argument of args (without the command name of confeito) with the rest arguments.
+//
+// This function returns an error if the command is not given or unknown, or the command fails.
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(args) == 0 {
+		return fmt.Errorf("command must be given (one of %q)", names)
+	}
+	command, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q (one of %q)", args[0], names)
+	}
+	return command(args[1:], stdin, stdout)
+}
+
+// parseFlags parses args with flagSet, and returns true if the command should run.
+// If -h or -help is given, then the usage of the flags is written to stdout, and the command should not run.
+//
+// This function returns an error if args is illegal.
+func parseFlags(flagSet *flag.FlagSet, args []string, stdout io.Writer) (bool, error) {
+	if err := flagSet.Parse(args); err != nil {
+		if err != flag.ErrHelp {
+			return false, err
+		}
+		fmt.Fprintf(stdout, "Usage of %s:\n", flagSet.Name())
+		flagSet.SetOutput(stdout)
+		flagSet.PrintDefaults()
+		return false, nil
+	}
+	return true, nil
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "confeito: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestRun(t *testing.T) {
+	path := writeTestForest(t, t.TempDir(), "(feature[0] <= 0.5 ? 1 : 2)")
+	var stdout bytes.Buffer
+	goassert.New(t).SucceedWithoutError(run([]string{"predict", "-model", path}, strings.NewReader("0 0:1\n"), &stdout))
+	goassert.New(t, "2\n").Equal(stdout.String())
+	goassert.New(t, "command must be given (one of [\"bench\" \"convert\" \"info\" \"predict\" \"serve\"])").ExpectError(run([]string{}, strings.NewReader(""), &stdout))
+	goassert.New(t, "unknown command \"score\" (one of [\"bench\" \"convert\" \"info\" \"predict\" \"serve\"])").ExpectError(run([]string{"score"}, strings.NewReader(""), &stdout))
+	// Each command writes the usage of the flags for -h without running.
+	for _, name := range []string{"bench", "convert", "info", "predict", "serve"} {
+		stdout.Reset()
+		goassert.New(t).SucceedWithoutError(run([]string{name, "-h"}, strings.NewReader(""), &stdout))
+		goassert.New(t, true).Equal(strings.HasPrefix(stdout.String(), "Usage of confeito "+name+":\n"))
+		goassert.New(t, true).Equal(strings.Contains(stdout.String(), "\n  -model string\n"))
+	}
+	goassert.New(t, "flag provided but not defined: -x").ExpectError(run([]string{"predict", "-x"}, strings.NewReader(""), &stdout))
+}
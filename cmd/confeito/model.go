@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hiro4bbh/confeito"
+)
+
+// modelFormats is the list of the supported model formats.
+var modelFormats = []string{"native", "sklearn", "onnx", "pmml", "catboost", "lightgbm", "lightgbm-json", "xgboost-json"}
+
+// scorer is the interface of the models predicting the data points, which is implemented by confeito.Forest and confeito.ObliviousForest.
+type scorer interface {
+	NumTrees() int
+	PredictSum(x confeito.FeatureVector) (float32, error)
+	PredictLeaves(x confeito.FeatureVector) ([]int, error)
+}
+
+// predictTrees returns a slice of the value predicted by each tree of s for x.
+//
+// This function returns an error at predicting.
+func predictTrees(s scorer, x confeito.FeatureVector) ([]interface{}, error) {
+	switch s := s.(type) {
+	case *confeito.Forest:
+		return s.Predict(x)
+	case *confeito.ObliviousForest:
+		values, err := s.Predict(x)
+		if err != nil {
+			return nil, err
+		}
+		trees := make([]interface{}, len(values))
+		for t, value := range values {
+			trees[t] = value
+		}
+		return trees, nil
+	default:
+		return nil, fmt.Errorf("unsupported scorer %T", s)
+	}
+}
+
+// model is a model loaded by loadModel.
+type model struct {
+	ensemble *confeito.Ensemble
+	// forest is the Forest of ensemble, which is nil until Forest is called except for the native format.
+	forest *confeito.Forest
+	// oblivious is the ObliviousForest of the format catboost, which is nil for the other formats.
+	oblivious *confeito.ObliviousForest
+	// featureIDs is the feature IDs of the named features, which is nil if the model format has no feature names.
+	featureIDs map[string]confeito.FeatureID
+}
+
+// Forest returns the Forest of m, which is built at the first call.
+//
+// This function returns an error if Forest does not support a tree of m (see confeito.Forest.Enqueue).
+func (m *model) Forest() (*confeito.Forest, error) {
+	if m.forest == nil {
+		forest, err := m.ensemble.Forest()
+		if err != nil {
+			return nil, err
+		}
+		m.forest = forest
+	}
+	return m.forest, nil
+}
+
+// Scorer returns the scorer predicting by m, which is the ObliviousForest for the format catboost, or the Forest (see Forest) otherwise.
+// The oblivious trees of CatBoost can have more terminal leaves than Forest supports.
+//
+// This function returns an error if Forest does not support a tree of m.
+func (m *model) Scorer() (scorer, error) {
+	if m.oblivious != nil {
+		return m.oblivious, nil
+	}
+	forest, err := m.Forest()
+	if err != nil {
+		return nil, err
+	}
+	return forest, nil
+}
+
+// scorerName returns the name of the scorer of m.
+func (m *model) scorerName() string {
+	if m.oblivious != nil {
+		return "ObliviousForest"
+	}
+	return "Forest"
+}
+
+// modelOptions is the options of reading models by loadModel.
+type modelOptions struct {
+	format string
+	// class is the class (or the target) whose score is predicted, which is used by the formats sklearn, onnx, catboost, lightgbm, lightgbm-json and xgboost-json.
+	class int
+	// strictMissing rejects the splits sending missing values to the right, which is used by the formats onnx, lightgbm, lightgbm-json and xgboost-json.
+	strictMissing bool
+	// baseScore and numClasses are the base score and the number of classes of the format xgboost-json, which are passed to confeito.XGBoostOptions.
+	baseScore  float64
+	numClasses int
+}
+
+// newModelFlags defines the flags of the model on flagSet, and returns the path of the model and the options set by the flags.
+// classUsage is the usage of the flag -class.
+func newModelFlags(flagSet *flag.FlagSet, classUsage string) (*string, *modelOptions) {
+	options := &modelOptions{}
+	modelPath := flagSet.String("model", "", "path of the model (required)")
+	flagSet.StringVar(&options.format, "model-format", "native", fmt.Sprintf("format of the model (one of %q)", modelFormats))
+	flagSet.IntVar(&options.class, "class", 0, classUsage)
+	flagSet.BoolVar(&options.strictMissing, "strict-missing", false, "reject the splits sending missing values to the right instead of sending them to the left")
+	flagSet.Float64Var(&options.baseScore, "xgboost-base-score", 0.0, "base score of the XGBoost model, which the JSON dump does not have")
+	flagSet.IntVar(&options.numClasses, "xgboost-num-class", 0, "number of classes of the multi-class XGBoost model")
+	return modelPath, options
+}
+
+// loadModel returns a new model read from path with options.
+// The model may have trees which Forest does not support, so Forest should be checked before prediction.
+//
+// This function returns an error if the format is unsupported, or at reading the model or building its Forest.
+func loadModel(path string, options *modelOptions) (*model, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m, err := unmarshalModel(data, options)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return m, nil
+}
+
+// unmarshalModel returns a new model decoded from data with options.
+func unmarshalModel(data []byte, options *modelOptions) (*model, error) {
+	var ensemble *confeito.Ensemble
+	var featureIDs map[string]confeito.FeatureID
+	switch options.format {
+	case "native":
+		forest := confeito.NewForest()
+		if err := json.Unmarshal(data, forest); err != nil {
+			return nil, err
+		}
+		return &model{ensemble: forest.Ensemble(), forest: forest}, nil
+	case "sklearn":
+		e, err := confeito.UnmarshalSklearnJSON(data, options.class)
+		if err != nil {
+			return nil, err
+		}
+		ensemble = e
+	case "onnx":
+		m, err := confeito.UnmarshalONNX(data, &confeito.ONNXOptions{Target: options.class, StrictMissingValueTracks: options.strictMissing})
+		if err != nil {
+			return nil, err
+		}
+		ensemble = &m.Ensemble
+	case "pmml":
+		m, err := confeito.UnmarshalPMML(data, nil)
+		if err != nil {
+			return nil, err
+		}
+		ensemble, featureIDs = &m.Ensemble, m.FeatureIDs
+	case "lightgbm", "lightgbm-json":
+		unmarshal := confeito.UnmarshalLightGBM
+		if options.format == "lightgbm-json" {
+			unmarshal = confeito.UnmarshalLightGBMJSON
+		}
+		e, err := unmarshal(data, &confeito.LightGBMOptions{Class: options.class, StrictMissingValues: options.strictMissing})
+		if err != nil {
+			return nil, err
+		}
+		ensemble = e
+	case "xgboost-json":
+		e, err := confeito.UnmarshalXGBoostJSON(data, &confeito.XGBoostOptions{
+			BaseScore:           float32(options.baseScore),
+			NumClasses:          options.numClasses,
+			Class:               options.class,
+			StrictMissingValues: options.strictMissing,
+		})
+		if err != nil {
+			return nil, err
+		}
+		ensemble = e
+	case "catboost":
+		forest, err := confeito.UnmarshalCatBoostJSON(data, options.class)
+		if err != nil {
+			return nil, err
+		}
+		return &model{ensemble: forest.Ensemble(), oblivious: forest}, nil
+	default:
+		return nil, fmt.Errorf("unsupported model format %q (supported formats are %q)", options.format, modelFormats)
+	}
+	return &model{ensemble: ensemble, featureIDs: featureIDs}, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hiro4bbh/confeito"
+	"github.com/hiro4bbh/go-assert"
+)
+
+const (
+	// testSklearnModel is a scikit-learn DecisionTreeClassifier with the split "feature[0] <= 0".
+	testSklearnModel = `{"model":"DecisionTreeClassifier","estimators":{"children_left":[1,-1,-1],"children_right":[2,-1,-1],"feature":[0,-2,-2],"threshold":[0,-2,-2],"value":[[[4,4]],[[3,1]],[[1,3]]]}}`
+	// testCatBoostModel is a CatBoost model of a single tree with the split "feature[1] <= 0" and the bias 1.
+	testCatBoostModel = `{"oblivious_trees":[{"leaf_values":[1,2],"splits":[{"border":0,"float_feature_index":1,"split_type":"FloatFeature"}]}],"scale_and_bias":[1,[1]]}`
+	// testLightGBMModel is a LightGBM model in the text model format of a single tree with the split "feature[1] <= -0.5".
+	testLightGBMModel = "tree\nnum_tree_per_iteration=1\n\nTree=0\nnum_leaves=2\nsplit_feature=1\nthreshold=-0.5\ndecision_type=2\nleft_child=-1\nright_child=-2\nleaf_value=1 2\n\nend of trees\n"
+	// testLightGBMModelJSON is testLightGBMModel dumped by Booster.dump_model.
+	testLightGBMModelJSON = `{"num_tree_per_iteration":1,"tree_info":[{"tree_index":0,"tree_structure":{"split_index":0,"split_feature":1,"threshold":-0.5,"decision_type":"<=","default_left":true,"missing_type":"None","left_child":{"leaf_value":1},"right_child":{"leaf_value":2}}}]}`
+	// testXGBoostModel is a XGBoost model dumped in JSON of a single tree with the split "f1 < -0.5" sending missing values to no.
+	testXGBoostModel = `[{"nodeid":0,"split":"f1","split_condition":-0.5,"yes":1,"no":2,"missing":2,"children":[{"nodeid":1,"leaf":1},{"nodeid":2,"leaf":2}]}]`
+	// testPMMLModel is a PMML TreeModel with the split "b < 1" on the fields a and b.
+	testPMMLModel = `<?xml version="1.0"?><PMML xmlns="http://www.dmg.org/PMML-4_4" version="4.4"><DataDictionary/>
+<TreeModel functionName="regression"><MiningSchema><MiningField name="y" usageType="target"/><MiningField name="a"/><MiningField name="b"/></MiningSchema>
+<Node><True/><Node score="-1"><SimplePredicate field="b" operator="lessThan" value="1"/></Node><Node score="1"><True/></Node></Node>
+</TreeModel></PMML>`
+)
+
+// writeTestForest writes forest of trees in the native model format to a new file in dir, and returns its path.
+func writeTestForest(t *testing.T, dir string, trees ...string) string {
+	leaves := make([]*confeito.Leaf, len(trees))
+	for i, tree := range trees {
+		leaves[i] = goassert.New(t).SucceedNew(confeito.ParseLeaf(tree)).(*confeito.Leaf)
+	}
+	forest := goassert.New(t).SucceedNew(confeito.NewForestFromTrees(leaves...)).(*confeito.Forest)
+	path := filepath.Join(dir, "model.json")
+	goassert.New(t).SucceedWithoutError(os.WriteFile(path, goassert.New(t).SucceedNew(json.Marshal(forest)).([]byte), 0644))
+	return path
+}
+
+// writeTestDeepCatBoostModel writes a CatBoost model of a single tree of depth 7 to a new file in dir, and returns its path.
+// The d-th split is "feature[d] <= 0.5", and the leaf values are the leaf indices, so Forest does not support the tree.
+func writeTestDeepCatBoostModel(t *testing.T, dir string) string {
+	splits := make([]string, 7)
+	for d := range splits {
+		splits[d] = fmt.Sprintf(`{"border":0.5,"float_feature_index":%d,"split_type":"FloatFeature"}`, d)
+	}
+	values := make([]string, 1<<uint(len(splits)))
+	for i := range values {
+		values[i] = strconv.Itoa(i)
+	}
+	data := fmt.Sprintf(`{"oblivious_trees":[{"leaf_values":[%s],"splits":[%s]}],"scale_and_bias":[1,[0]]}`, strings.Join(values, ","), strings.Join(splits, ","))
+	path := filepath.Join(dir, "catboost.json")
+	goassert.New(t).SucceedWithoutError(os.WriteFile(path, []byte(data), 0644))
+	return path
+}
+
+func TestLoadModel(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestForest(t, dir, "(feature[0] <= 0.5 ? 1 : 2)")
+	m := goassert.New(t).SucceedNew(loadModel(path, &modelOptions{format: "native"})).(*model)
+	goassert.New(t, float32(2.0)).EqualWithoutError(m.ensemble.PredictSum(confeito.DenseFeatureVector{1.0}))
+	goassert.New(t, map[string]confeito.FeatureID(nil)).Equal(m.featureIDs)
+	goassert.New(t, m.forest).EqualWithoutError(m.Forest())
+
+	m = goassert.New(t).SucceedNew(unmarshalModel([]byte(testSklearnModel), &modelOptions{format: "sklearn", class: 1})).(*model)
+	goassert.New(t, float32(0.75)).EqualWithoutError(m.ensemble.PredictSum(confeito.DenseFeatureVector{1.0}))
+	goassert.New(t, (*confeito.Forest)(nil)).Equal(m.forest)
+	forest := goassert.New(t).SucceedNew(m.Forest()).(*confeito.Forest)
+	goassert.New(t, forest).EqualWithoutError(m.Forest())
+	m = goassert.New(t).SucceedNew(unmarshalModel([]byte(testCatBoostModel), &modelOptions{format: "catboost"})).(*model)
+	goassert.New(t, float32(3.0)).EqualWithoutError(m.ensemble.PredictSum(confeito.DenseFeatureVector{0.0, 1.0}))
+	goassert.New(t, "ObliviousForest").Equal(m.scorerName())
+	// CatBoost models are scored by ObliviousForest, which supports the trees Forest does not.
+	m = goassert.New(t).SucceedNew(loadModel(writeTestDeepCatBoostModel(t, dir), &modelOptions{format: "catboost"})).(*model)
+	goassert.New(t).ExpectError(m.Forest())
+	s := goassert.New(t).SucceedNew(m.Scorer()).(scorer)
+	goassert.New(t, float32(65.0)).EqualWithoutError(s.PredictSum(confeito.DenseFeatureVector{1.0, 0.0, 0.0, 0.0, 0.0, 0.0, 1.0}))
+	goassert.New(t, []interface{}{float32(65.0), float32(0.0)}).EqualWithoutError(predictTrees(s, confeito.DenseFeatureVector{1.0, 0.0, 0.0, 0.0, 0.0, 0.0, 1.0}))
+	m = goassert.New(t).SucceedNew(unmarshalModel([]byte(testPMMLModel), &modelOptions{format: "pmml"})).(*model)
+	goassert.New(t, map[string]confeito.FeatureID{"a": 0, "b": 1}).Equal(m.featureIDs)
+	goassert.New(t, float32(1.0)).EqualWithoutError(m.ensemble.PredictSum(confeito.DenseFeatureVector{0.0, 1.0}))
+	// The exported ONNX models send missing values to the left, so they are imported also with strictMissing.
+	data := goassert.New(t).SucceedNew(confeito.MarshalONNX(m.ensemble)).([]byte)
+	m = goassert.New(t).SucceedNew(unmarshalModel(data, &modelOptions{format: "onnx", strictMissing: true})).(*model)
+	goassert.New(t, float32(1.0)).EqualWithoutError(m.ensemble.PredictSum(confeito.DenseFeatureVector{0.0, 1.0}))
+
+	m = goassert.New(t).SucceedNew(unmarshalModel([]byte(testLightGBMModel), &modelOptions{format: "lightgbm"})).(*model)
+	goassert.New(t, float32(2.0)).EqualWithoutError(m.ensemble.PredictSum(confeito.DenseFeatureVector{0.0, 1.0}))
+	m = goassert.New(t).SucceedNew(unmarshalModel([]byte(testLightGBMModelJSON), &modelOptions{format: "lightgbm-json"})).(*model)
+	goassert.New(t, float32(2.0)).EqualWithoutError(m.ensemble.PredictSum(confeito.DenseFeatureVector{0.0, 1.0}))
+	m = goassert.New(t).SucceedNew(unmarshalModel([]byte(testXGBoostModel), &modelOptions{format: "xgboost-json", baseScore: 0.5})).(*model)
+	goassert.New(t, float32(2.5)).EqualWithoutError(m.ensemble.PredictSum(confeito.DenseFeatureVector{0.0, 1.0}))
+	m = goassert.New(t).SucceedNew(unmarshalModel([]byte(`[{"nodeid":0,"leaf":1},{"nodeid":0,"leaf":2}]`), &modelOptions{format: "xgboost-json", numClasses: 2, class: 1})).(*model)
+	goassert.New(t, float32(2.0)).EqualWithoutError(m.ensemble.PredictSum(confeito.DenseFeatureVector{}))
+	// The split "feature[1] <= -0.5" sends missing values to the right in LightGBM, and the one of XGBoost sends them to no.
+	goassert.New(t, "tree 0: node 0: missing values must go to the left leaf, but missing type None with default_left=true does not").ExpectError(unmarshalModel([]byte(testLightGBMModel), &modelOptions{format: "lightgbm", strictMissing: true}))
+	goassert.New(t, "tree 0: node 0: missing values must go to the left leaf, but they go to no").ExpectError(unmarshalModel([]byte(testXGBoostModel), &modelOptions{format: "xgboost-json", strictMissing: true}))
+
+	goassert.New(t, "unsupported model format \"h2o\" (supported formats are [\"native\" \"sklearn\" \"onnx\" \"pmml\" \"catboost\" \"lightgbm\" \"lightgbm-json\" \"xgboost-json\"])").ExpectError(unmarshalModel([]byte(`{}`), &modelOptions{format: "h2o"}))
+	goassert.New(t, "estimator: node 1: class 2 is out of range [0, 2)").ExpectError(unmarshalModel([]byte(testSklearnModel), &modelOptions{format: "sklearn", class: 2}))
+	goassert.New(t).ExpectError(unmarshalModel([]byte(`{}`), &modelOptions{format: "onnx"}))
+	goassert.New(t).ExpectError(loadModel(filepath.Join(dir, "missing.json"), &modelOptions{format: "native"}))
+	goassert.New(t, path+": estimators must be given").ExpectError(loadModel(path, &modelOptions{format: "sklearn"}))
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hiro4bbh/confeito"
+)
+
+// predictBatchSize is the number of the data points scored in parallel at once.
+const predictBatchSize = 4096
+
+// predictTypes is the list of the supported prediction types.
+var predictTypes = []string{"sum", "trees", "leaves"}
+
+// formatFloat32 returns the shortest string representing v.
+func formatFloat32(v float32) string {
+	return strconv.FormatFloat(float64(v), 'g', -1, 32)
+}
+
+// newPredictFunc returns the function formatting the prediction of type predictType by s for a data point.
+//
+// This function returns an error if predictType is unsupported.
+func newPredictFunc(s scorer, predictType string) (func(x confeito.FeatureVector) (string, error), error) {
+	switch predictType {
+	case "sum":
+		return func(x confeito.FeatureVector) (string, error) {
+			score, err := s.PredictSum(x)
+			if err != nil {
+				return "", err
+			}
+			return formatFloat32(score), nil
+		}, nil
+	case "trees":
+		return func(x confeito.FeatureVector) (string, error) {
+			values, err := predictTrees(s, x)
+			if err != nil {
+				return "", err
+			}
+			fields := make([]string, len(values))
+			for t, value := range values {
+				if v, ok := value.(float32); ok {
+					fields[t] = formatFloat32(v)
+				} else {
+					fields[t] = fmt.Sprint(value)
+				}
+			}
+			return strings.Join(fields, "\t"), nil
+		}, nil
+	case "leaves":
+		return func(x confeito.FeatureVector) (string, error) {
+			leaves, err := s.PredictLeaves(x)
+			if err != nil {
+				return "", err
+			}
+			fields := make([]string, len(leaves))
+			for t, leaf := range leaves {
+				fields[t] = strconv.Itoa(leaf)
+			}
+			return strings.Join(fields, "\t"), nil
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported prediction type %q (supported types are %q)", predictType, predictTypes)
+	}
+}
+
+// predictBatch returns the predictions by fn for the data points in batch, which are computed by workers goroutines in parallel.
+//
+// This function returns the error of the first failed data point with its line number.
+func predictBatch(batch []*confeito.DataRecord, fn func(x confeito.FeatureVector) (string, error), workers int) ([]string, error) {
+	lines, errs := make([]string, len(batch)), make([]error, len(batch))
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(batch); i += workers {
+				lines[i], errs[i] = fn(batch[i].X)
+			}
+		}(w)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", batch[i].Line, err)
+		}
+	}
+	return lines, nil
+}
+
+// runPredict runs the subcommand predict with the arguments args, which reads the data from the file given as the argument or stdin, and writes the predictions to stdout line by line.
+// The predictions are the summed scores, the tab-separated values of trees, or the tab-separated indices of the terminal leaves of trees.
+//
+// This function returns an error if args is illegal, or at reading the model or the data, predicting or writing the predictions.
+func runPredict(args []string, stdin io.Reader, stdout io.Writer) error {
+	flagSet := flag.NewFlagSet("confeito predict", flag.ContinueOnError)
+	flagSet.SetOutput(io.Discard)
+	modelPath, options := newModelFlags(flagSet, "class (or target) whose score is predicted")
+	dataFormat := flagSet.String("data-format", "libsvm", fmt.Sprintf("format of the data (one of %q)", dataFormats))
+	labelColumn := flagSet.String("label", "", "name of the label column in CSV")
+	queryIDColumn := flagSet.String("qid", "", "name of the query ID column in CSV")
+	predictType := flagSet.String("type", "sum", fmt.Sprintf("type of the predictions (one of %q)", predictTypes))
+	workers := flagSet.Int("workers", runtime.NumCPU(), "number of the goroutines predicting in parallel")
+	if ok, err := parseFlags(flagSet, args, stdout); !ok {
+		return err
+	}
+	if flagSet.NArg() > 1 {
+		return fmt.Errorf("unexpected arguments: %q", flagSet.Args()[1:])
+	}
+	if *modelPath == "" {
+		return fmt.Errorf("-model must be given")
+	}
+	if *workers <= 0 {
+		return fmt.Errorf("-workers must be positive")
+	}
+	m, err := loadModel(*modelPath, options)
+	if err != nil {
+		return err
+	}
+	s, err := m.Scorer()
+	if err != nil {
+		return err
+	}
+	fn, err := newPredictFunc(s, *predictType)
+	if err != nil {
+		return err
+	}
+	input := stdin
+	if dataPath := flagSet.Arg(0); dataPath != "" && dataPath != "-" {
+		file, err := os.Open(dataPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+	}
+	reader, err := newDataReader(input, &dataOptions{
+		format:        *dataFormat,
+		labelColumn:   *labelColumn,
+		queryIDColumn: *queryIDColumn,
+		featureIDs:    m.featureIDs,
+	})
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(stdout)
+	batch := make([]*confeito.DataRecord, 0, predictBatchSize)
+	for eof := false; !eof; {
+		batch = batch[:0]
+		for len(batch) < predictBatchSize {
+			record, err := reader.Read()
+			if err == io.EOF {
+				eof = true
+				break
+			}
+			if err != nil {
+				return err
+			}
+			batch = append(batch, record)
+		}
+		lines, err := predictBatch(batch, fn, *workers)
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			if _, err := fmt.Fprintln(writer, line); err != nil {
+				return err
+			}
+		}
+	}
+	return writer.Flush()
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestRunPredict(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestForest(t, dir, "(feature[0] <= 0.5 ? 1 : (feature[1] < 1 ? 2 : 3))", "(feature[1] <= 0 ? 0.5 : -0.5)")
+	predict := func(args []string, input string) (string, error) {
+		var stdout bytes.Buffer
+		err := runPredict(append([]string{"-model", path}, args...), strings.NewReader(input), &stdout)
+		return stdout.String(), err
+	}
+	input := "# comment\n1 0:0 1:1\n0 qid:1 0:1 1:0.5\n1 0:1 1:1\n"
+	goassert.New(t, "0.5\n1.5\n2.5\n").EqualWithoutError(predict(nil, input))
+	goassert.New(t, "1\t-0.5\n2\t-0.5\n3\t-0.5\n").EqualWithoutError(predict([]string{"-type", "trees"}, input))
+	goassert.New(t, "0\t1\n1\t1\n2\t1\n").EqualWithoutError(predict([]string{"-type", "leaves", "-workers", "2"}, input))
+	goassert.New(t, "0.5\n1.5\n").EqualWithoutError(predict([]string{"-data-format", "libsvm1"}, "1 1:0 2:1\n0 1:1 2:0.5\n"))
+	goassert.New(t, "0.5\n2.5\n").EqualWithoutError(predict([]string{"-data-format", "csv", "-label", "y"}, "y,x0,x1\n1,0,1\n0,1,0\n"))
+
+	// The data points in multiple batches are predicted in order.
+	var data, expected strings.Builder
+	for i := 0; i < 2*predictBatchSize+1; i++ {
+		fmt.Fprintf(&data, "0 0:%d\n", i%2)
+		expected.WriteString([]string{"1.5\n", "2.5\n"}[i%2])
+	}
+	dataPath := filepath.Join(dir, "data.txt")
+	goassert.New(t).SucceedWithoutError(os.WriteFile(dataPath, []byte(data.String()), 0644))
+	goassert.New(t, expected.String()).EqualWithoutError(predict([]string{"-workers", "3", dataPath}, ""))
+
+	// CatBoost models are scored by ObliviousForest, whose leaf indices have the d-th bit of the d-th split.
+	catboostPath := writeTestDeepCatBoostModel(t, dir)
+	goassert.New(t, "65\n127\n").EqualWithoutError(predict([]string{"-model", catboostPath, "-model-format", "catboost"}, "0 0:1 6:1\n0 0:1 1:1 2:1 3:1 4:1 5:1 6:1\n"))
+	goassert.New(t, "65\t0\n").EqualWithoutError(predict([]string{"-model", catboostPath, "-model-format", "catboost", "-type", "trees"}, "0 0:1 6:1\n"))
+	goassert.New(t, "65\t0\n").EqualWithoutError(predict([]string{"-model", catboostPath, "-model-format", "catboost", "-type", "leaves"}, "0 0:1 6:1\n"))
+
+	// The flags of the model options are passed to the importers.
+	xgboostPath := filepath.Join(dir, "xgboost.json")
+	goassert.New(t).SucceedWithoutError(os.WriteFile(xgboostPath, []byte(testXGBoostModel), 0644))
+	goassert.New(t, "2.5\n").EqualWithoutError(predict([]string{"-model", xgboostPath, "-model-format", "xgboost-json", "-xgboost-base-score", "0.5"}, "0 1:1\n"))
+	goassert.New(t, xgboostPath+": tree 0: node 0: missing values must go to the left leaf, but they go to no").ExpectError(predict([]string{"-model", xgboostPath, "-model-format", "xgboost-json", "-strict-missing"}, ""))
+
+	goassert.New(t, "-model must be given").ExpectError(runPredict(nil, strings.NewReader(""), &bytes.Buffer{}))
+	goassert.New(t, "unexpected arguments: [\"extra\"]").ExpectError(predict([]string{dataPath, "extra"}, ""))
+	goassert.New(t, "-workers must be positive").ExpectError(predict([]string{"-workers", "0"}, ""))
+	goassert.New(t, "unsupported prediction type \"probability\" (supported types are [\"sum\" \"trees\" \"leaves\"])").ExpectError(predict([]string{"-type", "probability"}, ""))
+	goassert.New(t, "unsupported data format \"arff\" (supported formats are [\"libsvm\" \"libsvm1\" \"csv\"])").ExpectError(predict([]string{"-data-format", "arff"}, ""))
+	goassert.New(t, "line 2: illegal label \"x\"").ExpectError(predict(nil, "1 0:1\nx 0:1\n"))
+	goassert.New(t).ExpectError(predict([]string{filepath.Join(dir, "missing.txt")}, ""))
+}
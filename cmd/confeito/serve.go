@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hiro4bbh/confeito"
+)
+
+// serveMaxRequestBytes is the maximum size of the request body.
+const serveMaxRequestBytes = 32 << 20
+
+// The timeouts of reading the request header and the whole request, which drop the slow clients holding the connections.
+const (
+	serveReadHeaderTimeout = 10 * time.Second
+	serveReadTimeout       = time.Minute
+)
+
+// servedModel is a model served by server, which is never modified after loading.
+type servedModel struct {
+	scorer     scorer
+	featureIDs map[string]confeito.FeatureID
+	loadedAt   time.Time
+}
+
+// server is the HTTP handler predicting by the model read from a file, which is reloaded atomically by reload.
+// Each request uses the model at its start, so the in-flight requests finish on the old model while reloading.
+//
+// The endpoints are:
+//
+//   - POST /predict: predicts the instances in the request {"instances": [instance, ...], "type": "sum" | "trees" | "leaves"}, and responds {"predictions": [prediction, ...]}.
+//     Each instance is a dense array of feature values, or a sparse object mapping the feature IDs (or the feature names if the model has them) to the feature values.
+//     The null feature values are missing values (NaN).
+//   - GET /model: responds the information of the current model {"path": ..., "trees": ..., "loaded_at": ...}.
+//
+// The errors are responded as {"error": message}.
+type server struct {
+	path    string
+	options *modelOptions
+	logger  *log.Logger
+	model   atomic.Value
+	// mutex serializes reload, and digest is the SHA-256 digest of the file content of the current model.
+	mutex  sync.Mutex
+	digest [sha256.Size]byte
+}
+
+// newServer returns a new server of the model read from path with options (see loadModel), which logs to logger.
+//
+// This function returns an error at loading the model.
+func newServer(path string, options *modelOptions, logger *log.Logger) (*server, error) {
+	s := &server{path: path, options: options, logger: logger}
+	if _, err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// currentModel returns the model currently served by s.
+func (s *server) currentModel() *servedModel {
+	return s.model.Load().(*servedModel)
+}
+
+// reload replaces the model of s with the model read from the file if the file content differs from the one of the current model.
+// The content is compared instead of the modification time, which can miss the rewrites within its resolution.
+// The model is kept if the new model is broken, and the file is read again at the next reload.
+//
+// This function returns true if the model is replaced, or an error at loading the new model.
+func (s *server) reload() (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return false, err
+	}
+	digest := sha256.Sum256(data)
+	if s.model.Load() != nil && digest == s.digest {
+		return false, nil
+	}
+	m, err := unmarshalModel(data, s.options)
+	if err != nil {
+		return false, fmt.Errorf("%s: %s", s.path, err)
+	}
+	sc, err := m.Scorer()
+	if err != nil {
+		return false, fmt.Errorf("%s: %s", s.path, err)
+	}
+	s.model.Store(&servedModel{scorer: sc, featureIDs: m.featureIDs, loadedAt: time.Now()})
+	s.digest = digest
+	s.logger.Printf("loaded %s (%d trees)", s.path, sc.NumTrees())
+	return true, nil
+}
+
+// watch calls reload at every interval until stop is closed, and logs the errors.
+func (s *server) watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if _, err := s.reload(); err != nil {
+				s.logger.Printf("failed to reload: %s", err)
+			}
+		}
+	}
+}
+
+// serveRequest is the request body of POST /predict.
+type serveRequest struct {
+	Instances []json.RawMessage `json:"instances"`
+	Type      string            `json:"type"`
+}
+
+// parseInstance returns the feature vector of the instance in data, where the names in featureIDs are resolved in the sparse instances.
+//
+// This function returns an error if data is neither an array nor an object of feature values, or has an unknown feature.
+func parseInstance(data json.RawMessage, featureIDs map[string]confeito.FeatureID) (confeito.FeatureVector, error) {
+	switch data = bytes.TrimSpace(data); {
+	case len(data) > 0 && data[0] == '[':
+		var values []*float32
+		if err := json.Unmarshal(data, &values); err != nil {
+			return nil, err
+		}
+		x := make(confeito.DenseFeatureVector, len(values))
+		for i, value := range values {
+			x[i] = float32(math.NaN())
+			if value != nil {
+				x[i] = *value
+			}
+		}
+		return x, nil
+	case len(data) > 0 && data[0] == '{':
+		var values map[string]*float32
+		if err := json.Unmarshal(data, &values); err != nil {
+			return nil, err
+		}
+		x := make(confeito.SparseFeatureVector, 0, len(values))
+		for key, value := range values {
+			featureID, ok := featureIDs[key]
+			if !ok {
+				id, err := strconv.ParseUint(key, 10, 32)
+				if err != nil || confeito.FeatureID(id) == ^confeito.FeatureID(0) {
+					return nil, fmt.Errorf("unknown feature %q", key)
+				}
+				featureID = confeito.FeatureID(id)
+			}
+			kv := confeito.KeyValue{Key: featureID, Value: float32(math.NaN())}
+			if value != nil {
+				kv.Value = *value
+			}
+			x = append(x, kv)
+		}
+		sort.Sort(x)
+		return x, nil
+	default:
+		return nil, fmt.Errorf("instance must be an array or an object of feature values")
+	}
+}
+
+// predictInstance returns the prediction of type predictType by sc for x.
+//
+// This function returns an error if predictType is unsupported, or at predicting.
+func predictInstance(sc scorer, predictType string, x confeito.FeatureVector) (interface{}, error) {
+	switch predictType {
+	case "", "sum":
+		score, err := sc.PredictSum(x)
+		if err != nil {
+			return nil, err
+		}
+		if math.IsNaN(float64(score)) || math.IsInf(float64(score), 0) {
+			return nil, fmt.Errorf("score must be finite: %g", score)
+		}
+		return score, nil
+	case "trees":
+		return predictTrees(sc, x)
+	case "leaves":
+		return sc.PredictLeaves(x)
+	default:
+		return nil, fmt.Errorf("unsupported prediction type %q (supported types are %q)", predictType, predictTypes)
+	}
+}
+
+// writeJSON writes value in JSON with the status code to w.
+func (s *server) writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		status, data = http.StatusInternalServerError, []byte(fmt.Sprintf(`{"error":%q}`, err.Error()))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		s.logger.Printf("failed to write response: %s", err)
+	}
+}
+
+// writeError writes the error message formatted with args with the status code to w.
+func (s *server) writeError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	s.writeJSON(w, status, map[string]string{"error": fmt.Sprintf(format, args...)})
+}
+
+// ServeHTTP is for interface http.Handler.
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/predict":
+		if r.Method != http.MethodPost {
+			s.writeError(w, http.StatusMethodNotAllowed, "method must be POST")
+			return
+		}
+		s.servePredict(w, r)
+	case "/model":
+		if r.Method != http.MethodGet {
+			s.writeError(w, http.StatusMethodNotAllowed, "method must be GET")
+			return
+		}
+		m := s.currentModel()
+		s.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"path":      s.path,
+			"trees":     m.scorer.NumTrees(),
+			"loaded_at": m.loadedAt.Format(time.RFC3339Nano),
+		})
+	default:
+		s.writeError(w, http.StatusNotFound, "unknown path %q", r.URL.Path)
+	}
+}
+
+// servePredict serves POST /predict.
+func (s *server) servePredict(w http.ResponseWriter, r *http.Request) {
+	m := s.currentModel()
+	var req serveRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, serveMaxRequestBytes)).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "illegal request: %s", err)
+		return
+	}
+	predictions := make([]interface{}, len(req.Instances))
+	for i, instance := range req.Instances {
+		x, err := parseInstance(instance, m.featureIDs)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "instance %d: %s", i, err)
+			return
+		}
+		if predictions[i], err = predictInstance(m.scorer, req.Type, x); err != nil {
+			s.writeError(w, http.StatusBadRequest, "instance %d: %s", i, err)
+			return
+		}
+	}
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{"predictions": predictions})
+}
+
+// runServe runs the subcommand serve with the arguments args, which serves the model over HTTP and reloads it when the model file is modified.
+// The logs are written to stdout.
+//
+// This function returns an error if args is illegal, at loading the model, or at listening.
+func runServe(args []string, stdin io.Reader, stdout io.Writer) error {
+	flagSet := flag.NewFlagSet("confeito serve", flag.ContinueOnError)
+	flagSet.SetOutput(io.Discard)
+	modelPath, options := newModelFlags(flagSet, "class (or target) whose score is predicted")
+	addr := flagSet.String("addr", ":8080", "TCP address to listen on")
+	interval := flagSet.Duration("reload-interval", time.Second, "interval of checking whether the model file is modified")
+	if ok, err := parseFlags(flagSet, args, stdout); !ok {
+		return err
+	}
+	if flagSet.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %q", flagSet.Args())
+	}
+	if *modelPath == "" {
+		return fmt.Errorf("-model must be given")
+	}
+	if *interval <= 0 {
+		return fmt.Errorf("-reload-interval must be positive")
+	}
+	s, err := newServer(*modelPath, options, log.New(stdout, "", log.LstdFlags))
+	if err != nil {
+		return err
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go s.watch(*interval, stop)
+	s.logger.Printf("listening on %s", *addr)
+	httpServer := &http.Server{
+		Addr:              *addr,
+		Handler:           s,
+		ReadHeaderTimeout: serveReadHeaderTimeout,
+		ReadTimeout:       serveReadTimeout,
+	}
+	return httpServer.ListenAndServe()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hiro4bbh/confeito"
+	"github.com/hiro4bbh/go-assert"
+)
+
+// doServeRequest returns the status code and the body of the response of s to the request.
+func doServeRequest(t *testing.T, s http.Handler, method, path, body string) (int, string) {
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return recorder.Code, recorder.Body.String()
+}
+
+func TestServer(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestForest(t, dir, "(feature[0] <= 0.5 ? 1 : (feature[1] < 1 ? 2 : 3))", "(feature[1] <= 0 ? 0.5 : -0.5)")
+	var logs bytes.Buffer
+	s := goassert.New(t).SucceedNew(newServer(path, &modelOptions{format: "native"}, log.New(&logs, "", 0))).(*server)
+	goassert.New(t, true).Equal(strings.HasPrefix(logs.String(), "loaded "+path+" (2 trees)\n"))
+
+	request := `{"instances": [[0, 1], {"0": 1, "1": 0.5}, [1, null], {"1": null}]}`
+	goassert.New(t, http.StatusOK, `{"predictions":[0.5,1.5,2.5,1.5]}`+"\n").Equal(doServeRequest(t, s, "POST", "/predict", request))
+	request = `{"instances": [[0, 1], {"0": 1, "1": 0.5}], "type": "trees"}`
+	goassert.New(t, http.StatusOK, `{"predictions":[[1,-0.5],[2,-0.5]]}`+"\n").Equal(doServeRequest(t, s, "POST", "/predict", request))
+	request = `{"instances": [[0, 1], [1, 1]], "type": "leaves"}`
+	goassert.New(t, http.StatusOK, `{"predictions":[[0,1],[2,1]]}`+"\n").Equal(doServeRequest(t, s, "POST", "/predict", request))
+	goassert.New(t, http.StatusOK, `{"predictions":[]}`+"\n").Equal(doServeRequest(t, s, "POST", "/predict", `{"instances": []}`))
+
+	for _, c := range []struct {
+		body, err string
+	}{
+		{`[`, `illegal request: unexpected EOF`},
+		{`{"instances": [1]}`, `instance 0: instance must be an array or an object of feature values`},
+		{`{"instances": [{"x": 1}]}`, `instance 0: unknown feature \"x\"`},
+		{`{"instances": [{"4294967295": 1}]}`, `instance 0: unknown feature \"4294967295\"`},
+		{`{"instances": [[1]], "type": "probability"}`, `instance 0: unsupported prediction type \"probability\" (supported types are [\"sum\" \"trees\" \"leaves\"])`},
+	} {
+		goassert.New(t, http.StatusBadRequest, `{"error":"`+c.err+`"}`+"\n").Equal(doServeRequest(t, s, "POST", "/predict", c.body))
+	}
+	status, body := doServeRequest(t, s, "POST", "/predict", `{"instances": [[0], ["x"]]}`)
+	goassert.New(t, http.StatusBadRequest, true).Equal(status, strings.HasPrefix(body, `{"error":"instance 1: json: `))
+	goassert.New(t, http.StatusMethodNotAllowed, `{"error":"method must be POST"}`+"\n").Equal(doServeRequest(t, s, "GET", "/predict", ""))
+	goassert.New(t, http.StatusMethodNotAllowed, `{"error":"method must be GET"}`+"\n").Equal(doServeRequest(t, s, "POST", "/model", ""))
+	goassert.New(t, http.StatusNotFound, `{"error":"unknown path \"/\""}`+"\n").Equal(doServeRequest(t, s, "GET", "/", ""))
+
+	status, body = doServeRequest(t, s, "GET", "/model", "")
+	goassert.New(t, http.StatusOK).Equal(status)
+	var info map[string]interface{}
+	goassert.New(t).SucceedWithoutError(json.Unmarshal([]byte(body), &info))
+	goassert.New(t, path, 2.0).Equal(info["path"], info["trees"])
+
+	// The unmodified model is not reloaded.
+	goassert.New(t, false).EqualWithoutError(s.reload())
+	// The broken model is not served, and the model is kept.
+	old := s.currentModel()
+	goassert.New(t).SucceedWithoutError(os.WriteFile(path, []byte(`{"broken`), 0644))
+	goassert.New(t).ExpectError(s.reload())
+	goassert.New(t, old).Equal(s.currentModel())
+	goassert.New(t, http.StatusOK, `{"predictions":[0.5]}`+"\n").Equal(doServeRequest(t, s, "POST", "/predict", `{"instances": [[0, 1]]}`))
+	// The broken model is read again at the next reload, and the restored model is not reloaded.
+	goassert.New(t).ExpectError(s.reload())
+	writeTestForest(t, dir, "(feature[0] <= 0.5 ? 1 : (feature[1] < 1 ? 2 : 3))", "(feature[1] <= 0 ? 0.5 : -0.5)")
+	goassert.New(t, false).EqualWithoutError(s.reload())
+	// The modified model is reloaded, and the old model can still be used.
+	writeTestForest(t, dir, "(feature[0] <= 0.5 ? 10 : 20)")
+	goassert.New(t, true).EqualWithoutError(s.reload())
+	goassert.New(t, http.StatusOK, `{"predictions":[10]}`+"\n").Equal(doServeRequest(t, s, "POST", "/predict", `{"instances": [[0, 1]]}`))
+	goassert.New(t, float32(0.5)).EqualWithoutError(old.scorer.PredictSum(confeito.DenseFeatureVector{0.0, 1.0}))
+	// The rewrite of the same size and modification time is reloaded.
+	stat := goassert.New(t).SucceedNew(os.Stat(path)).(os.FileInfo)
+	writeTestForest(t, dir, "(feature[0] <= 0.5 ? 30 : 40)")
+	goassert.New(t).SucceedWithoutError(os.Chtimes(path, stat.ModTime(), stat.ModTime()))
+	goassert.New(t, stat.Size()).Equal(goassert.New(t).SucceedNew(os.Stat(path)).(os.FileInfo).Size())
+	goassert.New(t, true).EqualWithoutError(s.reload())
+	goassert.New(t, http.StatusOK, `{"predictions":[30]}`+"\n").Equal(doServeRequest(t, s, "POST", "/predict", `{"instances": [[0, 1]]}`))
+
+	goassert.New(t).ExpectError(newServer(filepath.Join(dir, "missing.json"), &modelOptions{format: "native"}, log.New(io.Discard, "", 0)))
+}
+
+func TestServerCatBoost(t *testing.T) {
+	// CatBoost models are served by ObliviousForest, which supports the trees Forest does not.
+	s := goassert.New(t).SucceedNew(newServer(writeTestDeepCatBoostModel(t, t.TempDir()), &modelOptions{format: "catboost"}, log.New(io.Discard, "", 0))).(*server)
+	request := `{"instances": [[1, 0, 0, 0, 0, 0, 1], {"6": 1}]}`
+	goassert.New(t, http.StatusOK, `{"predictions":[65,64]}`+"\n").Equal(doServeRequest(t, s, "POST", "/predict", request))
+	request = `{"instances": [[1, 0, 0, 0, 0, 0, 1]], "type": "trees"}`
+	goassert.New(t, http.StatusOK, `{"predictions":[[65,0]]}`+"\n").Equal(doServeRequest(t, s, "POST", "/predict", request))
+	request = `{"instances": [[1, 0, 0, 0, 0, 0, 1]], "type": "leaves"}`
+	goassert.New(t, http.StatusOK, `{"predictions":[[65,0]]}`+"\n").Equal(doServeRequest(t, s, "POST", "/predict", request))
+}
+
+func TestServerWatch(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestForest(t, dir, "(feature[0] <= 0.5 ? 1 : 2)")
+	s := goassert.New(t).SucceedNew(newServer(path, &modelOptions{format: "native"}, log.New(io.Discard, "", 0))).(*server)
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		s.watch(time.Millisecond, stop)
+		close(done)
+	}()
+	writeTestForest(t, dir, "(feature[0] <= 0.5 ? 10 : (feature[1] <= 0.5 ? 20 : 30))")
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if score, _ := s.currentModel().scorer.PredictSum(confeito.DenseFeatureVector{}); score == 10.0 {
+			break
+		}
+	}
+	goassert.New(t, float32(10.0)).EqualWithoutError(s.currentModel().scorer.PredictSum(confeito.DenseFeatureVector{}))
+	close(stop)
+	<-done
+}
+
+func TestRunServe(t *testing.T) {
+	path := writeTestForest(t, t.TempDir(), "1")
+	goassert.New(t, "-model must be given").ExpectError(runServe(nil, nil, io.Discard))
+	goassert.New(t, "unexpected arguments: [\"extra\"]").ExpectError(runServe([]string{"-model", path, "extra"}, nil, io.Discard))
+	goassert.New(t, "-reload-interval must be positive").ExpectError(runServe([]string{"-model", path, "-reload-interval", "0s"}, nil, io.Discard))
+	goassert.New(t).ExpectError(runServe([]string{"-model", path, "-addr", "illegal address"}, nil, io.Discard))
+}
@@ -0,0 +1,168 @@
+package confeito
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// CSVOptions is the options of reading CSV by CSVReader.
+type CSVOptions struct {
+	// Comma is the field delimiter.
+	// If it is 0, then ',' is used.
+	Comma rune
+	// LabelColumn is the name of the label column.
+	// If it is empty, then the labels are 0.
+	LabelColumn string
+	// QueryIDColumn is the name of the integer query ID column.
+	// If it is empty, then the data points have no query ID.
+	QueryIDColumn string
+	// FeatureIDs is the feature IDs of the feature columns, and the other columns are ignored.
+	// If it is nil, then the columns except the label and query ID columns have the feature IDs in order.
+	FeatureIDs map[string]FeatureID
+}
+
+// CSVReader reads data points in CSV having the header line from io.Reader line by line.
+// Each data point has DenseFeatureVector of the feature columns, whose dimension is the maximum feature ID plus 1.
+// The empty and "NA" feature values are missing values (NaN), and the features not in the columns are 0.
+//
+// This implements interface DataReader.
+type CSVReader struct {
+	reader                   *csv.Reader
+	labelIndex, queryIDIndex int
+	featureIndices           []int
+	featureIDs               []FeatureID
+	featureIDMap             map[string]FeatureID
+	dim                      int
+	header                   []string
+}
+
+// NewCSVReader returns a new CSVReader reading r with options, which reads the header line.
+//
+// This function returns an error if the header line is malformed or does not have a column in options.
+func NewCSVReader(r io.Reader, options *CSVOptions) (*CSVReader, error) {
+	if options == nil {
+		options = &CSVOptions{}
+	}
+	reader := &CSVReader{
+		reader:       csv.NewReader(r),
+		labelIndex:   -1,
+		queryIDIndex: -1,
+		featureIDMap: map[string]FeatureID{},
+	}
+	if options.Comma != 0 {
+		reader.reader.Comma = options.Comma
+	}
+	reader.reader.ReuseRecord = true
+	header, err := reader.reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("header line must be given")
+		}
+		return nil, err
+	}
+	reader.header = append([]string{}, header...)
+	columns := map[string]bool{}
+	for i, name := range reader.header {
+		if columns[name] {
+			return nil, fmt.Errorf("line 1: duplicated column %q", name)
+		}
+		columns[name] = true
+		switch {
+		case options.LabelColumn != "" && name == options.LabelColumn:
+			reader.labelIndex = i
+		case options.QueryIDColumn != "" && name == options.QueryIDColumn:
+			reader.queryIDIndex = i
+		default:
+			featureID, ok := FeatureID(len(reader.featureIDs)), options.FeatureIDs == nil
+			if !ok {
+				featureID, ok = options.FeatureIDs[name]
+			}
+			if !ok {
+				continue
+			}
+			if featureID == _FEATURE_ID_ILLEGAL {
+				return nil, fmt.Errorf("column %q: illegal feature ID", name)
+			}
+			reader.featureIndices, reader.featureIDs = append(reader.featureIndices, i), append(reader.featureIDs, featureID)
+			reader.featureIDMap[name] = featureID
+			if reader.dim <= int(featureID) {
+				reader.dim = int(featureID) + 1
+			}
+		}
+	}
+	if options.LabelColumn != "" && reader.labelIndex < 0 {
+		return nil, fmt.Errorf("label column %q is not found", options.LabelColumn)
+	}
+	if options.QueryIDColumn != "" && reader.queryIDIndex < 0 {
+		return nil, fmt.Errorf("query ID column %q is not found", options.QueryIDColumn)
+	}
+	for name := range options.FeatureIDs {
+		if _, ok := reader.featureIDMap[name]; !ok {
+			return nil, fmt.Errorf("feature column %q is not found", name)
+		}
+	}
+	return reader, nil
+}
+
+// FeatureIDs returns the feature IDs of the feature columns.
+func (reader *CSVReader) FeatureIDs() map[string]FeatureID {
+	featureIDs := make(map[string]FeatureID, len(reader.featureIDMap))
+	for name, featureID := range reader.featureIDMap {
+		featureIDs[name] = featureID
+	}
+	return featureIDs
+}
+
+// parseValue returns the feature value of the i-th column, where the empty and "NA" values are NaN.
+func (reader *CSVReader) parseValue(record []string, i int) (float32, error) {
+	s := record[i]
+	if s == "" || s == "NA" {
+		return float32(math.NaN()), nil
+	}
+	value, err := strconv.ParseFloat(s, 32)
+	if err != nil {
+		return 0.0, fmt.Errorf("column %q: illegal value %q", reader.header[i], s)
+	}
+	return float32(value), nil
+}
+
+// Read is for interface DataReader.
+//
+// This function returns io.EOF at the end of the input, or an error with the line number if the line is malformed.
+func (reader *CSVReader) Read() (*DataRecord, error) {
+	record, err := reader.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	line, _ := reader.reader.FieldPos(0)
+	data := &DataRecord{Line: line}
+	if reader.labelIndex >= 0 {
+		s := record[reader.labelIndex]
+		label, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: column %q: illegal label %q", line, reader.header[reader.labelIndex], s)
+		}
+		data.Label = float32(label)
+	}
+	if reader.queryIDIndex >= 0 {
+		s := record[reader.queryIDIndex]
+		queryID, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: column %q: illegal query ID %q", line, reader.header[reader.queryIDIndex], s)
+		}
+		data.QueryID, data.HasQueryID = queryID, true
+	}
+	x := make(DenseFeatureVector, reader.dim)
+	for f, i := range reader.featureIndices {
+		value, err := reader.parseValue(record, i)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		x[reader.featureIDs[f]] = value
+	}
+	data.X = x
+	return data, nil
+}
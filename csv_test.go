@@ -0,0 +1,46 @@
+package confeito
+
+import (
+	"io"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestCSVReader(t *testing.T) {
+	input := "x0,y,qid,x1\n0.5,1,3,NA\n\"-1\",0,3,\n"
+	reader := goassert.New(t).SucceedNew(NewCSVReader(strings.NewReader(input), &CSVOptions{LabelColumn: "y", QueryIDColumn: "qid"})).(*CSVReader)
+	goassert.New(t, map[string]FeatureID{"x0": 0, "x1": 1}).Equal(reader.FeatureIDs())
+	record := goassert.New(t).SucceedNew(reader.Read()).(*DataRecord)
+	goassert.New(t, &DataRecord{Line: 2, Label: 1.0, QueryID: 3, HasQueryID: true, X: record.X}).Equal(record)
+	goassert.New(t, float32(0.5)).EqualWithoutError(record.X.Get(0))
+	goassert.New(t, true).Equal(math.IsNaN(float64(record.X.(DenseFeatureVector)[1])))
+	record = goassert.New(t).SucceedNew(reader.Read()).(*DataRecord)
+	goassert.New(t, 3).Equal(record.Line)
+	goassert.New(t, float32(-1.0)).Equal(record.X.(DenseFeatureVector)[0])
+	goassert.New(t, io.EOF.Error()).ExpectError(reader.Read())
+
+	reader = goassert.New(t).SucceedNew(NewCSVReader(strings.NewReader("a;b;c\n1;2;3\n"), &CSVOptions{Comma: ';', FeatureIDs: map[string]FeatureID{"c": 0, "a": 3}})).(*CSVReader)
+	goassert.New(t, &DataRecord{Line: 2, X: DenseFeatureVector{3.0, 0.0, 0.0, 1.0}}).EqualWithoutError(reader.Read())
+
+	goassert.New(t, "header line must be given").ExpectError(NewCSVReader(strings.NewReader(""), nil))
+	goassert.New(t, `line 1: duplicated column "a"`).ExpectError(NewCSVReader(strings.NewReader("a,a\n"), nil))
+	goassert.New(t, `label column "y" is not found`).ExpectError(NewCSVReader(strings.NewReader("a\n"), &CSVOptions{LabelColumn: "y"}))
+	goassert.New(t, `query ID column "q" is not found`).ExpectError(NewCSVReader(strings.NewReader("a\n"), &CSVOptions{QueryIDColumn: "q"}))
+	goassert.New(t, `feature column "b" is not found`).ExpectError(NewCSVReader(strings.NewReader("a\n"), &CSVOptions{FeatureIDs: map[string]FeatureID{"b": 0}}))
+	goassert.New(t, `column "a": illegal feature ID`).ExpectError(NewCSVReader(strings.NewReader("a\n"), &CSVOptions{FeatureIDs: map[string]FeatureID{"a": _FEATURE_ID_ILLEGAL}}))
+
+	for _, c := range []struct {
+		input string
+		err   string
+	}{
+		{"y,q,a,note\n1,0,1,\nx,0,1,\n", `line 3: column "y": illegal label "x"`},
+		{"y,q,a,note\n1,x,1,\n", `line 2: column "q": illegal query ID "x"`},
+		{"y,q,a,note\n1,0,1,\"multi\nline\"\n1,0,x,\n", `line 4: column "a": illegal value "x"`},
+	} {
+		reader := goassert.New(t).SucceedNew(NewCSVReader(strings.NewReader(c.input), &CSVOptions{LabelColumn: "y", QueryIDColumn: "q", FeatureIDs: map[string]FeatureID{"a": 0}})).(*CSVReader)
+		goassert.New(t, c.err).ExpectError(ReadAllData(reader))
+	}
+}
@@ -0,0 +1,45 @@
+package confeito
+
+import (
+	"io"
+)
+
+// DataRecord is a data point read by DataReader.
+type DataRecord struct {
+	// Line is the line number (1-origin) of the data point in the input.
+	Line int
+	// Label is the label (the target value or the relevance) of the data point.
+	Label float32
+	// QueryID is the query ID of the data point, which is valid only if HasQueryID is true.
+	QueryID    int
+	HasQueryID bool
+	// X is the feature vector of the data point.
+	X FeatureVector
+}
+
+// DataReader is the interface for reading data points from a stream.
+// LibSVMReader and CSVReader implement this interface.
+type DataReader interface {
+	// Read returns the next data point.
+	// The function should return io.EOF at the end of the input, or an error with the line number if the input is malformed.
+	Read() (*DataRecord, error)
+}
+
+// ReadAllData returns the feature vectors, the labels and the query IDs of all data points read by reader.
+// The query ID of a data point without a query ID is 0.
+// The results can be given to the trainers like GradientBoostingTrainer.Train and LambdaMARTTrainer.Train.
+//
+// This function returns an error if reader returns an error except io.EOF.
+func ReadAllData(reader DataReader) (X []FeatureVector, labels []float32, queryIDs []int, err error) {
+	X, labels, queryIDs = []FeatureVector{}, []float32{}, []int{}
+	for {
+		record, e := reader.Read()
+		if e == io.EOF {
+			return
+		}
+		if e != nil {
+			return nil, nil, nil, e
+		}
+		X, labels, queryIDs = append(X, record.X), append(labels, record.Label), append(queryIDs, record.QueryID)
+	}
+}
@@ -0,0 +1,22 @@
+package confeito
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestReadAllData(t *testing.T) {
+	X, labels, queryIDs, err := ReadAllData(NewLibSVMReader(strings.NewReader("1 qid:2 0:1\n0 1:2\n")))
+	goassert.New(t).SucceedWithoutError(err)
+	goassert.New(t, []FeatureVector{SparseFeatureVector{{0, 1.0}}, SparseFeatureVector{{1, 2.0}}}).Equal(X)
+	goassert.New(t, []float32{1.0, 0.0}).Equal(labels)
+	goassert.New(t, []int{2, 0}).Equal(queryIDs)
+
+	X, labels, queryIDs, err = ReadAllData(NewLibSVMReader(strings.NewReader("")))
+	goassert.New(t).SucceedWithoutError(err)
+	goassert.New(t, []FeatureVector{}, []float32{}, []int{}).Equal(X, labels, queryIDs)
+
+	goassert.New(t, `line 2: illegal label "x"`).ExpectError(ReadAllData(NewLibSVMReader(strings.NewReader("1 0:1\nx\n"))))
+}
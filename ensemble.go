@@ -0,0 +1,49 @@
+package confeito
+
+import (
+	"fmt"
+)
+
+// Ensemble is a weighted sum of trees, which is imported from the other libraries.
+// The score of the ensemble is the sum of the values predicted by Trees[t] weighted by Weights[t].
+type Ensemble struct {
+	Trees   []*Leaf
+	Weights []float32
+}
+
+// Forest returns a new Forest of ensemble, where each tree has the base weight in Weights (see EnqueueWeighted).
+//
+// This function returns an error if the numbers of Trees and Weights differ, or Forest does not support a tree (see Forest.Enqueue).
+func (ensemble *Ensemble) Forest() (*Forest, error) {
+	if len(ensemble.Trees) != len(ensemble.Weights) {
+		return nil, fmt.Errorf("the number of weights must be equal to the number of trees")
+	}
+	forest := NewForest()
+	if err := forest.enqueue(ensemble.Trees, ensemble.Weights); err != nil {
+		return nil, err
+	}
+	return forest, nil
+}
+
+// PredictSum returns the score of ensemble for x in the same way as Forest.PredictSum.
+// This supports trees having more than 64 terminal leaves, which Forest does not support.
+//
+// This function returns an error if the numbers of Trees and Weights differ, a predicted value is not float32, or at getting feature values of x.
+func (ensemble *Ensemble) PredictSum(x FeatureVector) (float32, error) {
+	if len(ensemble.Trees) != len(ensemble.Weights) {
+		return 0.0, fmt.Errorf("the number of weights must be equal to the number of trees")
+	}
+	sum := float32(0.0)
+	for t, tree := range ensemble.Trees {
+		value, err := tree.Predict(x)
+		if err != nil {
+			return 0.0, err
+		}
+		fvalue, ok := value.(float32)
+		if !ok {
+			return 0.0, fmt.Errorf("value of tree %d must be float32: %#v", t, value)
+		}
+		sum += ensemble.Weights[t] * fvalue
+	}
+	return sum, nil
+}
@@ -0,0 +1,30 @@
+package confeito
+
+import (
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestEnsemble(t *testing.T) {
+	x := DenseFeatureVector{-2.0, -1.0, 0.0, 1.0, 2.0}
+	tree1 := goassert.New(t).SucceedNew(NewLeaf(0, -2.5, float32(1.0), float32(2.0))).(*Leaf)
+	tree2 := goassert.New(t).SucceedNew(NewTerminalLeaf(float32(4.0))).(*Leaf)
+	ensemble := &Ensemble{
+		Trees:   []*Leaf{tree1, tree2},
+		Weights: []float32{0.5, 0.25},
+	}
+	goassert.New(t, float32(2.0)).EqualWithoutError(ensemble.PredictSum(x))
+	forest := goassert.New(t).SucceedNew(ensemble.Forest()).(*Forest)
+	goassert.New(t, []float32{0.5, 0.25}).Equal(forest.Weights())
+	goassert.New(t, float32(2.0)).EqualWithoutError(forest.PredictSum(x))
+	// Ensemble supports the trees which Forest does not support.
+	ensemble.Trees[1] = newTooDeepTree(t)
+	goassert.New(t).SucceedNew(ensemble.PredictSum(x))
+	goassert.New(t, "the number of leaves in the tree must not be greater than 64").ExpectError(ensemble.Forest())
+	ensemble.Weights = ensemble.Weights[:1]
+	goassert.New(t, "the number of weights must be equal to the number of trees").ExpectError(ensemble.PredictSum(x))
+	goassert.New(t, "the number of weights must be equal to the number of trees").ExpectError(ensemble.Forest())
+	ensemble.Trees = []*Leaf{goassert.New(t).SucceedNew(NewTerminalLeaf("a")).(*Leaf)}
+	goassert.New(t, "value of tree 0 must be float32: \"a\"").ExpectError(ensemble.PredictSum(x))
+}
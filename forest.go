@@ -2,17 +2,37 @@ package confeito
 
 import (
 	"fmt"
+	"math"
 	"math/bits"
 	"sort"
+	"sync"
+	"unsafe"
 )
 
+// ForestMaxLeaves is the maximum number of terminal leaves in a tree which Forest supports.
+const ForestMaxLeaves = 64
+
+// forestFeature has the entries of the splits on a feature, which are sorted in the order of applying them to the feature value (see less).
 // This type implements interface sort.Interface.
 type forestFeature struct {
 	thresholds []float32
+	operators  []SplitOperator
 	treeIDs    []int
 	bvs        []uint64
 }
 
+// applies returns true if the p-th entry of ff takes the right leaf for value, otherwise false.
+func (ff *forestFeature) applies(p int, value float32) bool {
+	return ff.thresholds[p] < value || (ff.thresholds[p] == value && ff.operators[p] == Less)
+}
+
+// less returns true if the entry (threshold1, operator1) is applied before the entry (threshold2, operator2).
+// The entry with operator Less is applied at value equal to the threshold, so it precedes the entry with LessOrEqual having the same threshold.
+// Thus, the entries applied to any value are always a prefix of the sorted entries.
+func (ff *forestFeature) less(threshold1 float32, operator1 SplitOperator, threshold2 float32, operator2 SplitOperator) bool {
+	return threshold1 < threshold2 || (threshold1 == threshold2 && operator1 == Less && operator2 != Less)
+}
+
 // See sort.Interface.
 func (ff *forestFeature) Len() int {
 	return len(ff.thresholds)
@@ -20,20 +40,69 @@ func (ff *forestFeature) Len() int {
 
 // See sort.Interface.
 func (ff *forestFeature) Less(i, j int) bool {
-	return ff.thresholds[i] < ff.thresholds[j]
+	return ff.less(ff.thresholds[i], ff.operators[i], ff.thresholds[j], ff.operators[j])
 }
 
 // See sort.Interface.
 func (ff *forestFeature) Swap(i, j int) {
 	ff.thresholds[i], ff.thresholds[j] = ff.thresholds[j], ff.thresholds[i]
+	ff.operators[i], ff.operators[j] = ff.operators[j], ff.operators[i]
 	ff.treeIDs[i], ff.treeIDs[j] = ff.treeIDs[j], ff.treeIDs[i]
 	ff.bvs[i], ff.bvs[j] = ff.bvs[j], ff.bvs[i]
 }
 
+// merge merges the sorted entries of other into the sorted entries of ff in linear time.
+func (ff *forestFeature) merge(other *forestFeature) {
+	n := ff.Len() + other.Len()
+	thresholds, operators, treeIDs, bvs := make([]float32, 0, n), make([]SplitOperator, 0, n), make([]int, 0, n), make([]uint64, 0, n)
+	p, q := 0, 0
+	for p < ff.Len() || q < other.Len() {
+		if q == other.Len() || (p < ff.Len() && !ff.less(other.thresholds[q], other.operators[q], ff.thresholds[p], ff.operators[p])) {
+			thresholds, operators, treeIDs, bvs = append(thresholds, ff.thresholds[p]), append(operators, ff.operators[p]), append(treeIDs, ff.treeIDs[p]), append(bvs, ff.bvs[p])
+			p++
+		} else {
+			thresholds, operators, treeIDs, bvs = append(thresholds, other.thresholds[q]), append(operators, other.operators[q]), append(treeIDs, other.treeIDs[q]), append(bvs, other.bvs[q])
+			q++
+		}
+	}
+	ff.thresholds, ff.operators, ff.treeIDs, ff.bvs = thresholds, operators, treeIDs, bvs
+}
+
+// forestTree has the base weight and the terminal leaf values of a tree in Forest.
+// The tree structure is not kept, and it is rebuilt from the entries on demand (see rebuildTrees).
 type forestTree struct {
+	weight float32
 	values []interface{}
 }
 
+// forestSplit is a non-terminal leaf of a tree rebuilt from an entry of forestFeature.
+// The left leaves of the split have the leaf IDs in [lo, hi), where the leaves are numbered from the rightmost one.
+type forestSplit struct {
+	featureID FeatureID
+	threshold float32
+	operator  SplitOperator
+	lo        int
+}
+
+// rebuildLeaf returns a new tree having the leaves with the leaf IDs in [lo, hi) of tree, where splits has the splits of tree for each hi sorted in ascending order of lo.
+// The split having the leaves in [lo, hi) is the one whose left leaves are [lo', hi) with the smallest lo' > lo, because the splits in its left subtree have the larger lo' and its ancestors have lo' <= lo.
+func (tree *forestTree) rebuildLeaf(splits map[int][]forestSplit, lo, hi int) *Leaf {
+	if hi-lo == 1 {
+		return &Leaf{featureID: _FEATURE_ID_TERMINAL_LEAF, value: tree.values[lo]}
+	}
+	candidates := splits[hi]
+	split := candidates[sort.Search(len(candidates), func(k int) bool {
+		return candidates[k].lo > lo
+	})]
+	return &Leaf{
+		featureID: split.featureID,
+		threshold: split.threshold,
+		operator:  split.operator,
+		left:      tree.rebuildLeaf(splits, split.lo, hi),
+		right:     tree.rebuildLeaf(splits, lo, split.lo),
+	}
+}
+
 // Forest is a ensemble of tree (*Leaf).
 // This is designed to compact and fast online prediction.
 // Thus, there is no way to modify each tree, and users can enqueue/dequeue an tree, or get predicted values.
@@ -41,54 +110,226 @@ type forestTree struct {
 // Result of prediction is slice of the value predicted by each tree.
 // This design enables users to use the predicted values for estimators weighted arbitrarily.
 //
+// A bounded Forest (see NewBoundedForest) keeps at most a fixed number of trees as a sliding window, and evicts the oldest trees on Enqueue.
+// Also, each tree can be weighted by its base weight (see EnqueueWeighted) and by its age with the decay rate (see SetDecay and Weights).
+//
+// All methods of Forest are safe for concurrent use.
+//
 // NOTICE: Currently, Forest supports only trees having at most 64 terminal leaves.
 type Forest struct {
-	ntrees   int
+	mutex    sync.RWMutex
+	capacity int
+	decay    float32
 	features map[FeatureID]*forestFeature
 	trees    []*forestTree
+	// weights is the cache of Weights, which is updated by updateWeights whenever the trees or the decay rate are modified.
+	weights []float32
 }
 
 // NewForest returns a new empty Forest.
 func NewForest() *Forest {
 	return &Forest{
-		ntrees:   0,
+		capacity: 0,
+		decay:    1.0,
 		features: make(map[FeatureID]*forestFeature),
 		trees:    []*forestTree{},
+		weights:  []float32{},
 	}
 }
 
-// Dequeue dequeues the first enqueued tree from forest.
+// NewBoundedForest returns a new empty Forest holding at most capacity trees.
+// Enqueue on the full forest evicts the oldest trees at once.
 //
-// This would be too slow because the implementation is not designed for frequent dequeues.
-func (forest *Forest) Dequeue() {
-	if len(forest.trees) == 0 {
-		return
+// This function returns an error if capacity is not positive.
+func NewBoundedForest(capacity int) (*Forest, error) {
+	if capacity <= 0 {
+		return nil, fmt.Errorf("capacity must be positive")
 	}
+	forest := NewForest()
+	forest.capacity = capacity
+	return forest, nil
+}
+
+// Capacity returns the maximum number of trees in forest.
+// If forest is not bounded, then this returns 0.
+func (forest *Forest) Capacity() int {
+	return forest.capacity
+}
+
+// Decay returns the decay rate of the tree weights.
+func (forest *Forest) Decay() float32 {
+	forest.mutex.RLock()
+	defer forest.mutex.RUnlock()
+	return forest.decay
+}
+
+// SetDecay sets the decay rate of the tree weights.
+// The weight of a tree is weight*decay^age, where weight is the base weight given at enqueuing, and the age of the last enqueued tree is 0.
+// The default decay rate is 1.0, which means that every tree has its base weight.
+//
+// This function returns an error if decay is not in (0.0, 1.0].
+func (forest *Forest) SetDecay(decay float32) error {
+	if !(0.0 < decay && decay <= 1.0) {
+		return fmt.Errorf("decay must be in (0.0, 1.0]")
+	}
+	forest.mutex.Lock()
+	defer forest.mutex.Unlock()
+	forest.decay = decay
+	forest.updateWeights()
+	return nil
+}
+
+// NumTrees returns the number of trees in forest.
+func (forest *Forest) NumTrees() int {
+	forest.mutex.RLock()
+	defer forest.mutex.RUnlock()
+	return len(forest.trees)
+}
+
+// Tree returns a copy of the i-th tree of forest in the same order as Predict.
+//
+// This function returns an error if i is out of range.
+func (forest *Forest) Tree(i int) (*Leaf, error) {
+	forest.mutex.RLock()
+	defer forest.mutex.RUnlock()
+	if !(0 <= i && i < len(forest.trees)) {
+		return nil, fmt.Errorf("tree index %d is out of range [0, %d)", i, len(forest.trees))
+	}
+	return forest.rebuildTrees(i, i+1)[0], nil
+}
+
+// rebuildTrees returns the new trees of forest in [begin, end) rebuilt from the entries and the terminal leaf values.
+// The entries clear the left leaves of their splits (see registerLeaf), which determine the tree structures.
+func (forest *Forest) rebuildTrees(begin, end int) []*Leaf {
+	splits := make([]map[int][]forestSplit, end-begin)
+	for t := range splits {
+		splits[t] = map[int][]forestSplit{}
+	}
+	for featureID, feature := range forest.features {
+		for p, treeID := range feature.treeIDs {
+			if !(begin <= treeID && treeID < end) {
+				continue
+			}
+			mask := ^feature.bvs[p]
+			lo := bits.TrailingZeros64(mask)
+			hi := lo + bits.OnesCount64(mask)
+			splits[treeID-begin][hi] = append(splits[treeID-begin][hi], forestSplit{
+				featureID: featureID,
+				threshold: feature.thresholds[p],
+				operator:  feature.operators[p],
+				lo:        lo,
+			})
+		}
+	}
+	trees := make([]*Leaf, end-begin)
+	for t := range trees {
+		for _, candidates := range splits[t] {
+			sort.Slice(candidates, func(k, l int) bool { return candidates[k].lo < candidates[l].lo })
+		}
+		tree := forest.trees[begin+t]
+		trees[t] = tree.rebuildLeaf(splits[t], 0, len(tree.values))
+	}
+	return trees
+}
+
+// Ensemble returns a new Ensemble having the copies of the trees of forest with Weights in the same order as Predict.
+func (forest *Forest) Ensemble() *Ensemble {
+	forest.mutex.RLock()
+	defer forest.mutex.RUnlock()
+	return &Ensemble{
+		Trees:   forest.rebuildTrees(0, len(forest.trees)),
+		Weights: append([]float32{}, forest.weights...),
+	}
+}
+
+// MemoryFootprint returns the approximate number of bytes allocated for the entries, the terminal leaf values and the weights of forest.
+func (forest *Forest) MemoryFootprint() int {
+	forest.mutex.RLock()
+	defer forest.mutex.RUnlock()
+	size := 0
 	for _, feature := range forest.features {
-		for p := 0; p < len(feature.treeIDs); {
-			if feature.treeIDs[p] == 0 {
-				feature.thresholds = append(feature.thresholds[:p], feature.thresholds[p+1:]...)
-				feature.treeIDs = append(feature.treeIDs[:p], feature.treeIDs[p+1:]...)
-				feature.bvs = append(feature.bvs[:p], feature.bvs[p+1:]...)
-			} else {
-				feature.treeIDs[p]--
-				p++
+		size += cap(feature.thresholds)*int(unsafe.Sizeof(float32(0))) + cap(feature.operators)*int(unsafe.Sizeof(SplitOperator(0))) + cap(feature.treeIDs)*int(unsafe.Sizeof(int(0))) + cap(feature.bvs)*int(unsafe.Sizeof(uint64(0)))
+	}
+	for _, tree := range forest.trees {
+		size += int(unsafe.Sizeof(*tree)) + cap(tree.values)*int(unsafe.Sizeof(interface{}(nil)))
+	}
+	size += cap(forest.trees)*int(unsafe.Sizeof((*forestTree)(nil))) + cap(forest.weights)*int(unsafe.Sizeof(float32(0)))
+	return size
+}
+
+// Weights returns a slice of the weight of each tree of forest in the same order as Predict.
+// See SetDecay for the definition of the weights.
+func (forest *Forest) Weights() []float32 {
+	forest.mutex.RLock()
+	defer forest.mutex.RUnlock()
+	return append([]float32{}, forest.weights...)
+}
+
+// updateWeights updates the cache of Weights, which must be called whenever the trees or the decay rate are modified.
+func (forest *Forest) updateWeights() {
+	weights := make([]float32, len(forest.trees))
+	for t := range weights {
+		age := len(weights) - 1 - t
+		weights[t] = forest.trees[t].weight * float32(math.Pow(float64(forest.decay), float64(age)))
+	}
+	forest.weights = weights
+}
+
+// dequeue removes the first n enqueued trees from forest in a single pass over the features.
+func (forest *Forest) dequeue(n int) {
+	if n > len(forest.trees) {
+		n = len(forest.trees)
+	}
+	if n <= 0 {
+		return
+	}
+	for featureID, feature := range forest.features {
+		q := 0
+		for p := 0; p < len(feature.treeIDs); p++ {
+			if feature.treeIDs[p] < n {
+				continue
 			}
+			feature.thresholds[q] = feature.thresholds[p]
+			feature.operators[q] = feature.operators[p]
+			feature.treeIDs[q] = feature.treeIDs[p] - n
+			feature.bvs[q] = feature.bvs[p]
+			q++
 		}
+		if q == 0 {
+			// The features used by no remaining tree are removed, so Predict does not scan them.
+			delete(forest.features, featureID)
+			continue
+		}
+		feature.thresholds = feature.thresholds[:q]
+		feature.operators = feature.operators[:q]
+		feature.treeIDs = feature.treeIDs[:q]
+		feature.bvs = feature.bvs[:q]
 	}
-	forest.trees = forest.trees[1:]
+	forest.trees = forest.trees[n:]
+	forest.updateWeights()
 }
 
-func (forest *Forest) registerLeaf(leaf *Leaf, treeID int) (nleft, nright int, err error) {
+// Dequeue dequeues the first enqueued tree from forest.
+//
+// This would be too slow because the implementation is not designed for frequent dequeues.
+func (forest *Forest) Dequeue() {
+	forest.mutex.Lock()
+	defer forest.mutex.Unlock()
+	forest.dequeue(1)
+}
+
+// registerLeaf appends the entries of the leaves under leaf of tree to the unsorted features.
+func (forest *Forest) registerLeaf(features map[FeatureID]*forestFeature, tree *forestTree, leaf *Leaf, treeID int) (nleft, nright int, err error) {
 	if leaf.IsTerminal() {
 		value, _ := leaf.Value()
-		tree := forest.trees[treeID]
 		tree.values = append(tree.values, value)
 		nleft = 1
 		return
 	}
+	// The leaves are numbered from the rightmost one, so the leaves under leaf start at offset.
+	offset := len(tree.values)
 	if rightLeaf := leaf.Right(); rightLeaf != nil {
-		nleftAtRight, nrightAtRight, e := forest.registerLeaf(rightLeaf, treeID)
+		nleftAtRight, nrightAtRight, e := forest.registerLeaf(features, tree, rightLeaf, treeID)
 		if e != nil {
 			err = e
 			return
@@ -96,7 +337,7 @@ func (forest *Forest) registerLeaf(leaf *Leaf, treeID int) (nleft, nright int, e
 		nright += nleftAtRight + nrightAtRight
 	}
 	if leftLeaf := leaf.Left(); leftLeaf != nil {
-		nleftAtLeft, nrightAtLeft, e := forest.registerLeaf(leftLeaf, treeID)
+		nleftAtLeft, nrightAtLeft, e := forest.registerLeaf(features, tree, leftLeaf, treeID)
 		if e != nil {
 			err = e
 			return
@@ -104,56 +345,148 @@ func (forest *Forest) registerLeaf(leaf *Leaf, treeID int) (nleft, nright int, e
 		nleft += nleftAtLeft + nrightAtLeft
 	}
 	nleaves := nleft + nright
-	if nleaves > 64 {
+	if nleaves > ForestMaxLeaves {
 		err = fmt.Errorf("the number of leaves in the tree must not be greater than 64")
 		return
 	}
 	featureID, threshold, _ := leaf.Threshold()
-	feature, ok := forest.features[featureID]
+	feature, ok := features[featureID]
 	if !ok {
 		feature = &forestFeature{
 			thresholds: []float32{},
+			operators:  []SplitOperator{},
 			treeIDs:    []int{},
 			bvs:        []uint64{},
 		}
-		forest.features[featureID] = feature
+		features[featureID] = feature
 	}
 	feature.thresholds = append(feature.thresholds, threshold)
+	feature.operators = append(feature.operators, leaf.operator)
 	feature.treeIDs = append(feature.treeIDs, treeID)
-	bv := ((^uint64(0)) & ^((1 << uint64(nleaves)) - 1)) | (((1 << uint64(nleaves)) | (1 << uint64(nright))) - 1)
+	// The bit vector clears the left leaves, which are false if the split takes the right leaf.
+	bv := ^(((uint64(1) << uint(nleft)) - 1) << uint(offset+nright))
 	feature.bvs = append(feature.bvs, bv)
 	return
 }
 
-func (forest *Forest) registerTree(treeRoot *Leaf) error {
+// registerTree returns a new forestTree of treeRoot with weight and treeID, and appends its entries to the unsorted features.
+// forest is not modified.
+func (forest *Forest) registerTree(features map[FeatureID]*forestFeature, treeRoot *Leaf, weight float32, treeID int) (*forestTree, error) {
 	tree := &forestTree{
+		weight: weight,
 		values: []interface{}{},
 	}
-	treeID := len(forest.trees)
-	forest.trees = append(forest.trees, tree)
-	_, _, err := forest.registerLeaf(treeRoot, treeID)
-	return err
+	if _, _, err := forest.registerLeaf(features, tree, treeRoot, treeID); err != nil {
+		return nil, err
+	}
+	return tree, nil
 }
 
-// Enqueue enqueues the given trees to forest in order.
+// Enqueue enqueues the given trees with base weight 1.0 to forest in order.
+// If forest is bounded, then the oldest trees exceeding the capacity are evicted.
+//
+// Enqueue is atomic, that is, forest is not modified at all if this returns an error.
 //
-// This function returns an error if the number of leaves in tree is greater than 64.
+// This function returns a *LeafError if a tree is malformed (see Leaf.Validate), or an error if the number of leaves in tree is greater than 64.
 func (forest *Forest) Enqueue(trees ...*Leaf) error {
+	return forest.EnqueueWeighted(1.0, trees...)
+}
+
+// EnqueueWeighted enqueues the given trees with base weight to forest in order, like Enqueue.
+// The base weight scales the predicted value of each tree in PredictSum (see Weights), which is useful for averaging ensembles and boosting with the learning rate.
+//
+// This function returns an error if weight is not finite, or Enqueue returns an error.
+func (forest *Forest) EnqueueWeighted(weight float32, trees ...*Leaf) error {
+	weights := make([]float32, len(trees))
+	for t := range weights {
+		weights[t] = weight
+	}
+	return forest.enqueue(trees, weights)
+}
+
+// enqueue enqueues trees[t] with base weight weights[t] to forest in order atomically.
+func (forest *Forest) enqueue(trees []*Leaf, weights []float32) error {
+	for _, weight := range weights {
+		if math.IsNaN(float64(weight)) || math.IsInf(float64(weight), 0) {
+			return fmt.Errorf("weight must be finite")
+		}
+	}
 	for _, tree := range trees {
-		if err := forest.registerTree(tree); err != nil {
+		if err := tree.Validate(); err != nil {
 			return err
 		}
+		if tree.NumLeaves() > ForestMaxLeaves {
+			return fmt.Errorf("the number of leaves in the tree must not be greater than 64")
+		}
 	}
-	for _, feature := range forest.features {
-		sort.Sort(feature)
+	forest.mutex.Lock()
+	defer forest.mutex.Unlock()
+	// The new trees are registered to the new features, which are committed to forest only if all trees are registered.
+	features := make(map[FeatureID]*forestFeature)
+	newTrees := make([]*forestTree, 0, len(trees))
+	for t, tree := range trees {
+		newTree, err := forest.registerTree(features, tree, weights[t], len(forest.trees)+len(newTrees))
+		if err != nil {
+			return err
+		}
+		newTrees = append(newTrees, newTree)
+	}
+	forest.trees = append(forest.trees, newTrees...)
+	// Only the features used by the new trees are sorted and merged into the existing ones.
+	for featureID, newFeature := range features {
+		sort.Sort(newFeature)
+		if feature, ok := forest.features[featureID]; ok {
+			feature.merge(newFeature)
+		} else {
+			forest.features[featureID] = newFeature
+		}
+	}
+	forest.updateWeights()
+	if forest.capacity > 0 {
+		forest.dequeue(len(forest.trees) - forest.capacity)
 	}
 	return nil
 }
 
+// NewForestFromTrees returns a new Forest compiled from the given trees at once.
+// This sorts the entries of each feature only once, so it is faster than enqueuing the trees one by one.
+//
+// This function returns a *LeafError if a tree is malformed (see Leaf.Validate), or an error if the number of leaves in tree is greater than 64.
+func NewForestFromTrees(trees ...*Leaf) (*Forest, error) {
+	forest := NewForest()
+	if err := forest.Enqueue(trees...); err != nil {
+		return nil, err
+	}
+	return forest, nil
+}
+
 // Predict returns a slice of the value predicted by each tree of forest.
 //
 // This function returns an error at getting feature values of x.
 func (forest *Forest) Predict(x FeatureVector) ([]interface{}, error) {
+	forest.mutex.RLock()
+	defer forest.mutex.RUnlock()
+	return forest.predict(x)
+}
+
+// PredictLeaves returns a slice of the index of the terminal leaf predicted by each tree of forest.
+// The terminal leaves in each tree are indexed from the leftmost one in the depth-first order.
+//
+// This function returns an error at getting feature values of x.
+func (forest *Forest) PredictLeaves(x FeatureVector) ([]int, error) {
+	forest.mutex.RLock()
+	defer forest.mutex.RUnlock()
+	bvs := forest.predictBitVectors(x)
+	leaves := make([]int, len(forest.trees))
+	for t, tree := range forest.trees {
+		// The leaves are numbered from the rightmost one in forestTree (see registerLeaf).
+		leaves[t] = len(tree.values) - bits.Len64(bvs[t])
+	}
+	return leaves, nil
+}
+
+// predictBitVectors returns the bit vector of each tree of forest for x, whose highest set bit is the predicted terminal leaf.
+func (forest *Forest) predictBitVectors(x FeatureVector) []uint64 {
 	bvs := make([]uint64, len(forest.trees))
 	for t := 0; t < len(bvs); t++ {
 		bvs[t] = (1 << uint64(len(forest.trees[t].values))) - 1
@@ -163,7 +496,7 @@ func (forest *Forest) Predict(x FeatureVector) ([]interface{}, error) {
 		left, right := 0, len(feature.thresholds)
 		for left < right {
 			middle := (left + right) / 2
-			if feature.thresholds[middle] < featureValue {
+			if feature.applies(middle, featureValue) {
 				left = middle + 1
 			} else {
 				right = middle
@@ -174,6 +507,11 @@ func (forest *Forest) Predict(x FeatureVector) ([]interface{}, error) {
 			bvs[treeID] &= feature.bvs[p]
 		}
 	}
+	return bvs
+}
+
+func (forest *Forest) predict(x FeatureVector) ([]interface{}, error) {
+	bvs := forest.predictBitVectors(x)
 	values := make([]interface{}, len(forest.trees))
 	for t, tree := range forest.trees {
 		leafID := bits.Len64(bvs[t]) - 1
@@ -181,3 +519,24 @@ func (forest *Forest) Predict(x FeatureVector) ([]interface{}, error) {
 	}
 	return values, nil
 }
+
+// PredictSum returns the sum of the values predicted by each tree of forest weighted by Weights.
+//
+// This function returns an error if a predicted value is not float32, or at getting feature values of x.
+func (forest *Forest) PredictSum(x FeatureVector) (float32, error) {
+	forest.mutex.RLock()
+	defer forest.mutex.RUnlock()
+	values, err := forest.predict(x)
+	if err != nil {
+		return 0.0, err
+	}
+	sum := float32(0.0)
+	for t, weight := range forest.weights {
+		value, ok := values[t].(float32)
+		if !ok {
+			return 0.0, fmt.Errorf("value of tree %d must be float32: %#v", t, values[t])
+		}
+		sum += weight * value
+	}
+	return sum, nil
+}
@@ -0,0 +1,74 @@
+package confeito
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// forestJSON is the JSON schema of Forest, which is the native model format of this package.
+type forestJSON struct {
+	Capacity int       `json:"capacity,omitempty"`
+	Decay    float32   `json:"decay"`
+	Trees    []*Leaf   `json:"trees"`
+	Weights  []float32 `json:"weights,omitempty"`
+}
+
+// MarshalJSON is for interface json.Marshaler.
+// The native model format is {"capacity": capacity, "decay": decay, "trees": [tree, ...], "weights": [weight, ...]}, where capacity is omitted if forest is not bounded, each tree is encoded by Leaf.MarshalJSON, and the base weights (see EnqueueWeighted) are omitted if all of them are 1.0.
+func (forest *Forest) MarshalJSON() ([]byte, error) {
+	forest.mutex.RLock()
+	defer forest.mutex.RUnlock()
+	fj := &forestJSON{
+		Capacity: forest.capacity,
+		Decay:    forest.decay,
+		Trees:    forest.rebuildTrees(0, len(forest.trees)),
+	}
+	for _, tree := range forest.trees {
+		if tree.weight != 1.0 && fj.Weights == nil {
+			fj.Weights = make([]float32, len(forest.trees))
+			for u := range fj.Weights {
+				fj.Weights[u] = forest.trees[u].weight
+			}
+		}
+	}
+	return json.Marshal(fj)
+}
+
+// UnmarshalJSON is for interface json.Unmarshaler.
+// This replaces forest with the forest compiled from the native model format (see MarshalJSON).
+func (forest *Forest) UnmarshalJSON(data []byte) error {
+	fj := &forestJSON{
+		Decay: 1.0,
+	}
+	if err := json.Unmarshal(data, fj); err != nil {
+		return err
+	}
+	newForest := NewForest()
+	if fj.Capacity != 0 {
+		var err error
+		if newForest, err = NewBoundedForest(fj.Capacity); err != nil {
+			return err
+		}
+	}
+	if err := newForest.SetDecay(fj.Decay); err != nil {
+		return err
+	}
+	weights := fj.Weights
+	if weights == nil {
+		weights = make([]float32, len(fj.Trees))
+		for t := range weights {
+			weights[t] = 1.0
+		}
+	}
+	if len(weights) != len(fj.Trees) {
+		return fmt.Errorf("the number of weights must be equal to the number of trees")
+	}
+	if err := newForest.enqueue(fj.Trees, weights); err != nil {
+		return err
+	}
+	forest.mutex.Lock()
+	defer forest.mutex.Unlock()
+	forest.capacity, forest.decay = newForest.capacity, newForest.decay
+	forest.features, forest.trees, forest.weights = newForest.features, newForest.trees, newForest.weights
+	return nil
+}
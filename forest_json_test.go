@@ -0,0 +1,43 @@
+package confeito
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestForestJSON(t *testing.T) {
+	x := DenseFeatureVector{-2.0, -1.0, 0.0, 1.0, 2.0}
+	forest := goassert.New(t).SucceedNew(NewBoundedForest(3)).(*Forest)
+	goassert.New(t).SucceedWithoutError(forest.SetDecay(0.5))
+	tree := goassert.New(t).SucceedNew(NewLeaf(0, -2.5, float32(0.0), float32(1.0))).(*Leaf)
+	goassert.New(t).SucceedWithoutError(forest.Enqueue(newTestTree(t), tree))
+	data := goassert.New(t).SucceedNew(json.Marshal(forest)).([]byte)
+	goassert.New(t, `{"capacity":3,"decay":0.5,"trees":[`+string(goassert.New(t).SucceedNew(json.Marshal(newTestTree(t))).([]byte))+`,{"feature":0,"threshold":-2.5,"left":{"value":0},"right":{"value":1}}]}`).Equal(string(data))
+	decoded := NewForest()
+	goassert.New(t).SucceedWithoutError(json.Unmarshal(data, decoded))
+	goassert.New(t, 3).Equal(decoded.Capacity())
+	goassert.New(t, float32(0.5)).Equal(decoded.Decay())
+	goassert.New(t, []interface{}{float32(1.0), float32(1.0)}).EqualWithoutError(decoded.Predict(x))
+	goassert.New(t, float32(1.5)).EqualWithoutError(decoded.PredictSum(x))
+
+	goassert.New(t).SucceedWithoutError(json.Unmarshal([]byte(`{"trees":[{"value":1}]}`), decoded))
+	goassert.New(t, 0).Equal(decoded.Capacity())
+	goassert.New(t, float32(1.0)).Equal(decoded.Decay())
+	goassert.New(t, []interface{}{float32(1.0)}).EqualWithoutError(decoded.Predict(x))
+	goassert.New(t, "capacity must be positive").ExpectError(json.Unmarshal([]byte(`{"capacity":-1,"trees":[]}`), decoded))
+	goassert.New(t, "decay must be in (0.0, 1.0]").ExpectError(json.Unmarshal([]byte(`{"decay":2,"trees":[]}`), decoded))
+	goassert.New(t, "root: leaf must not be nil").ExpectError(json.Unmarshal([]byte(`{"trees":[null]}`), decoded))
+	goassert.New(t, []interface{}{float32(1.0)}).EqualWithoutError(decoded.Predict(x))
+
+	weighted := NewForest()
+	goassert.New(t).SucceedWithoutError(weighted.Enqueue(tree))
+	goassert.New(t).SucceedWithoutError(weighted.EnqueueWeighted(0.25, tree))
+	data = goassert.New(t).SucceedNew(json.Marshal(weighted)).([]byte)
+	goassert.New(t, `{"decay":1,"trees":[{"feature":0,"threshold":-2.5,"left":{"value":0},"right":{"value":1}},{"feature":0,"threshold":-2.5,"left":{"value":0},"right":{"value":1}}],"weights":[1,0.25]}`).Equal(string(data))
+	goassert.New(t).SucceedWithoutError(json.Unmarshal(data, decoded))
+	goassert.New(t, []float32{1.0, 0.25}).Equal(decoded.Weights())
+	goassert.New(t, float32(1.25)).EqualWithoutError(decoded.PredictSum(x))
+	goassert.New(t, "the number of weights must be equal to the number of trees").ExpectError(json.Unmarshal([]byte(`{"trees":[{"value":1}],"weights":[1,2]}`), decoded))
+}
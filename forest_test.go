@@ -1,6 +1,9 @@
 package confeito
 
 import (
+	"math"
+	"math/rand"
+	"sort"
 	"testing"
 
 	"github.com/hiro4bbh/go-assert"
@@ -50,21 +53,320 @@ func TestForestEnqueueDequeue(t *testing.T) {
 	goassert.New(t).SucceedWithoutError(forest.Enqueue(tree3))
 	goassert.New(t).SucceedWithoutError(forest.Enqueue(tree4))
 	goassert.New(t, []interface{}{float32(1.0), float32(1.0), float32(2.0), float32(0.0)}).EqualWithoutError(forest.Predict(x))
+	goassert.New(t, 5).Equal(len(forest.features))
 	forest.Dequeue()
 	goassert.New(t, []interface{}{float32(1.0), float32(2.0), float32(0.0)}).EqualWithoutError(forest.Predict(x))
+	// Feature 1 is used only by tree1, so it is removed.
+	goassert.New(t, 4).Equal(len(forest.features))
 	goassert.New(t).SucceedWithoutError(forest.Enqueue(tree1))
 	goassert.New(t, []interface{}{float32(1.0), float32(2.0), float32(0.0), float32(1.0)}).EqualWithoutError(forest.Predict(x))
+	goassert.New(t, 5).Equal(len(forest.features))
 	forest.Dequeue()
 	forest.Dequeue()
 	forest.Dequeue()
 	forest.Dequeue()
 	goassert.New(t, []interface{}{}).EqualWithoutError(forest.Predict(x))
+	goassert.New(t, 0).Equal(len(forest.features))
 	forest.Dequeue()
 	goassert.New(t, []interface{}{}).EqualWithoutError(forest.Predict(x))
 }
 
+func TestForestBalancedTree(t *testing.T) {
+	// (feature[0] <= 0 ? (feature[1] <= 0 ? 1 : 2) : (feature[2] <= 0 ? 3 : (feature[1] <= 1 ? 4 : 5)))
+	tree := goassert.New(t).SucceedNew(NewLeaf(0, 0.0, float32(0.0), float32(0.0))).(*Leaf)
+	tree.SetLeft(goassert.New(t).SucceedNew(NewLeaf(1, 0.0, float32(1.0), float32(2.0))).(*Leaf))
+	treeR := goassert.New(t).SucceedNew(NewLeaf(2, 0.0, float32(3.0), float32(0.0))).(*Leaf)
+	treeR.SetRight(goassert.New(t).SucceedNew(NewLeaf(1, 1.0, float32(4.0), float32(5.0))).(*Leaf))
+	tree.SetRight(treeR)
+	forest := goassert.New(t).SucceedNew(NewForestFromTrees(tree)).(*Forest)
+	for _, x := range []DenseFeatureVector{
+		{-1.0, -1.0, -1.0}, {-1.0, 1.0, -1.0}, {1.0, -1.0, -1.0}, {1.0, 1.0, 1.0}, {1.0, 2.0, 1.0},
+	} {
+		value := goassert.New(t).SucceedNew(tree.Predict(x))
+		goassert.New(t, []interface{}{value}).EqualWithoutError(forest.Predict(x))
+	}
+}
+
+func TestBoundedForest(t *testing.T) {
+	x := DenseFeatureVector{-2.0, -1.0, 0.0, 1.0, 2.0, 3.0}
+
+	goassert.New(t, "capacity must be positive").ExpectError(NewBoundedForest(0))
+	tree1 := goassert.New(t).SucceedNew(NewLeaf(0, -2.5, float32(0.0), float32(1.0))).(*Leaf)
+	tree1.SetRight(goassert.New(t).SucceedNew(NewLeaf(1, 0.0, float32(1.0), float32(2.0))).(*Leaf))
+	tree2 := goassert.New(t).SucceedNew(NewLeaf(0, -4.5, float32(0.0), float32(1.0))).(*Leaf)
+	tree2.SetRight(goassert.New(t).SucceedNew(NewLeaf(2, 0.0, float32(1.0), float32(2.0))).(*Leaf))
+	tree3 := goassert.New(t).SucceedNew(NewLeaf(0, -3.5, float32(0.0), float32(1.0))).(*Leaf)
+	tree3.SetRight(goassert.New(t).SucceedNew(NewLeaf(3, 0.0, float32(1.0), float32(2.0))).(*Leaf))
+	tree4 := goassert.New(t).SucceedNew(NewLeaf(0, -1.5, float32(0.0), float32(1.0))).(*Leaf)
+	tree4.SetRight(goassert.New(t).SucceedNew(NewLeaf(4, 0.0, float32(1.0), float32(2.0))).(*Leaf))
+	forest := goassert.New(t).SucceedNew(NewBoundedForest(2)).(*Forest)
+	goassert.New(t, 2).Equal(forest.Capacity())
+	goassert.New(t).SucceedWithoutError(forest.Enqueue(tree1))
+	goassert.New(t, []interface{}{float32(1.0)}).EqualWithoutError(forest.Predict(x))
+	goassert.New(t).SucceedWithoutError(forest.Enqueue(tree2, tree3))
+	goassert.New(t, 2).Equal(forest.NumTrees())
+	goassert.New(t, []interface{}{float32(1.0), float32(2.0)}).EqualWithoutError(forest.Predict(x))
+	goassert.New(t).SucceedWithoutError(forest.Enqueue(tree4, tree1, tree2))
+	goassert.New(t, []interface{}{float32(1.0), float32(1.0)}).EqualWithoutError(forest.Predict(x))
+	forest.Dequeue()
+	goassert.New(t, []interface{}{float32(1.0)}).EqualWithoutError(forest.Predict(x))
+}
+
+func TestForestWeights(t *testing.T) {
+	tree := goassert.New(t).SucceedNew(NewLeaf(0, 0.0, float32(0.0), float32(1.0))).(*Leaf)
+	forest := NewForest()
+	goassert.New(t, float32(1.0)).Equal(forest.Decay())
+	goassert.New(t, "decay must be in (0.0, 1.0]").ExpectError(forest.SetDecay(0.0))
+	goassert.New(t, "decay must be in (0.0, 1.0]").ExpectError(forest.SetDecay(1.5))
+	goassert.New(t, []float32{}).Equal(forest.Weights())
+	goassert.New(t).SucceedWithoutError(forest.Enqueue(tree, tree, tree))
+	goassert.New(t, []float32{1.0, 1.0, 1.0}).Equal(forest.Weights())
+	goassert.New(t).SucceedWithoutError(forest.SetDecay(0.5))
+	goassert.New(t, []float32{0.25, 0.5, 1.0}).Equal(forest.Weights())
+	forest.Dequeue()
+	goassert.New(t, []float32{0.5, 1.0}).Equal(forest.Weights())
+	goassert.New(t).SucceedWithoutError(forest.EnqueueWeighted(0.5, tree, tree))
+	goassert.New(t, []float32{0.125, 0.25, 0.25, 0.5}).Equal(forest.Weights())
+	goassert.New(t, "weight must be finite").ExpectError(forest.EnqueueWeighted(float32(math.Inf(1)), tree))
+	goassert.New(t, 4).Equal(forest.NumTrees())
+	// The weights are cached, so the returned slice must not share it.
+	forest.Weights()[0] = 100.0
+	goassert.New(t, []float32{0.125, 0.25, 0.25, 0.5}).Equal(forest.Weights())
+	goassert.New(t, float32(1.125)).EqualWithoutError(forest.PredictSum(DenseFeatureVector{1.0}))
+}
+
+func TestForestRebuildTrees(t *testing.T) {
+	trees := []*Leaf{
+		goassert.New(t).SucceedNew(NewTerminalLeaf(float32(1.0))).(*Leaf),
+		goassert.New(t).SucceedNew(ParseLeaf("(feature[0] <= 0.5 ? (feature[1] < 1 ? 1 : (feature[0] <= 0 ? 2 : 3)) : (feature[1] <= 2 ? (feature[2] < 0 ? 4 : 5) : 6))")).(*Leaf),
+		goassert.New(t).SucceedNew(ParseLeaf("(feature[1] <= 1 ? 7 : (feature[1] <= 2 ? 8 : (feature[1] <= 3 ? 9 : 10)))")).(*Leaf),
+		goassert.New(t).SucceedNew(ParseLeaf("(feature[0] < 0.5 ? (feature[0] < 0.5 ? 11 : 12) : (feature[0] < 0.5 ? 13 : 14))")).(*Leaf),
+	}
+	forest := goassert.New(t).SucceedNew(NewForestFromTrees(trees...)).(*Forest)
+	for i, tree := range trees {
+		goassert.New(t, true).Equal(tree.Equal(goassert.New(t).SucceedNew(forest.Tree(i)).(*Leaf), 0.0))
+	}
+	goassert.New(t, "tree index 4 is out of range [0, 4)").ExpectError(forest.Tree(4))
+	// The trees are rebuilt from the entries remaining after Dequeue.
+	forest.Dequeue()
+	ensemble := forest.Ensemble()
+	goassert.New(t, 3).Equal(len(ensemble.Trees))
+	for i, tree := range trees[1:] {
+		goassert.New(t, true).Equal(tree.Equal(ensemble.Trees[i], 0.0))
+	}
+}
+
+func TestForestEnsemble(t *testing.T) {
+	tree1 := goassert.New(t).SucceedNew(NewLeaf(0, -2.5, float32(1.0), float32(2.0))).(*Leaf)
+	tree2 := goassert.New(t).SucceedNew(NewLeaf(1, 0.0, float32(4.0), float32(8.0))).(*Leaf)
+	forest := NewForest()
+	goassert.New(t).SucceedWithoutError(forest.Enqueue(tree1))
+	goassert.New(t).SucceedWithoutError(forest.EnqueueWeighted(0.5, tree2))
+	goassert.New(t).SucceedWithoutError(forest.SetDecay(0.5))
+	ensemble := forest.Ensemble()
+	goassert.New(t, []float32{0.5, 0.5}).Equal(ensemble.Weights)
+	goassert.New(t, 2).Equal(len(ensemble.Trees))
+	goassert.New(t, true).Equal(tree1.Equal(ensemble.Trees[0], 0.0))
+	goassert.New(t, true).Equal(tree2.Equal(ensemble.Trees[1], 0.0))
+	x := DenseFeatureVector{-2.0, -1.0, 0.0}
+	goassert.New(t, goassert.New(t).SucceedNew(forest.PredictSum(x))).EqualWithoutError(ensemble.PredictSum(x))
+}
+
+func TestForestPredictSum(t *testing.T) {
+	x := DenseFeatureVector{-2.0, -1.0, 0.0}
+	tree1 := goassert.New(t).SucceedNew(NewLeaf(0, -2.5, float32(1.0), float32(2.0))).(*Leaf)
+	tree2 := goassert.New(t).SucceedNew(NewLeaf(1, 0.0, float32(4.0), float32(8.0))).(*Leaf)
+	forest := NewForest()
+	goassert.New(t, float32(0.0)).EqualWithoutError(forest.PredictSum(x))
+	goassert.New(t).SucceedWithoutError(forest.Enqueue(tree1, tree2))
+	goassert.New(t, float32(6.0)).EqualWithoutError(forest.PredictSum(x))
+	goassert.New(t).SucceedWithoutError(forest.SetDecay(0.5))
+	goassert.New(t, float32(5.0)).EqualWithoutError(forest.PredictSum(x))
+	goassert.New(t).SucceedWithoutError(forest.Enqueue(goassert.New(t).SucceedNew(NewTerminalLeaf("a")).(*Leaf)))
+	goassert.New(t, "value of tree 2 must be float32: \"a\"").ExpectError(forest.PredictSum(x))
+}
+
+func TestForestPredictLeaves(t *testing.T) {
+	tree1 := goassert.New(t).SucceedNew(ParseLeaf("(feature[0] <= 0.5 ? (feature[1] <= 1 ? 1 : 2) : (feature[1] < 2 ? 3 : 4))")).(*Leaf)
+	tree2 := goassert.New(t).SucceedNew(ParseLeaf("(feature[1] <= 1 ? 5 : (feature[0] <= 0 ? 6 : 7))")).(*Leaf)
+	forest := goassert.New(t).SucceedNew(NewForestFromTrees(tree1, tree2)).(*Forest)
+	goassert.New(t, []int{0, 0}).EqualWithoutError(forest.PredictLeaves(DenseFeatureVector{0.5, 1.0}))
+	goassert.New(t, []int{1, 1}).EqualWithoutError(forest.PredictLeaves(DenseFeatureVector{0.0, 1.5}))
+	goassert.New(t, []int{3, 2}).EqualWithoutError(forest.PredictLeaves(DenseFeatureVector{1.0, 2.0}))
+	goassert.New(t, []int{2, 0}).EqualWithoutError(forest.PredictLeaves(DenseFeatureVector{1.0, float32(math.NaN())}))
+	goassert.New(t, []int{}).EqualWithoutError(NewForest().PredictLeaves(DenseFeatureVector{}))
+}
+
+func TestForestMemoryFootprint(t *testing.T) {
+	forest := NewForest()
+	goassert.New(t, 0).Equal(forest.MemoryFootprint())
+	tree := goassert.New(t).SucceedNew(ParseLeaf("(feature[0] <= 0.5 ? 1 : (feature[1] <= 1 ? 2 : 3))")).(*Leaf)
+	goassert.New(t).SucceedWithoutError(forest.Enqueue(tree))
+	size1 := forest.MemoryFootprint()
+	goassert.New(t, true).Equal(size1 > 0)
+	goassert.New(t).SucceedWithoutError(forest.Enqueue(tree))
+	size2 := forest.MemoryFootprint()
+	goassert.New(t, true).Equal(size2 > size1)
+	// Dequeue keeps the allocated entries for the trees enqueued later.
+	forest.Dequeue()
+	goassert.New(t, true).Equal(forest.MemoryFootprint() < size2)
+}
+
+func TestForestConcurrentPredict(t *testing.T) {
+	x := DenseFeatureVector{-2.0, -1.0, 0.0, 1.0, 2.0, 3.0}
+	tree := goassert.New(t).SucceedNew(NewLeaf(0, -2.5, float32(0.0), float32(1.0))).(*Leaf)
+	forest := goassert.New(t).SucceedNew(NewBoundedForest(8)).(*Forest)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			forest.Enqueue(tree)
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		values, err := forest.Predict(x)
+		goassert.New(t).SucceedWithoutError(err)
+		for _, value := range values {
+			goassert.New(t, float32(1.0)).Equal(value)
+		}
+	}
+	<-done
+	goassert.New(t, 8).Equal(forest.NumTrees())
+}
+
+func TestForestIncrementalEnqueue(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	trees := make([]*Leaf, 32)
+	for i := range trees {
+		root := goassert.New(t).SucceedNew(NewLeaf(FeatureID(rng.Intn(4)), rng.Float32(), float32(0.0), float32(i))).(*Leaf)
+		root.SetRight(goassert.New(t).SucceedNew(NewLeaf(FeatureID(rng.Intn(4)), rng.Float32(), float32(i), float32(2*i))).(*Leaf))
+		trees[i] = root
+	}
+	bulk := goassert.New(t).SucceedNew(NewForestFromTrees(trees...)).(*Forest)
+	incremental := NewForest()
+	for _, tree := range trees {
+		goassert.New(t).SucceedWithoutError(incremental.Enqueue(tree))
+	}
+	for featureID, feature := range incremental.features {
+		goassert.New(t, true).Equal(sort.IsSorted(feature))
+		goassert.New(t, bulk.features[featureID].thresholds).Equal(feature.thresholds)
+	}
+	for i := 0; i < 100; i++ {
+		x := DenseFeatureVector{rng.Float32(), rng.Float32(), rng.Float32(), rng.Float32()}
+		expected := make([]interface{}, len(trees))
+		for t := range trees {
+			expected[t], _ = trees[t].Predict(x)
+		}
+		goassert.New(t, expected).EqualWithoutError(bulk.Predict(x))
+		goassert.New(t, expected).EqualWithoutError(incremental.Predict(x))
+	}
+	goassert.New(t, "the number of leaves in the tree must not be greater than 64").ExpectError(NewForestFromTrees(newTooDeepTree(t)))
+}
+
+func TestForestMixedOperators(t *testing.T) {
+	// The thresholds and the feature values are taken from a few values, so that the splits with both operators often have the same threshold as the feature value.
+	rng := rand.New(rand.NewSource(0))
+	values := []float32{0.0, 0.25, 0.5, 0.75}
+	newLeaf := func(value1, value2 float32) *Leaf {
+		leaf := goassert.New(t).SucceedNew(NewLeaf(FeatureID(rng.Intn(2)), values[rng.Intn(len(values))], value1, value2)).(*Leaf)
+		goassert.New(t).SucceedWithoutError(leaf.SetOperator(SplitOperator(rng.Intn(2))))
+		return leaf
+	}
+	trees := make([]*Leaf, 64)
+	for i := range trees {
+		trees[i] = newLeaf(float32(0.0), float32(i))
+		trees[i].SetLeft(newLeaf(float32(-i), float32(i)))
+		trees[i].SetRight(newLeaf(float32(i), float32(2*i)))
+	}
+	bulk := goassert.New(t).SucceedNew(NewForestFromTrees(trees...)).(*Forest)
+	incremental := NewForest()
+	for _, tree := range trees {
+		goassert.New(t).SucceedWithoutError(incremental.Enqueue(tree))
+	}
+	for _, feature := range incremental.features {
+		goassert.New(t, true).Equal(sort.IsSorted(feature))
+	}
+	for i := 0; i < 100; i++ {
+		x := DenseFeatureVector{values[rng.Intn(len(values))], values[rng.Intn(len(values))]}
+		if rng.Intn(4) == 0 {
+			x[rng.Intn(2)] = float32(math.NaN())
+		}
+		expected := make([]interface{}, len(trees))
+		for t := range trees {
+			expected[t], _ = trees[t].Predict(x)
+		}
+		goassert.New(t, expected).EqualWithoutError(bulk.Predict(x))
+		goassert.New(t, expected).EqualWithoutError(incremental.Predict(x))
+	}
+	incremental.Dequeue()
+	for i := 0; i < 100; i++ {
+		x := DenseFeatureVector{values[rng.Intn(len(values))], values[rng.Intn(len(values))]}
+		expected := make([]interface{}, len(trees)-1)
+		for t := range expected {
+			expected[t], _ = trees[t+1].Predict(x)
+		}
+		goassert.New(t, expected).EqualWithoutError(incremental.Predict(x))
+	}
+}
+
+func TestForestEnqueueAtomic(t *testing.T) {
+	xs := []DenseFeatureVector{{-3.0, -1.0}, {-2.0, 1.0}, {1.0, -1.0}, {1.0, 1.0}}
+	tree1 := goassert.New(t).SucceedNew(NewLeaf(0, -2.5, float32(0.0), float32(1.0))).(*Leaf)
+	tree2 := goassert.New(t).SucceedNew(NewLeaf(1, 0.0, float32(2.0), float32(3.0))).(*Leaf)
+	forest := goassert.New(t).SucceedNew(NewBoundedForest(2)).(*Forest)
+	goassert.New(t).SucceedWithoutError(forest.Enqueue(tree1))
+	predictions := make([][]interface{}, len(xs))
+	for i, x := range xs {
+		predictions[i] = goassert.New(t).SucceedNew(forest.Predict(x)).([]interface{})
+	}
+	// Each failing Enqueue has a valid tree before the broken one, which must not be enqueued nor evict the existing tree.
+	for _, c := range []struct {
+		enqueue func() error
+		err     string
+	}{
+		{func() error { return forest.Enqueue(tree2, newTooDeepTree(t), tree2) }, "the number of leaves in the tree must not be greater than 64"},
+		{func() error { return forest.EnqueueWeighted(float32(math.NaN()), tree2, tree2) }, "weight must be finite"},
+	} {
+		goassert.New(t, c.err).ExpectError(c.enqueue())
+		goassert.New(t, 1).Equal(forest.NumTrees())
+		for i, x := range xs {
+			goassert.New(t, predictions[i]).EqualWithoutError(forest.Predict(x))
+		}
+	}
+	goassert.New(t).SucceedWithoutError(forest.Enqueue(tree2))
+	goassert.New(t, []interface{}{float32(1.0), float32(2.0)}).EqualWithoutError(forest.Predict(xs[2]))
+}
+
+func TestForestEnqueueMalformedTree(t *testing.T) {
+	tree := goassert.New(t).SucceedNew(NewLeaf(0, 0.0, float32(0.0), float32(1.0))).(*Leaf)
+	cyclic := goassert.New(t).SucceedNew(NewLeaf(0, 0.0, float32(0.0), float32(1.0))).(*Leaf)
+	cyclic.SetRight(cyclic)
+	forest := NewForest()
+	goassert.New(t, "root.right: cycle to root").ExpectError(forest.Enqueue(tree, cyclic))
+	goassert.New(t, 0).Equal(forest.NumTrees())
+	_, ok := forest.Enqueue(&Leaf{}).(*LeafError)
+	goassert.New(t, true).Equal(ok)
+	goassert.New(t, "root: non-terminal leaf must have left and right leaf").ExpectError(NewForestFromTrees(&Leaf{}))
+}
+
+func newTooDeepTree(t *testing.T) *Leaf {
+	tree := goassert.New(t).SucceedNew(NewLeaf(0, 0.0, float32(0.0), float32(0.0))).(*Leaf)
+	child := tree
+	for d := 0; d < 65; d++ {
+		leaf := goassert.New(t).SucceedNew(NewLeaf(0, 0.0, float32(0.0), float32(0.0))).(*Leaf)
+		child.SetLeft(leaf)
+		child = leaf
+	}
+	return tree
+}
+
 // NOTICE: If Forest supports trees with more than 64 leaves, then this test should be modified.
 func TestForestEnqueueTooDeepTree(t *testing.T) {
+	forest := NewForest()
+	goassert.New(t, "the number of leaves in the tree must not be greater than 64").ExpectError(forest.Enqueue(newTooDeepTree(t)))
+	goassert.New(t, 0).Equal(forest.NumTrees())
+	goassert.New(t, 0).Equal(len(forest.features))
+
 	treeLeft := goassert.New(t).SucceedNew(NewLeaf(0, 0.0, float32(0.0), float32(0.0))).(*Leaf)
 	treeRight := goassert.New(t).SucceedNew(NewLeaf(0, 0.0, float32(0.0), float32(0.0))).(*Leaf)
 	childLeft, childRight := treeLeft, treeRight
@@ -75,7 +377,6 @@ func TestForestEnqueueTooDeepTree(t *testing.T) {
 		childRight.SetRight(leafRight)
 		childLeft, childRight = leafLeft, leafRight
 	}
-	forest := NewForest()
 	goassert.New(t, "the number of leaves in the tree must not be greater than 64").ExpectError(forest.Enqueue(treeLeft))
 	goassert.New(t, "the number of leaves in the tree must not be greater than 64").ExpectError(forest.Enqueue(treeRight))
 }
@@ -132,3 +433,33 @@ func BenchmarkForest(b *testing.B) {
 		forest.Predict(x)
 	}
 }
+
+func BenchmarkForestPredictSum(b *testing.B) {
+	dim, ntrees, depth := 256, 512, 6
+	rng := rand.New(rand.NewSource(0))
+	trees := make([]*Leaf, ntrees)
+	for t := range trees {
+		trees[t] = goassert.New(b).SucceedNew(NewTerminalLeaf(float32(0.0))).(*Leaf)
+		leaf := trees[t]
+		for d := 0; d < depth; d++ {
+			child := goassert.New(b).SucceedNew(NewLeaf(FeatureID(rng.Intn(dim)), float32(rng.Intn(10))/4.0, float32(0.1), float32(-0.1))).(*Leaf)
+			if d == 0 {
+				trees[t] = child
+			} else {
+				leaf.SetRight(child)
+			}
+			leaf = child
+		}
+	}
+	forest := NewForest()
+	goassert.New(b).SucceedWithoutError(forest.Enqueue(trees...))
+	goassert.New(b).SucceedWithoutError(forest.SetDecay(0.999))
+	x := make(DenseFeatureVector, dim)
+	for i := range x {
+		x[i] = float32(rng.Intn(10)) / 4.0
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		forest.PredictSum(x)
+	}
+}
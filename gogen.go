@@ -0,0 +1,132 @@
+package confeito
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"math"
+	"strconv"
+	"unicode"
+)
+
+// GoSourceOptions is the options of generating Go source code by WriteGoSource.
+type GoSourceOptions struct {
+	// Package is the package name of the generated file.
+	Package string
+	// Function is the name of the generated scoring function.
+	Function string
+}
+
+// goSourceWriter is the buffer of the body of the generated Go source file, which records whether package math is used.
+type goSourceWriter struct {
+	bytes.Buffer
+	usesMath bool
+}
+
+// formatFloat32 returns the Go expression of float32 value v.
+// The non-finite values are written with package math, because Go has no literals of them.
+func (w *goSourceWriter) formatFloat32(v float32) string {
+	switch {
+	case math.IsNaN(float64(v)):
+		w.usesMath = true
+		return "float32(math.NaN())"
+	case math.IsInf(float64(v), 1):
+		w.usesMath = true
+		return "float32(math.Inf(1))"
+	case math.IsInf(float64(v), -1):
+		w.usesMath = true
+		return "float32(math.Inf(-1))"
+	}
+	return fmt.Sprintf("float32(%s)", strconv.FormatFloat(float64(v), 'g', -1, 32))
+}
+
+// treeFloat32Value returns the float32 value of terminal leaf l.
+//
+// This function returns an error if the value is not float32.
+func treeFloat32Value(l *Leaf, path LeafPath) (float32, error) {
+	value, ok := l.value.(float32)
+	if !ok {
+		return 0.0, &LeafError{Path: append(LeafPath{}, path...), Reason: fmt.Sprintf("value must be float32: %#v", l.value)}
+	}
+	return value, nil
+}
+
+// rightComparison returns the comparison operator in the source code taking the right leaf of non-terminal leaf l.
+// The generated code tests the right leaf, so NaN goes to the left as Leaf does.
+func rightComparison(l *Leaf) string {
+	if l.operator == Less {
+		return ">="
+	}
+	return ">"
+}
+
+// writeGoTree writes the nested if/else statements of the tree whose root is l.
+func writeGoTree(w *goSourceWriter, l *Leaf, featureFunc string, path LeafPath) error {
+	if l.IsTerminal() {
+		value, err := treeFloat32Value(l, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "return %s\n", w.formatFloat32(value))
+		return nil
+	}
+	fmt.Fprintf(w, "if %s(x, %d) %s %s {\n", featureFunc, l.featureID, rightComparison(l), w.formatFloat32(l.threshold))
+	if err := writeGoTree(w, l.right, featureFunc, append(path, true)); err != nil {
+		return err
+	}
+	w.WriteString("}\n")
+	return writeGoTree(w, l.left, featureFunc, append(path, false))
+}
+
+// WriteGoSource writes the standalone Go source file implementing the summed score of forest (see Forest.PredictSum) to w.
+// The generated function has signature func(x []float32) float32, where the features not in x are regarded as 0.
+// Each tree is implemented as nested if/else statements in an unexported function.
+//
+// This function returns an error if options has an illegal identifier, a value of forest is not float32, or at writing to w.
+func WriteGoSource(w io.Writer, forest *Forest, options *GoSourceOptions) error {
+	if !token.IsIdentifier(options.Package) {
+		return fmt.Errorf("illegal package name: %q", options.Package)
+	}
+	if !token.IsIdentifier(options.Function) {
+		return fmt.Errorf("illegal function name: %q", options.Function)
+	}
+	forest.mutex.RLock()
+	defer forest.mutex.RUnlock()
+	// The helper functions are unexported.
+	runes := []rune(options.Function)
+	prefix := string(unicode.ToLower(runes[0])) + string(runes[1:])
+	featureFunc := prefix + "Feature"
+	var body goSourceWriter
+	fmt.Fprintf(&body, "// %s returns the score of the model for feature vector x.\n", options.Function)
+	body.WriteString("// The features not in x are regarded as 0.\n")
+	fmt.Fprintf(&body, "func %s(x []float32) float32 {\n", options.Function)
+	body.WriteString("score := float32(0.0)\n")
+	for t, weight := range forest.weights {
+		fmt.Fprintf(&body, "score += %s * %sTree%d(x)\n", body.formatFloat32(weight), prefix, t)
+	}
+	body.WriteString("return score\n}\n\n")
+	fmt.Fprintf(&body, "func %s(x []float32, i int) float32 {\n", featureFunc)
+	body.WriteString("if i < len(x) {\nreturn x[i]\n}\nreturn 0.0\n}\n")
+	for t, tree := range forest.rebuildTrees(0, len(forest.trees)) {
+		fmt.Fprintf(&body, "\nfunc %sTree%d(x []float32) float32 {\n", prefix, t)
+		if err := writeGoTree(&body, tree, featureFunc, LeafPath{}); err != nil {
+			return fmt.Errorf("tree %d: %s", t, err)
+		}
+		body.WriteString("}\n")
+	}
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by confeito; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", options.Package)
+	if body.usesMath {
+		buf.WriteString("import \"math\"\n\n")
+	}
+	buf.Write(body.Bytes())
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(source)
+	return err
+}
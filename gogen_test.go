@@ -0,0 +1,175 @@
+package confeito
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestWriteGoSource(t *testing.T) {
+	tree := goassert.New(t).SucceedNew(ParseLeaf("(feature[0] <= 0.5 ? 1 : (feature[2] < -1e-05 ? 2 : 3))")).(*Leaf)
+	forest := goassert.New(t).SucceedNew(NewForestFromTrees(tree)).(*Forest)
+	var buf bytes.Buffer
+	goassert.New(t).SucceedWithoutError(WriteGoSource(&buf, forest, &GoSourceOptions{Package: "model", Function: "Score"}))
+	goassert.New(t, `// Code generated by confeito; DO NOT EDIT.
+
+package model
+
+// Score returns the score of the model for feature vector x.
+// The features not in x are regarded as 0.
+func Score(x []float32) float32 {
+	score := float32(0.0)
+	score += float32(1) * scoreTree0(x)
+	return score
+}
+
+func scoreFeature(x []float32, i int) float32 {
+	if i < len(x) {
+		return x[i]
+	}
+	return 0.0
+}
+
+func scoreTree0(x []float32) float32 {
+	if scoreFeature(x, 0) > float32(0.5) {
+		if scoreFeature(x, 2) >= float32(-1e-05) {
+			return float32(3)
+		}
+		return float32(2)
+	}
+	return float32(1)
+}
+`).Equal(buf.String())
+	goassert.New(t, "illegal package name: \"1a\"").ExpectError(WriteGoSource(&buf, forest, &GoSourceOptions{Package: "1a", Function: "Score"}))
+	goassert.New(t, "illegal function name: \"\"").ExpectError(WriteGoSource(&buf, forest, &GoSourceOptions{Package: "model"}))
+	goassert.New(t).SucceedWithoutError(forest.Enqueue(goassert.New(t).SucceedNew(NewLeaf(0, 0.0, float32(1.0), "a")).(*Leaf)))
+	goassert.New(t, "tree 1: root.right: value must be float32: \"a\"").ExpectError(WriteGoSource(&buf, forest, &GoSourceOptions{Package: "model", Function: "Score"}))
+}
+
+func TestWriteGoSourceNonFinite(t *testing.T) {
+	tree := goassert.New(t).SucceedNew(NewLeaf(0, 0.5, float32(math.Inf(-1)), float32(math.Inf(1)))).(*Leaf)
+	forest := goassert.New(t).SucceedNew(NewForestFromTrees(tree)).(*Forest)
+	var buf bytes.Buffer
+	goassert.New(t).SucceedWithoutError(WriteGoSource(&buf, forest, &GoSourceOptions{Package: "model", Function: "Score"}))
+	goassert.New(t, `// Code generated by confeito; DO NOT EDIT.
+
+package model
+
+import "math"
+
+// Score returns the score of the model for feature vector x.
+// The features not in x are regarded as 0.
+func Score(x []float32) float32 {
+	score := float32(0.0)
+	score += float32(1) * scoreTree0(x)
+	return score
+}
+
+func scoreFeature(x []float32, i int) float32 {
+	if i < len(x) {
+		return x[i]
+	}
+	return 0.0
+}
+
+func scoreTree0(x []float32) float32 {
+	if scoreFeature(x, 0) > float32(0.5) {
+		return float32(math.Inf(1))
+	}
+	return float32(math.Inf(-1))
+}
+`).Equal(buf.String())
+}
+
+// setAlternateOperators sets operator Less to every other non-terminal leaf of trees in pre-order, and returns the thresholds of trees.
+func setAlternateOperators(t *testing.T, trees []*Leaf) []float32 {
+	thresholds := []float32{}
+	for _, tree := range trees {
+		tree.Walk(PreOrder, func(leaf *Leaf, path LeafPath) error {
+			if !leaf.IsTerminal() {
+				if len(thresholds)%2 == 1 {
+					goassert.New(t).SucceedWithoutError(leaf.SetOperator(Less))
+				}
+				thresholds = append(thresholds, leaf.threshold)
+			}
+			return nil
+		})
+	}
+	return thresholds
+}
+
+// newHarnessInput returns a random input of at most 4 features, whose values are sometimes NaN or equal to thresholds.
+func newHarnessInput(rng *rand.Rand, thresholds []float32) []float32 {
+	x := make([]float32, rng.Intn(5))
+	for j := range x {
+		switch rng.Intn(8) {
+		case 0:
+			x[j] = float32(math.NaN())
+		case 1, 2:
+			x[j] = thresholds[rng.Intn(len(thresholds))]
+		default:
+			x[j] = rng.Float32()
+		}
+	}
+	return x
+}
+
+// TestWriteGoSourceHarness compiles the generated source, and verifies that it matches Forest.PredictSum on random inputs.
+func TestWriteGoSourceHarness(t *testing.T) {
+	goPath, err := exec.LookPath("go")
+	if err != nil || testing.Short() {
+		t.Skip("go command is not available, or -short is given")
+	}
+	X, y := newRandomForestDataset(500, false)
+	trainer := NewGradientBoostingTrainer(SquaredLoss{})
+	trainer.NumTrees, trainer.MaxLeaves = 20, ForestMaxLeaves
+	trees := goassert.New(t).SucceedNew(trainer.Train(X, y)).([]*Leaf)
+	thresholds := setAlternateOperators(t, trees)
+	forest := goassert.New(t).SucceedNew(NewForestFromTrees(trees...)).(*Forest)
+	goassert.New(t).SucceedWithoutError(forest.SetDecay(0.9))
+	dir := t.TempDir()
+	var source bytes.Buffer
+	goassert.New(t).SucceedWithoutError(WriteGoSource(&source, forest, &GoSourceOptions{Package: "main", Function: "Score"}))
+	rng := rand.New(rand.NewSource(1))
+	inputs := make([][]float32, 1000)
+	var main bytes.Buffer
+	main.WriteString("package main\n\nimport (\n\t\"fmt\"\n\t\"math\"\n)\n\nvar inputs = [][]float32{\n")
+	for i := range inputs {
+		inputs[i] = newHarnessInput(rng, thresholds)
+		elems := []string{}
+		for _, value := range inputs[i] {
+			if math.IsNaN(float64(value)) {
+				elems = append(elems, "float32(math.NaN())")
+			} else {
+				elems = append(elems, strconv.FormatFloat(float64(value), 'g', -1, 32))
+			}
+		}
+		fmt.Fprintf(&main, "\t{%s},\n", strings.Join(elems, ", "))
+	}
+	main.WriteString("}\n\nfunc main() {\n\tfor _, x := range inputs {\n\t\tfmt.Println(math.Float32bits(Score(x)))\n\t}\n}\n")
+	goassert.New(t).SucceedWithoutError(os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module harness\n\ngo 1.13\n"), 0644))
+	goassert.New(t).SucceedWithoutError(os.WriteFile(filepath.Join(dir, "model.go"), source.Bytes(), 0644))
+	goassert.New(t).SucceedWithoutError(os.WriteFile(filepath.Join(dir, "main.go"), main.Bytes(), 0644))
+	cmd := exec.Command(goPath, "run", ".")
+	cmd.Dir, cmd.Env = dir, append(os.Environ(), "GOFLAGS=")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run failed: %s\n%s", err, output)
+	}
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	goassert.New(t, len(inputs)).Equal(len(lines))
+	for i, x := range inputs {
+		expected := goassert.New(t).SucceedNew(forest.PredictSum(DenseFeatureVector(x))).(float32)
+		bits := goassert.New(t).SucceedNew(strconv.ParseUint(lines[i], 10, 32)).(uint64)
+		goassert.New(t, expected).Equal(math.Float32frombits(uint32(bits)))
+	}
+}
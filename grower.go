@@ -0,0 +1,358 @@
+package confeito
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// trainingSet is a column-oriented copy of a dataset for growing trees.
+// Only the features which can be non-default in some sample have columns, so the sparse feature IDs do not allocate the columns up to the largest one.
+// The missing (NaN) feature values go to the left leaf at every split as in Leaf.Predict, so the thresholds are searched only between the other values.
+type trainingSet struct {
+	nsamples int
+	columns  [][]float32
+	// featureIDs is the feature ID of each column in ascending order.
+	featureIDs []FeatureID
+	// columnIndices is the column index of each feature ID, which is nil if featureIDs[j] == j for every column j.
+	columnIndices map[FeatureID]int
+}
+
+// newTrainingSet returns a new trainingSet copied from X.
+//
+// This function returns an error if X is empty, or at getting feature values of X.
+func newTrainingSet(X []FeatureVector) (*trainingSet, error) {
+	if len(X) == 0 {
+		return nil, fmt.Errorf("dataset must not be empty")
+	}
+	denseDim, sparseIDs := 0, map[FeatureID]bool{}
+	for _, x := range X {
+		if sparse, ok := x.(SparseFeatureVector); ok {
+			for _, vpair := range sparse {
+				sparseIDs[vpair.Key] = true
+			}
+		} else if denseDim < x.Dim() {
+			denseDim = x.Dim()
+		}
+	}
+	featureIDs := make([]FeatureID, denseDim, denseDim+len(sparseIDs))
+	for j := range featureIDs {
+		featureIDs[j] = FeatureID(j)
+	}
+	for featureID := range sparseIDs {
+		if int(featureID) >= denseDim {
+			featureIDs = append(featureIDs, featureID)
+		}
+	}
+	sort.Slice(featureIDs, func(p, q int) bool { return featureIDs[p] < featureIDs[q] })
+	set := &trainingSet{
+		nsamples:   len(X),
+		columns:    make([][]float32, len(featureIDs)),
+		featureIDs: featureIDs,
+	}
+	if len(featureIDs) > 0 && int(featureIDs[len(featureIDs)-1]) != len(featureIDs)-1 {
+		set.columnIndices = make(map[FeatureID]int, len(featureIDs))
+		for j, featureID := range featureIDs {
+			set.columnIndices[featureID] = j
+		}
+	}
+	for j := range set.columns {
+		set.columns[j] = make([]float32, len(X))
+	}
+	for i, x := range X {
+		if sparse, ok := x.(SparseFeatureVector); ok {
+			for _, vpair := range sparse {
+				j, _ := set.columnIndex(vpair.Key)
+				set.columns[j][i] = vpair.Value
+			}
+			continue
+		}
+		for j := 0; j < x.Dim(); j++ {
+			value, err := x.Get(FeatureID(j))
+			if err != nil {
+				return nil, fmt.Errorf("sample %d: %s", i, err)
+			}
+			set.columns[j][i] = value
+		}
+	}
+	return set, nil
+}
+
+// columnIndex returns the column index of featureID in set, or false if featureID does not have a column.
+func (set *trainingSet) columnIndex(featureID FeatureID) (int, bool) {
+	if set.columnIndices == nil {
+		return int(featureID), int(featureID) < len(set.columns)
+	}
+	j, ok := set.columnIndices[featureID]
+	return j, ok
+}
+
+// nfeatures returns the number of features (columns) in set.
+func (set *trainingSet) nfeatures() int {
+	return len(set.columns)
+}
+
+// predict returns the value predicted by tree l for sample i in set.
+func (set *trainingSet) predict(l *Leaf, i int) interface{} {
+	for !l.IsTerminal() {
+		value := float32(0.0)
+		if j, ok := set.columnIndex(l.featureID); ok {
+			value = set.columns[j][i]
+		}
+		if l.goesRight(value) {
+			l = l.right
+		} else {
+			l = l.left
+		}
+	}
+	return l.value
+}
+
+// splitCriterion is the interface for the criterion of splitting leaves.
+// The samples in a leaf are summarized in a statistics vector, which is additive over the samples.
+type splitCriterion interface {
+	// statsLen returns the length of the statistics vectors.
+	statsLen() int
+	// addSample adds the statistics of sample i to stats.
+	addSample(stats []float64, i int)
+	// score returns the score of the samples summarized by stats.
+	// The gain of a split is the sum of the scores of the children minus the score of the parent.
+	score(stats []float64) float64
+	// value returns the value of the terminal leaf having the samples summarized by stats.
+	value(stats []float64) interface{}
+}
+
+// growerNode is a terminal leaf which is a candidate for splitting.
+type growerNode struct {
+	samples []int
+	depth   int
+	stats   []float64
+	hist    [][]float64
+	parent  *Leaf
+	isLeft  bool
+	// The best split of the node.
+	splittable bool
+	column     int
+	threshold  float32
+	gain       float64
+}
+
+// update updates the best split of node on the j-th column if gain is larger.
+func (node *growerNode) update(j int, threshold float32, gain, minGain float64) {
+	if gain > minGain && (!node.splittable || gain > node.gain) {
+		node.splittable = true
+		node.column, node.threshold, node.gain = j, threshold, gain
+	}
+}
+
+// treeGrower grows a tree in best-first order, that is, the terminal leaf with the largest gain is split first.
+//
+// If maxFeatures is positive, then only randomly chosen maxFeatures features are searched at each split.
+// If randomThresholds is true, then a random threshold is drawn for each feature instead of searching the best one (extremely randomized trees).
+// rng must not be nil in these cases.
+//
+// If bins is not nil, then the splits are searched on the histograms of the binned features instead of the sorted feature values.
+// The histograms of the larger child are given by subtracting the ones of the smaller child from the ones of the parent.
+type treeGrower struct {
+	set              *trainingSet
+	criterion        splitCriterion
+	maxDepth         int
+	minSamplesLeaf   int
+	maxLeaves        int
+	maxFeatures      int
+	randomThresholds bool
+	rng              *rand.Rand
+	bins             *featureBins
+}
+
+// sumStats returns the statistics vector of the given samples.
+func (g *treeGrower) sumStats(samples []int) []float64 {
+	stats := make([]float64, g.criterion.statsLen())
+	for _, i := range samples {
+		g.criterion.addSample(stats, i)
+	}
+	return stats
+}
+
+// splitThreshold returns the threshold between the adjacent feature values lower < upper.
+func splitThreshold(lower, upper float32) float32 {
+	if threshold := lower + (upper-lower)/2; threshold < upper {
+		return threshold
+	}
+	return lower
+}
+
+// candidateFeatures returns the features to be searched at a split.
+func (g *treeGrower) candidateFeatures() []int {
+	nfeatures := g.set.nfeatures()
+	if g.maxFeatures > 0 && g.maxFeatures < nfeatures {
+		return g.rng.Perm(nfeatures)[:g.maxFeatures]
+	}
+	features := make([]int, nfeatures)
+	for j := range features {
+		features[j] = j
+	}
+	return features
+}
+
+// findSplit searches the best split of node over the candidate features.
+func (g *treeGrower) findSplit(node *growerNode) {
+	node.splittable = false
+	if g.maxDepth > 0 && node.depth >= g.maxDepth {
+		return
+	}
+	n := len(node.samples)
+	if n < 2*g.minSamplesLeaf {
+		return
+	}
+	parentScore := g.criterion.score(node.stats)
+	minGain := 1e-12 * (1.0 + abs64(parentScore))
+	samples := make([]int, n)
+	leftStats, rightStats := make([]float64, len(node.stats)), make([]float64, len(node.stats))
+	for _, j := range g.candidateFeatures() {
+		if node.hist != nil {
+			g.findSplitHistogram(node, j, parentScore, minGain, leftStats, rightStats)
+			continue
+		}
+		column := g.set.columns[j]
+		for s := range leftStats {
+			leftStats[s] = 0.0
+		}
+		if g.randomThresholds {
+			// The missing values are skipped by the comparisons.
+			lower, upper := float32(math.Inf(1)), float32(math.Inf(-1))
+			for _, i := range node.samples {
+				if column[i] < lower {
+					lower = column[i]
+				}
+				if column[i] > upper {
+					upper = column[i]
+				}
+			}
+			if !(lower < upper) {
+				continue
+			}
+			threshold := lower + g.rng.Float32()*(upper-lower)
+			if !(threshold < upper) {
+				threshold = lower
+			}
+			nleft := 0
+			for _, i := range node.samples {
+				if !(column[i] > threshold) {
+					g.criterion.addSample(leftStats, i)
+					nleft++
+				}
+			}
+			if nleft < g.minSamplesLeaf || n-nleft < g.minSamplesLeaf {
+				continue
+			}
+			for s := range rightStats {
+				rightStats[s] = node.stats[s] - leftStats[s]
+			}
+			gain := g.criterion.score(leftStats) + g.criterion.score(rightStats) - parentScore
+			node.update(j, threshold, gain, minGain)
+			continue
+		}
+		copy(samples, node.samples)
+		sort.Slice(samples, func(p, q int) bool {
+			return lessNaNFirst(column[samples[p]], column[samples[q]])
+		})
+		for k := 1; k < n; k++ {
+			g.criterion.addSample(leftStats, samples[k-1])
+			if k < g.minSamplesLeaf || n-k < g.minSamplesLeaf {
+				continue
+			}
+			lower, upper := column[samples[k-1]], column[samples[k]]
+			if math.IsNaN(float64(lower)) || lower == upper {
+				continue
+			}
+			for s := range rightStats {
+				rightStats[s] = node.stats[s] - leftStats[s]
+			}
+			gain := g.criterion.score(leftStats) + g.criterion.score(rightStats) - parentScore
+			node.update(j, splitThreshold(lower, upper), gain, minGain)
+		}
+	}
+}
+
+// grow returns a new tree grown on the given samples.
+func (g *treeGrower) grow(samples []int) (*Leaf, error) {
+	rootStats := g.sumStats(samples)
+	root, err := NewTerminalLeaf(g.criterion.value(rootStats))
+	if err != nil {
+		return nil, err
+	}
+	rootNode := &growerNode{
+		samples: samples,
+		depth:   0,
+		stats:   rootStats,
+	}
+	if g.bins != nil {
+		rootNode.hist = g.buildHistogram(samples)
+	}
+	g.findSplit(rootNode)
+	candidates := []*growerNode{rootNode}
+	for nleaves := 1; g.maxLeaves <= 0 || nleaves < g.maxLeaves; nleaves++ {
+		best := -1
+		for c, node := range candidates {
+			if node.splittable && (best < 0 || node.gain > candidates[best].gain) {
+				best = c
+			}
+		}
+		if best < 0 {
+			break
+		}
+		node := candidates[best]
+		candidates = append(candidates[:best], candidates[best+1:]...)
+		column := g.set.columns[node.column]
+		leftSamples, rightSamples := []int{}, []int{}
+		for _, i := range node.samples {
+			if !(column[i] > node.threshold) {
+				leftSamples = append(leftSamples, i)
+			} else {
+				rightSamples = append(rightSamples, i)
+			}
+		}
+		leftStats, rightStats := g.sumStats(leftSamples), g.sumStats(rightSamples)
+		leaf, err := NewLeaf(g.set.featureIDs[node.column], node.threshold, g.criterion.value(leftStats), g.criterion.value(rightStats))
+		if err != nil {
+			return nil, err
+		}
+		if node.parent == nil {
+			root = leaf
+		} else if node.isLeft {
+			node.parent.SetLeft(leaf)
+		} else {
+			node.parent.SetRight(leaf)
+		}
+		leftNode := &growerNode{samples: leftSamples, depth: node.depth + 1, stats: leftStats, parent: leaf, isLeft: true}
+		rightNode := &growerNode{samples: rightSamples, depth: node.depth + 1, stats: rightStats, parent: leaf, isLeft: false}
+		if node.hist != nil {
+			if len(leftSamples) <= len(rightSamples) {
+				leftNode.hist = g.buildHistogram(leftSamples)
+				rightNode.hist = subtractHistogram(node.hist, leftNode.hist)
+			} else {
+				rightNode.hist = g.buildHistogram(rightSamples)
+				leftNode.hist = subtractHistogram(node.hist, rightNode.hist)
+			}
+			node.hist = nil
+		}
+		g.findSplit(leftNode)
+		g.findSplit(rightNode)
+		candidates = append(candidates, leftNode, rightNode)
+	}
+	return root, nil
+}
+
+// lessNaNFirst returns true if x < y in the order where NaN precedes the other values.
+func lessNaNFirst(x, y float32) bool {
+	return x < y || (math.IsNaN(float64(x)) && !math.IsNaN(float64(y)))
+}
+
+func abs64(x float64) float64 {
+	if x < 0.0 {
+		return -x
+	}
+	return x
+}
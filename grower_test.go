@@ -0,0 +1,43 @@
+package confeito
+
+import (
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestNewTrainingSet(t *testing.T) {
+	goassert.New(t, "dataset must not be empty").ExpectError(newTrainingSet([]FeatureVector{}))
+	set := goassert.New(t).SucceedNew(newTrainingSet([]FeatureVector{
+		DenseFeatureVector{1.0, 2.0},
+		SparseFeatureVector{KeyValue{0, -1.0}, KeyValue{3, 4.0}},
+		DenseFeatureVector{},
+	})).(*trainingSet)
+	goassert.New(t, 3).Equal(set.nsamples)
+	goassert.New(t, 3).Equal(set.nfeatures())
+	goassert.New(t, []FeatureID{0, 1, 3}).Equal(set.featureIDs)
+	goassert.New(t, [][]float32{
+		{1.0, -1.0, 0.0},
+		{2.0, 0.0, 0.0},
+		{0.0, 4.0, 0.0},
+	}).Equal(set.columns)
+
+	// The sparse feature IDs are remapped to the columns, so the huge ones do not allocate the columns up to them.
+	set = goassert.New(t).SucceedNew(newTrainingSet([]FeatureVector{
+		SparseFeatureVector{KeyValue{4000000000, 1.0}},
+		SparseFeatureVector{KeyValue{5, 2.0}, KeyValue{4000000000, -1.0}},
+	})).(*trainingSet)
+	goassert.New(t, []FeatureID{5, 4000000000}).Equal(set.featureIDs)
+	goassert.New(t, [][]float32{{0.0, 2.0}, {1.0, -1.0}}).Equal(set.columns)
+	tree := goassert.New(t).SucceedNew(NewLeaf(4000000000, 0.0, float32(0.0), float32(3.0))).(*Leaf)
+	goassert.New(t).SucceedWithoutError(tree.SetLeft(goassert.New(t).SucceedNew(NewLeaf(6, 0.0, float32(1.0), float32(2.0))).(*Leaf)))
+	goassert.New(t, float32(3.0), float32(1.0)).Equal(set.predict(tree, 0), set.predict(tree, 1))
+}
+
+func TestSplitThreshold(t *testing.T) {
+	goassert.New(t, float32(1.5)).Equal(splitThreshold(1.0, 2.0))
+	goassert.New(t, float32(-0.5)).Equal(splitThreshold(-1.0, 0.0))
+	lower := float32(1.0)
+	upper := lower + lower*1.1920929e-07
+	goassert.New(t, lower).Equal(splitThreshold(lower, upper))
+}
@@ -0,0 +1,182 @@
+package confeito
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// MaxHistogramBins is the maximum number of bins of each feature for histogram-based split finding.
+const MaxHistogramBins = 255
+
+// The maximum number of values sampled from each feature for computing the quantiles.
+const _HISTOGRAM_SKETCH_SIZE = 1 << 16
+
+// featureBins is the binned features of a trainingSet.
+// Bin b of feature j has the values in (thresholds[j][b-1], thresholds[j][b]], and the last bin has no upper bound.
+// Thus, splitting feature j between bin b and b+1 is equivalent to threshold thresholds[j][b].
+// The missing (NaN) values are in bin 0, so they go to the left leaf at every split.
+type featureBins struct {
+	thresholds [][]float32
+	bins       [][]uint8
+}
+
+// quantileThresholds returns at most maxBins-1 thresholds dividing column into the bins of almost equal number of values.
+// If column has many values, then the quantiles are estimated on the evenly spaced samples.
+// The missing (NaN) values are ignored.
+func quantileThresholds(column []float32, maxBins int) []float32 {
+	stride := (len(column) + _HISTOGRAM_SKETCH_SIZE - 1) / _HISTOGRAM_SKETCH_SIZE
+	sketch := make([]float32, 0, (len(column)+stride-1)/stride)
+	for i := 0; i < len(column); i += stride {
+		if !math.IsNaN(float64(column[i])) {
+			sketch = append(sketch, column[i])
+		}
+	}
+	sort.Slice(sketch, func(p, q int) bool {
+		return sketch[p] < sketch[q]
+	})
+	values, counts := []float32{}, []int{}
+	for _, value := range sketch {
+		if len(values) > 0 && values[len(values)-1] == value {
+			counts[len(counts)-1]++
+		} else {
+			values, counts = append(values, value), append(counts, 1)
+		}
+	}
+	thresholds := []float32{}
+	if len(values) <= maxBins {
+		for k := 1; k < len(values); k++ {
+			thresholds = append(thresholds, splitThreshold(values[k-1], values[k]))
+		}
+		return thresholds
+	}
+	cumsum := 0
+	for k := 0; k < len(values)-1 && len(thresholds) < maxBins-1; k++ {
+		cumsum += counts[k]
+		if cumsum*maxBins >= (len(thresholds)+1)*len(sketch) {
+			thresholds = append(thresholds, splitThreshold(values[k], values[k+1]))
+		}
+	}
+	return thresholds
+}
+
+// newFeatureBins returns a new featureBins of set with at most maxBins bins for each feature.
+func newFeatureBins(set *trainingSet, maxBins int) *featureBins {
+	thresholds, bins := make([][]float32, set.nfeatures()), make([][]uint8, set.nfeatures())
+	for j, column := range set.columns {
+		thresholds[j] = quantileThresholds(column, maxBins)
+		bins[j] = make([]uint8, len(column))
+		for i, value := range column {
+			if math.IsNaN(float64(value)) {
+				continue
+			}
+			bins[j][i] = uint8(sort.Search(len(thresholds[j]), func(b int) bool {
+				return thresholds[j][b] >= value
+			}))
+		}
+	}
+	return &featureBins{
+		thresholds: thresholds,
+		bins:       bins,
+	}
+}
+
+// newHistogramBins returns a new featureBins of set if maxBins is positive, otherwise nil for the exact split finding.
+//
+// This function returns an error if maxBins is not in [0, MaxHistogramBins].
+func newHistogramBins(set *trainingSet, maxBins int) (*featureBins, error) {
+	if err := validateMaxBins(maxBins); err != nil {
+		return nil, err
+	}
+	if maxBins == 0 {
+		return nil, nil
+	}
+	return newFeatureBins(set, maxBins), nil
+}
+
+// validateMaxBins returns an error if maxBins is not in [0, MaxHistogramBins].
+func validateMaxBins(maxBins int) error {
+	if !(0 <= maxBins && maxBins <= MaxHistogramBins) {
+		return fmt.Errorf("MaxBins must be in [0, %d]", MaxHistogramBins)
+	}
+	return nil
+}
+
+// nbins returns the number of bins of feature j.
+func (fb *featureBins) nbins(j int) int {
+	return len(fb.thresholds[j]) + 1
+}
+
+// buildHistogram returns the histograms of the given samples.
+// The histogram of feature j has the slot of each bin, which is the number of samples followed by the statistics vector.
+func (g *treeGrower) buildHistogram(samples []int) [][]float64 {
+	slotLen := g.criterion.statsLen() + 1
+	hist := make([][]float64, len(g.bins.bins))
+	for j, bins := range g.bins.bins {
+		hist[j] = make([]float64, g.bins.nbins(j)*slotLen)
+		for _, i := range samples {
+			slot := hist[j][int(bins[i])*slotLen : (int(bins[i])+1)*slotLen]
+			slot[0]++
+			g.criterion.addSample(slot[1:], i)
+		}
+	}
+	return hist
+}
+
+// subtractHistogram subtracts the histograms child from parent in place, and returns parent.
+// This gives the histograms of the sibling of child without scanning its samples.
+func subtractHistogram(parent, child [][]float64) [][]float64 {
+	for j := range parent {
+		for s := range parent[j] {
+			parent[j][s] -= child[j][s]
+		}
+	}
+	return parent
+}
+
+// findSplitHistogram searches the best split of node on feature j with the histograms of node.
+func (g *treeGrower) findSplitHistogram(node *growerNode, j int, parentScore, minGain float64, leftStats, rightStats []float64) {
+	slotLen := len(leftStats) + 1
+	hist, n := node.hist[j], len(node.samples)
+	nbins := len(hist) / slotLen
+	for s := range leftStats {
+		leftStats[s] = 0.0
+	}
+	lowerBin, upperBin := -1, -1
+	for b := 0; b < nbins; b++ {
+		if hist[b*slotLen] > 0.0 {
+			if lowerBin < 0 {
+				lowerBin = b
+			}
+			upperBin = b
+		}
+	}
+	if lowerBin == upperBin {
+		return
+	}
+	splitBin := -1
+	if g.randomThresholds {
+		splitBin = lowerBin + g.rng.Intn(upperBin-lowerBin)
+	}
+	nleft := 0
+	for b := lowerBin; b < upperBin; b++ {
+		slot := hist[b*slotLen : (b+1)*slotLen]
+		nleft += int(slot[0])
+		for s := range leftStats {
+			leftStats[s] += slot[s+1]
+		}
+		if splitBin >= 0 && b != splitBin {
+			continue
+		} else if splitBin < 0 && slot[0] == 0.0 {
+			continue
+		}
+		if nleft < g.minSamplesLeaf || n-nleft < g.minSamplesLeaf {
+			continue
+		}
+		for s := range rightStats {
+			rightStats[s] = node.stats[s] - leftStats[s]
+		}
+		gain := g.criterion.score(leftStats) + g.criterion.score(rightStats) - parentScore
+		node.update(j, g.bins.thresholds[j][b], gain, minGain)
+	}
+}
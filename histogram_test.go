@@ -0,0 +1,132 @@
+package confeito
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestQuantileThresholds(t *testing.T) {
+	goassert.New(t, []float32{}).Equal(quantileThresholds([]float32{1.0, 1.0}, 4))
+	goassert.New(t, []float32{1.5, 2.5}).Equal(quantileThresholds([]float32{3.0, 1.0, 2.0, 1.0}, 4))
+	column := make([]float32, 1000)
+	for i := range column {
+		column[i] = float32(i % 100)
+	}
+	thresholds := quantileThresholds(column, 4)
+	goassert.New(t, []float32{24.5, 49.5, 74.5}).Equal(thresholds)
+	rng := rand.New(rand.NewSource(0))
+	column = make([]float32, 3*_HISTOGRAM_SKETCH_SIZE)
+	for i := range column {
+		column[i] = rng.Float32()
+	}
+	thresholds = quantileThresholds(column, MaxHistogramBins)
+	goassert.New(t, MaxHistogramBins-1).Equal(len(thresholds))
+	for b := 1; b < len(thresholds); b++ {
+		goassert.New(t, true).Equal(thresholds[b-1] < thresholds[b])
+	}
+}
+
+func TestFeatureBins(t *testing.T) {
+	set := goassert.New(t).SucceedNew(newTrainingSet([]FeatureVector{
+		DenseFeatureVector{3.0, 0.0},
+		DenseFeatureVector{1.0, 0.0},
+		DenseFeatureVector{2.0, 0.0},
+		DenseFeatureVector{1.0, 0.0},
+	})).(*trainingSet)
+	fb := newFeatureBins(set, 4)
+	goassert.New(t, [][]float32{{1.5, 2.5}, {}}).Equal(fb.thresholds)
+	goassert.New(t, [][]uint8{{2, 0, 1, 0}, {0, 0, 0, 0}}).Equal(fb.bins)
+	goassert.New(t, 3).Equal(fb.nbins(0))
+	goassert.New(t, 1).Equal(fb.nbins(1))
+	goassert.New(t, "MaxBins must be in [0, 255]").ExpectError(newHistogramBins(set, 256))
+	goassert.New(t, (*featureBins)(nil)).EqualWithoutError(newHistogramBins(set, 0))
+}
+
+func TestHistogramSubtraction(t *testing.T) {
+	X, y := newRandomForestDataset(100, false)
+	set := goassert.New(t).SucceedNew(newTrainingSet(X)).(*trainingSet)
+	g := &treeGrower{set: set, criterion: &mseCriterion{y: y}, bins: newFeatureBins(set, 16)}
+	samples, left, right := []int{}, []int{}, []int{}
+	for i := range X {
+		samples = append(samples, i)
+		if i%3 == 0 {
+			left = append(left, i)
+		} else {
+			right = append(right, i)
+		}
+	}
+	expected := g.buildHistogram(right)
+	actual := subtractHistogram(g.buildHistogram(samples), g.buildHistogram(left))
+	for j := range expected {
+		for s := range expected[j] {
+			if d := expected[j][s] - actual[j][s]; d < -1e-9 || d > 1e-9 {
+				t.Fatalf("histogram mismatch at feature %d slot %d: %g != %g", j, s, expected[j][s], actual[j][s])
+			}
+		}
+	}
+}
+
+func TestCARTTrainerHistogram(t *testing.T) {
+	X := make([]FeatureVector, 200)
+	y := make([]float32, len(X))
+	for i := range X {
+		x := DenseFeatureVector{float32(i % 10), float32(i % 7)}
+		X[i], y[i] = x, x[0]*x[1]
+	}
+	trainer := NewCARTTrainer(CriterionMSE)
+	trainer.MaxLeaves = 16
+	exact := goassert.New(t).SucceedNew(trainer.Train(X, y)).(*Leaf)
+	trainer.MaxBins = 16
+	histogram := goassert.New(t).SucceedNew(trainer.Train(X, y)).(*Leaf)
+	goassert.New(t, exact.String()).Equal(histogram.String())
+	trainer.MaxBins = 256
+	goassert.New(t, "MaxBins must be in [0, 255]").ExpectError(trainer.Train(X, y))
+}
+
+func TestTrainersHistogram(t *testing.T) {
+	X, y := newRandomForestDataset(1000, false)
+	gbm := NewGradientBoostingTrainer(SquaredLoss{})
+	gbm.NumTrees, gbm.MaxLeaves, gbm.MaxBins = 50, ForestMaxLeaves, 32
+	trees := goassert.New(t).SucceedNew(gbm.Train(X, y)).([]*Leaf)
+	forest := goassert.New(t).SucceedNew(NewForestFromTrees(trees...)).(*Forest)
+	mse := 0.0
+	for i, x := range X {
+		score := goassert.New(t).SucceedNew(forest.PredictSum(x)).(float32)
+		mse += float64((score-y[i])*(score-y[i])) / float64(len(X))
+	}
+	if mse > 0.01 {
+		t.Errorf("too large training MSE: %g", mse)
+	}
+	rf := NewExtraTreesTrainer(CriterionMSE)
+	rf.NumTrees, rf.MaxBins = 10, 32
+	_, _, err := rf.Train(X, y)
+	goassert.New(t).SucceedWithoutError(err)
+}
+
+func benchmarkCARTTrainer(b *testing.B, maxBins int) {
+	rng := rand.New(rand.NewSource(0))
+	X, y := make([]FeatureVector, 100000), make([]float32, 100000)
+	for i := range X {
+		x := make(DenseFeatureVector, 8)
+		for j := range x {
+			x[j] = rng.Float32()
+		}
+		X[i], y[i] = x, x[0]*x[1]+x[2]
+	}
+	trainer := NewCARTTrainer(CriterionMSE)
+	trainer.MaxLeaves, trainer.MaxBins = ForestMaxLeaves, maxBins
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		trainer.Train(X, y)
+	}
+}
+
+func BenchmarkCARTTrainerExact(b *testing.B) {
+	benchmarkCARTTrainer(b, 0)
+}
+
+func BenchmarkCARTTrainerHistogram(b *testing.B) {
+	benchmarkCARTTrainer(b, MaxHistogramBins)
+}
@@ -0,0 +1,242 @@
+package confeito
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// rankByScores returns the indices sorted in descending order of scores.
+// The ties are broken by the original order.
+func rankByScores(indices []int, scores []float32) []int {
+	ranked := append([]int{}, indices...)
+	sort.SliceStable(ranked, func(p, q int) bool {
+		return scores[ranked[p]] > scores[ranked[q]]
+	})
+	return ranked
+}
+
+// dcgGain returns the gain 2^relevance - 1 of DCG.
+func dcgGain(relevance float32) float64 {
+	return math.Exp2(float64(relevance)) - 1.0
+}
+
+// dcgDiscount returns the discount 1/log2(rank + 2) of DCG at 0-origin rank, or 0 if rank is not less than k.
+func dcgDiscount(rank, k int) float64 {
+	if rank >= k {
+		return 0.0
+	}
+	return 1.0 / math.Log2(float64(rank)+2.0)
+}
+
+// idealDCG returns the ideal DCG@k of the documents indices.
+func idealDCG(indices []int, relevance []float32, k int) float64 {
+	idcg := 0.0
+	for rank, i := range rankByScores(indices, relevance) {
+		idcg += dcgGain(relevance[i]) * dcgDiscount(rank, k)
+	}
+	return idcg
+}
+
+// NDCG returns NDCG@k (Normalized Discounted Cumulative Gain) of the ranking by scores with relevance labels.
+// The gain of a document is 2^relevance - 1.
+// If k is 0, then all documents are evaluated.
+// If all relevance labels are 0, then this returns 1.0.
+//
+// This function returns an error if the lengths of scores and relevance are different, relevance has a non-finite label, or k is negative.
+func NDCG(scores, relevance []float32, k int) (float64, error) {
+	if len(scores) != len(relevance) {
+		return 0.0, fmt.Errorf("the number of scores and relevance labels must be equal")
+	}
+	for i, r := range relevance {
+		if math.IsNaN(float64(r)) || math.IsInf(float64(r), 0) {
+			return 0.0, fmt.Errorf("relevance label must be finite: relevance[%d]=%g", i, r)
+		}
+	}
+	if k < 0 {
+		return 0.0, fmt.Errorf("k must not be negative")
+	}
+	if k == 0 {
+		k = len(scores)
+	}
+	indices := make([]int, len(scores))
+	for i := range indices {
+		indices[i] = i
+	}
+	idcg := idealDCG(indices, relevance, k)
+	if idcg == 0.0 {
+		return 1.0, nil
+	}
+	dcg := 0.0
+	for rank, i := range rankByScores(indices, scores) {
+		dcg += dcgGain(relevance[i]) * dcgDiscount(rank, k)
+	}
+	return dcg / idcg, nil
+}
+
+// LambdaMARTTrainer is a trainer of LambdaMART (Burges 2010) for learning-to-rank.
+// The trees are boosted with the lambda gradients optimizing NDCG@K.
+type LambdaMARTTrainer struct {
+	// NumTrees is the number of boosted trees.
+	NumTrees int
+	// Shrinkage is the learning rate multiplied to the values of each tree.
+	Shrinkage float32
+	// Lambda is the L2 regularization parameter of the values of terminal leaves.
+	Lambda float64
+	// K is the truncation level of NDCG@K to be optimized.
+	// If it is 0, then all documents of each query are evaluated.
+	K int
+	// MaxDepth is the maximum depth of each tree.
+	// If it is 0, then the depth is unlimited.
+	MaxDepth int
+	// MinSamplesLeaf is the minimum number of samples in each terminal leaf.
+	MinSamplesLeaf int
+	// MaxLeaves is the maximum number of terminal leaves in each tree.
+	// If it is 0, then the number is unlimited.
+	MaxLeaves int
+	// MaxBins is the maximum number of bins of each feature for histogram-based split finding.
+	// If it is 0, then the exact split finding is used.
+	MaxBins int
+}
+
+// NewLambdaMARTTrainer returns a new LambdaMARTTrainer.
+// The default parameters are 100 trees, shrinkage 0.1, lambda 1.0, NDCG@10, and at most 8 leaves and 1 sample in each terminal leaf.
+func NewLambdaMARTTrainer() *LambdaMARTTrainer {
+	return &LambdaMARTTrainer{
+		NumTrees:       100,
+		Shrinkage:      0.1,
+		Lambda:         1.0,
+		K:              10,
+		MaxDepth:       0,
+		MinSamplesLeaf: 1,
+		MaxLeaves:      8,
+		MaxBins:        0,
+	}
+}
+
+// validate returns an error if trainer has an illegal parameter.
+func (trainer *LambdaMARTTrainer) validate() error {
+	if trainer.K < 0 {
+		return fmt.Errorf("K must not be negative")
+	}
+	return (&GradientBoostingTrainer{
+		Loss:           SquaredLoss{},
+		NumTrees:       trainer.NumTrees,
+		Shrinkage:      trainer.Shrinkage,
+		Lambda:         trainer.Lambda,
+		MaxDepth:       trainer.MaxDepth,
+		MinSamplesLeaf: trainer.MinSamplesLeaf,
+		MaxLeaves:      trainer.MaxLeaves,
+		MaxBins:        trainer.MaxBins,
+	}).validate()
+}
+
+// groupQueries returns the indices of the documents of each query in order of the first appearance.
+func groupQueries(queryIDs []int) [][]int {
+	queries, positions := [][]int{}, make(map[int]int)
+	for i, qid := range queryIDs {
+		q, ok := positions[qid]
+		if !ok {
+			q = len(queries)
+			positions[qid] = q
+			queries = append(queries, []int{})
+		}
+		queries[q] = append(queries[q], i)
+	}
+	return queries
+}
+
+// lambdaGradients sets the lambda gradients and their derivatives of the documents of a query to grad and hess.
+func lambdaGradients(documents []int, relevance, scores []float32, k int, grad, hess []float64) {
+	for _, i := range documents {
+		grad[i], hess[i] = 0.0, 0.0
+	}
+	idcg := idealDCG(documents, relevance, k)
+	if idcg == 0.0 {
+		return
+	}
+	ranked := rankByScores(documents, scores)
+	for p, i := range ranked {
+		for q, j := range ranked {
+			if !(relevance[i] > relevance[j]) || (p >= k && q >= k) {
+				continue
+			}
+			delta := math.Abs((dcgGain(relevance[i]) - dcgGain(relevance[j])) * (dcgDiscount(p, k) - dcgDiscount(q, k)) / idcg)
+			rho := 1.0 / (1.0 + math.Exp(float64(scores[i]-scores[j])))
+			grad[i] -= rho * delta
+			grad[j] += rho * delta
+			hess[i] += rho * (1.0 - rho) * delta
+			hess[j] += rho * (1.0 - rho) * delta
+		}
+	}
+}
+
+// Train returns the trees trained on dataset X with relevance labels grouped by queryIDs.
+// The documents of a query need not be contiguous.
+// Each terminal leaf has float32 value, and the sum of the values predicted by the trees is the ranking score.
+// Thus, the trees can be enqueued to Forest directly, and the score is given by Forest.PredictSum.
+//
+// This function returns an error if trainer has an illegal parameter, the lengths of X, relevance and queryIDs are different, relevance has a negative label, or at getting feature values of X.
+func (trainer *LambdaMARTTrainer) Train(X []FeatureVector, relevance []float32, queryIDs []int) ([]*Leaf, error) {
+	if err := trainer.validate(); err != nil {
+		return nil, err
+	}
+	if len(X) != len(relevance) || len(X) != len(queryIDs) {
+		return nil, fmt.Errorf("the number of samples, relevance labels and query IDs must be equal")
+	}
+	for i, r := range relevance {
+		if math.IsNaN(float64(r)) || math.IsInf(float64(r), 0) {
+			return nil, fmt.Errorf("relevance label must be finite: relevance[%d]=%g", i, r)
+		}
+		if r < 0.0 {
+			return nil, fmt.Errorf("relevance label must not be negative: relevance[%d]=%g", i, r)
+		}
+	}
+	set, err := newTrainingSet(X)
+	if err != nil {
+		return nil, err
+	}
+	bins, err := newHistogramBins(set, trainer.MaxBins)
+	if err != nil {
+		return nil, err
+	}
+	queries := groupQueries(queryIDs)
+	scores := make([]float32, len(X))
+	criterion := &gradientCriterion{
+		grad:      make([]float64, len(X)),
+		hess:      make([]float64, len(X)),
+		lambda:    trainer.Lambda,
+		shrinkage: trainer.Shrinkage,
+	}
+	grower := &treeGrower{
+		set:            set,
+		criterion:      criterion,
+		maxDepth:       trainer.MaxDepth,
+		minSamplesLeaf: trainer.MinSamplesLeaf,
+		maxLeaves:      trainer.MaxLeaves,
+		bins:           bins,
+	}
+	samples := make([]int, len(X))
+	for i := range samples {
+		samples[i] = i
+	}
+	trees := []*Leaf{}
+	for m := 0; m < trainer.NumTrees; m++ {
+		for _, documents := range queries {
+			k := trainer.K
+			if k == 0 {
+				k = len(documents)
+			}
+			lambdaGradients(documents, relevance, scores, k, criterion.grad, criterion.hess)
+		}
+		tree, err := grower.grow(samples)
+		if err != nil {
+			return nil, err
+		}
+		for i := range scores {
+			scores[i] += set.predict(tree, i).(float32)
+		}
+		trees = append(trees, tree)
+	}
+	return trees, nil
+}
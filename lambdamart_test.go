@@ -0,0 +1,75 @@
+package confeito
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestNDCG(t *testing.T) {
+	relevance := []float32{3.0, 2.0, 3.0, 0.0, 1.0, 2.0}
+	goassert.New(t, 1.0).EqualWithoutError(NDCG([]float32{6.0, 3.0, 5.0, 1.0, 2.0, 4.0}, relevance, 0))
+	ndcg := goassert.New(t).SucceedNew(NDCG([]float32{6.0, 5.0, 4.0, 3.0, 2.0, 1.0}, relevance, 0)).(float64)
+	goassert.New(t, "0.9488").Equal(formatFloat4(ndcg))
+	ndcg = goassert.New(t).SucceedNew(NDCG([]float32{6.0, 5.0, 4.0, 3.0, 2.0, 1.0}, relevance, 2)).(float64)
+	goassert.New(t, "0.7789").Equal(formatFloat4(ndcg))
+	goassert.New(t, 1.0).EqualWithoutError(NDCG([]float32{1.0, 2.0}, []float32{0.0, 0.0}, 0))
+	goassert.New(t, "the number of scores and relevance labels must be equal").ExpectError(NDCG([]float32{1.0}, []float32{}, 0))
+	goassert.New(t, "k must not be negative").ExpectError(NDCG([]float32{}, []float32{}, -1))
+	goassert.New(t, "relevance label must be finite: relevance[1]=NaN").ExpectError(NDCG([]float32{0.0, 1.0}, []float32{1.0, float32(math.NaN())}, 0))
+}
+
+func formatFloat4(x float64) string {
+	return fmt.Sprintf("%.4f", x)
+}
+
+func TestGroupQueries(t *testing.T) {
+	goassert.New(t, [][]int{{0, 1, 4}, {2}, {3, 5}}).Equal(groupQueries([]int{7, 7, 3, 5, 7, 5}))
+}
+
+func TestLambdaMARTTrainer(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	nqueries, ndocuments := 50, 20
+	X, relevance, queryIDs := []FeatureVector{}, []float32{}, []int{}
+	for q := 0; q < nqueries; q++ {
+		for d := 0; d < ndocuments; d++ {
+			x := DenseFeatureVector{rng.Float32(), rng.Float32(), rng.Float32()}
+			X, queryIDs = append(X, x), append(queryIDs, q)
+			relevance = append(relevance, float32(math.Floor(float64(4.0*x[0]*x[1]))))
+		}
+	}
+	trainer := NewLambdaMARTTrainer()
+	trainer.NumTrees, trainer.MaxLeaves = 30, 16
+	trees := goassert.New(t).SucceedNew(trainer.Train(X, relevance, queryIDs)).([]*Leaf)
+	goassert.New(t, 30).Equal(len(trees))
+	forest := goassert.New(t).SucceedNew(NewForestFromTrees(trees...)).(*Forest)
+	meanNDCG := 0.0
+	for q := 0; q < nqueries; q++ {
+		scores := make([]float32, ndocuments)
+		for d := range scores {
+			scores[d] = goassert.New(t).SucceedNew(forest.PredictSum(X[q*ndocuments+d])).(float32)
+		}
+		ndcg := goassert.New(t).SucceedNew(NDCG(scores, relevance[q*ndocuments:(q+1)*ndocuments], 10)).(float64)
+		meanNDCG += ndcg / float64(nqueries)
+	}
+	if meanNDCG < 0.9 {
+		t.Errorf("too small training NDCG@10: %g", meanNDCG)
+	}
+}
+
+func TestLambdaMARTTrainerError(t *testing.T) {
+	X := []FeatureVector{DenseFeatureVector{0.0}}
+	trainer := NewLambdaMARTTrainer()
+	trainer.K = -1
+	goassert.New(t, "K must not be negative").ExpectError(trainer.Train(X, []float32{0.0}, []int{0}))
+	trainer = NewLambdaMARTTrainer()
+	trainer.Shrinkage = 0.0
+	goassert.New(t, "Shrinkage must be positive").ExpectError(trainer.Train(X, []float32{0.0}, []int{0}))
+	goassert.New(t, "the number of samples, relevance labels and query IDs must be equal").ExpectError(NewLambdaMARTTrainer().Train(X, []float32{0.0}, []int{}))
+	goassert.New(t, "relevance label must not be negative: relevance[0]=-1").ExpectError(NewLambdaMARTTrainer().Train(X, []float32{-1.0}, []int{0}))
+	goassert.New(t, "relevance label must be finite: relevance[0]=NaN").ExpectError(NewLambdaMARTTrainer().Train(X, []float32{float32(math.NaN())}, []int{0}))
+	goassert.New(t, "relevance label must be finite: relevance[0]=+Inf").ExpectError(NewLambdaMARTTrainer().Train(X, []float32{float32(math.Inf(1))}, []int{0}))
+}
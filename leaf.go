@@ -1,21 +1,51 @@
 package confeito
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
 
 // Feature ID for a terminal leaf.
 const _FEATURE_ID_TERMINAL_LEAF = _FEATURE_ID_ILLEGAL
 
+// SplitOperator is the type of the comparison operator of the split at a non-terminal leaf.
+type SplitOperator int
+
+const (
+	// LessOrEqual takes the left leaf if feature[featureID] <= threshold.
+	// This is the default operator.
+	LessOrEqual SplitOperator = iota
+	// Less takes the left leaf if feature[featureID] < threshold.
+	Less
+)
+
+// String returns the string representation of op, which is "<=" or "<".
+func (op SplitOperator) String() string {
+	switch op {
+	case LessOrEqual:
+		return "<="
+	case Less:
+		return "<"
+	default:
+		return fmt.Sprintf("SplitOperator(%d)", int(op))
+	}
+}
+
 // Leaf is an element in a tree.
 // It is either of non-terminal or terminal.
 // If it is non-terminal, then it has left and right leaf, otherwise it has a value which can be any object (interface{}).
 //
-// In predicting the value of the given feature, if feature[featureID] <= threshold, then the left leaf is taken, else the right one is taken.
+// In predicting the value of the given feature, if feature[featureID] <= threshold (or feature[featureID] < threshold with operator Less), then the left leaf is taken, else the right one is taken.
 // This process is repeated until the cursor points a terminal leaf, and returns the value of it.
+// Missing (NaN) feature values always go to the left with either operator.
 //
 // Leaf is slow, because it is designed to use manipulating tree structure in training-phase or testing its correctness.
 type Leaf struct {
 	featureID   FeatureID
 	threshold   float32
+	operator    SplitOperator
 	value       interface{}
 	left, right *Leaf
 }
@@ -48,6 +78,68 @@ func NewTerminalLeaf(value interface{}) (*Leaf, error) {
 	}, nil
 }
 
+// Clone returns a deep copy of the tree whose root is l.
+// The values of terminal leaves are copied shallowly.
+func (l *Leaf) Clone() *Leaf {
+	clone := *l
+	if l.left != nil {
+		clone.left = l.left.Clone()
+	}
+	if l.right != nil {
+		clone.right = l.right.Clone()
+	}
+	return &clone
+}
+
+// Depth returns the depth of the tree whose root is l.
+// The depth of a terminal leaf is 0.
+func (l *Leaf) Depth() int {
+	depth := 0
+	l.Walk(PreOrder, func(leaf *Leaf, path LeafPath) error {
+		if depth < len(path) {
+			depth = len(path)
+		}
+		return nil
+	})
+	return depth
+}
+
+// Equal returns true if the tree whose root is l is structurally equal to other, otherwise false.
+// The thresholds and the float32/float64 values of terminal leaves are compared within tolerance, and the other values are compared by reflect.DeepEqual.
+func (l *Leaf) Equal(other *Leaf, tolerance float32) bool {
+	if l == nil || other == nil {
+		return l == other
+	}
+	if l.featureID != other.featureID {
+		return false
+	}
+	if l.IsTerminal() {
+		switch value := l.value.(type) {
+		case float32:
+			otherValue, ok := other.value.(float32)
+			return ok && withinTolerance(float64(value), float64(otherValue), float64(tolerance))
+		case float64:
+			otherValue, ok := other.value.(float64)
+			return ok && withinTolerance(value, otherValue, float64(tolerance))
+		}
+		return reflect.DeepEqual(l.value, other.value)
+	}
+	return l.operator == other.operator && withinTolerance(float64(l.threshold), float64(other.threshold), float64(tolerance)) && l.left.Equal(other.left, tolerance) && l.right.Equal(other.right, tolerance)
+}
+
+func withinTolerance(x, y, tolerance float64) bool {
+	return x == y || (x-y <= tolerance && y-x <= tolerance)
+}
+
+// goesRight returns true if the split of the non-terminal leaf l takes the right leaf for value, otherwise false.
+// NaN is not greater than any threshold, so it goes to the left.
+func (l *Leaf) goesRight(value float32) bool {
+	if l.operator == Less {
+		return value >= l.threshold
+	}
+	return value > l.threshold
+}
+
 // IsTerminal returns true if l is terminal, otherwise false.
 func (l *Leaf) IsTerminal() bool {
 	return l.featureID == _FEATURE_ID_TERMINAL_LEAF
@@ -59,6 +151,28 @@ func (l *Leaf) Left() *Leaf {
 	return l.left
 }
 
+// NumLeaves returns the number of terminal leaves in the tree whose root is l.
+func (l *Leaf) NumLeaves() int {
+	nleaves := 0
+	l.Walk(PreOrder, func(leaf *Leaf, path LeafPath) error {
+		if leaf.IsTerminal() {
+			nleaves++
+		}
+		return nil
+	})
+	return nleaves
+}
+
+// NumNodes returns the number of terminal and non-terminal leaves in the tree whose root is l.
+func (l *Leaf) NumNodes() int {
+	nnodes := 0
+	l.Walk(PreOrder, func(leaf *Leaf, path LeafPath) error {
+		nnodes++
+		return nil
+	})
+	return nnodes
+}
+
 // Predict returns the predicted value of the given feature.
 //
 // This function returns an errors at getting feature values of x.
@@ -66,12 +180,22 @@ func (l *Leaf) Predict(x FeatureVector) (value interface{}, err error) {
 	if l.IsTerminal() {
 		return l.value, nil
 	}
-	if fvalue, _ := x.Get(l.featureID); fvalue > l.threshold {
+	if fvalue, _ := x.Get(l.featureID); l.goesRight(fvalue) {
 		return l.right.Predict(x)
 	}
 	return l.left.Predict(x)
 }
 
+// Operator returns the comparison operator of the split of l.
+//
+// This function returns an error if l is terminal.
+func (l *Leaf) Operator() (SplitOperator, error) {
+	if l.IsTerminal() {
+		return LessOrEqual, fmt.Errorf("terminal leaf does not have operator")
+	}
+	return l.operator, nil
+}
+
 // Right returns the right leaf.
 // If l is terminal, then this returns nil.
 func (l *Leaf) Right() *Leaf {
@@ -92,6 +216,20 @@ func (l *Leaf) SetLeft(left *Leaf) error {
 	return nil
 }
 
+// SetOperator sets the comparison operator of the split of l.
+//
+// This function returns an error if l is terminal, or op is illegal.
+func (l *Leaf) SetOperator(op SplitOperator) error {
+	if l.IsTerminal() {
+		return fmt.Errorf("terminal leaf cannot have operator")
+	}
+	if op != LessOrEqual && op != Less {
+		return fmt.Errorf("illegal operator: %s", op)
+	}
+	l.operator = op
+	return nil
+}
+
 // SetRight sets the right leaf.
 //
 // This function returns an error if l is terminal, or the new leaf is nil.
@@ -111,7 +249,7 @@ func (l *Leaf) String() string {
 	if l.IsTerminal() {
 		return fmt.Sprintf("%g", l.value)
 	}
-	return fmt.Sprintf("(feature[%d] <= %g ? %s : %s)", l.featureID, l.threshold, l.left, l.right)
+	return fmt.Sprintf("(feature[%d] %s %g ? %s : %s)", l.featureID, l.operator, l.threshold, l.left, l.right)
 }
 
 // Threshold returns the threshold with feature ID of l.
@@ -125,6 +263,47 @@ func (l *Leaf) Threshold() (featureID FeatureID, threshold float32, err error) {
 	return l.featureID, l.threshold, nil
 }
 
+// Validate validates the tree whose root is l.
+// SetLeft and SetRight allow a tree to reference itself or share subtrees, which makes Predict loop forever.
+//
+// This function returns a *LeafError at the first malformed leaf in pre-order, which is either of a nil root, a cycle, a subtree shared with another path, a non-terminal leaf with NaN/Inf threshold, an illegal operator or without left or right leaf, and a terminal leaf (having the illegal feature ID) with left or right leaf.
+func (l *Leaf) Validate() error {
+	if l == nil {
+		return &LeafError{Path: LeafPath{}, Reason: "leaf must not be nil"}
+	}
+	return l.validate(LeafPath{}, make(map[*Leaf]LeafPath), make(map[*Leaf]bool))
+}
+
+func (l *Leaf) validate(path LeafPath, visited map[*Leaf]LeafPath, ancestors map[*Leaf]bool) error {
+	if visitedPath, ok := visited[l]; ok {
+		if ancestors[l] {
+			return &LeafError{Path: append(LeafPath{}, path...), Reason: fmt.Sprintf("cycle to %s", visitedPath)}
+		}
+		return &LeafError{Path: append(LeafPath{}, path...), Reason: fmt.Sprintf("shared with %s", visitedPath)}
+	}
+	visited[l], ancestors[l] = append(LeafPath{}, path...), true
+	defer delete(ancestors, l)
+	if l.IsTerminal() {
+		if l.left != nil || l.right != nil {
+			return &LeafError{Path: append(LeafPath{}, path...), Reason: "terminal leaf (having the illegal feature ID) must not have left or right leaf"}
+		}
+		return nil
+	}
+	if math.IsNaN(float64(l.threshold)) || math.IsInf(float64(l.threshold), 0) {
+		return &LeafError{Path: append(LeafPath{}, path...), Reason: fmt.Sprintf("threshold must be finite: %g", l.threshold)}
+	}
+	if l.operator != LessOrEqual && l.operator != Less {
+		return &LeafError{Path: append(LeafPath{}, path...), Reason: fmt.Sprintf("illegal operator: %s", l.operator)}
+	}
+	if l.left == nil || l.right == nil {
+		return &LeafError{Path: append(LeafPath{}, path...), Reason: "non-terminal leaf must have left and right leaf"}
+	}
+	if err := l.left.validate(append(path, false), visited, ancestors); err != nil {
+		return err
+	}
+	return l.right.validate(append(path, true), visited, ancestors)
+}
+
 // Value returns the value of the terminal leaf l.
 //
 // This function returns an error if l is not terminal.
@@ -135,3 +314,80 @@ func (l *Leaf) Value() (value interface{}, err error) {
 	}
 	return l.value, nil
 }
+
+// LeafError is the error at a malformed leaf in a tree.
+type LeafError struct {
+	// Path is the path from the root to the malformed leaf.
+	Path LeafPath
+	// Reason is the reason why the leaf is malformed.
+	Reason string
+}
+
+// Error is for interface error.
+func (err *LeafError) Error() string {
+	return fmt.Sprintf("%s: %s", err.Path, err.Reason)
+}
+
+// LeafPath is the path from the root to a leaf.
+// Each element is false if the path goes to the left leaf, otherwise true.
+type LeafPath []bool
+
+// String returns the human-readable string representation of path like "root.left.right".
+func (path LeafPath) String() string {
+	names := []string{"root"}
+	for _, isRight := range path {
+		if isRight {
+			names = append(names, "right")
+		} else {
+			names = append(names, "left")
+		}
+	}
+	return strings.Join(names, ".")
+}
+
+// WalkOrder is the type of the order of visiting leaves in Walk.
+type WalkOrder int
+
+const (
+	// PreOrder visits a leaf before its left and right leaf.
+	PreOrder WalkOrder = iota
+	// PostOrder visits a leaf after its left and right leaf.
+	PostOrder
+)
+
+// WalkFunc is the type of the function called for each leaf visited by Walk.
+// path is reused by Walk, so it should be copied in order to be retained.
+// If the function returns an error, then Walk stops and returns the error.
+type WalkFunc func(leaf *Leaf, path LeafPath) error
+
+// Walk visits the leaves in the tree whose root is l in order, calling fn for each leaf.
+// The left leaf is visited before the right leaf.
+//
+// This function returns the error returned by fn.
+func (l *Leaf) Walk(order WalkOrder, fn WalkFunc) error {
+	return l.walk(order, fn, LeafPath{})
+}
+
+func (l *Leaf) walk(order WalkOrder, fn WalkFunc, path LeafPath) error {
+	if order == PreOrder {
+		if err := fn(l, path); err != nil {
+			return err
+		}
+	}
+	if l.left != nil {
+		if err := l.left.walk(order, fn, append(path, false)); err != nil {
+			return err
+		}
+	}
+	if l.right != nil {
+		if err := l.right.walk(order, fn, append(path, true)); err != nil {
+			return err
+		}
+	}
+	if order == PostOrder {
+		if err := fn(l, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
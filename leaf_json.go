@@ -0,0 +1,282 @@
+package confeito
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+)
+
+// LeafValueCodec is the interface for encoding and decoding the values of terminal leaves in JSON.
+// Implement this interface in order to round-trip custom value types.
+type LeafValueCodec interface {
+	// EncodeValue returns the JSON representation of value.
+	EncodeValue(value interface{}) (json.RawMessage, error)
+	// DecodeValue returns the value represented by JSON data.
+	DecodeValue(data json.RawMessage) (interface{}, error)
+}
+
+// defaultLeafValueCodec is the type of DefaultLeafValueCodec.
+type defaultLeafValueCodec struct{}
+
+// EncodeValue is for interface LeafValueCodec.
+func (defaultLeafValueCodec) EncodeValue(value interface{}) (json.RawMessage, error) {
+	return json.Marshal(value)
+}
+
+// DecodeValue is for interface LeafValueCodec.
+func (defaultLeafValueCodec) DecodeValue(data json.RawMessage) (interface{}, error) {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if number, ok := value.(json.Number); ok {
+		f, err := number.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return float32(f), nil
+	}
+	return convertJSONNumbers(value)
+}
+
+// convertJSONNumbers returns value decoded with json.Decoder.UseNumber whose numbers in the slices and the maps are converted into float64 as encoding/json does.
+//
+// This function returns an error if a number is out of range of float64.
+func convertJSONNumbers(value interface{}) (interface{}, error) {
+	switch value := value.(type) {
+	case json.Number:
+		return value.Float64()
+	case []interface{}:
+		for i, elem := range value {
+			converted, err := convertJSONNumbers(elem)
+			if err != nil {
+				return nil, err
+			}
+			value[i] = converted
+		}
+	case map[string]interface{}:
+		for key, elem := range value {
+			converted, err := convertJSONNumbers(elem)
+			if err != nil {
+				return nil, err
+			}
+			value[key] = converted
+		}
+	}
+	return value, nil
+}
+
+// DefaultLeafValueCodec is the LeafValueCodec used by Leaf.MarshalJSON and Leaf.UnmarshalJSON.
+// This encodes values with encoding/json, and decodes a number as float32 and the others as encoding/json does into interface{} (e.g., the numbers in arrays and objects are float64).
+// Thus, float32 values round-trip, but float64 values are decoded as float32.
+var DefaultLeafValueCodec LeafValueCodec = defaultLeafValueCodec{}
+
+// leafJSON is the JSON schema of Leaf.
+// A non-terminal leaf is {"feature": featureID, "threshold": threshold, "operator": operator, "left": left, "right": right}, and a terminal leaf is {"value": value}.
+type leafJSON struct {
+	Feature   *FeatureID      `json:"feature,omitempty"`
+	Threshold *float32        `json:"threshold,omitempty"`
+	Operator  string          `json:"operator,omitempty"`
+	Left      json.RawMessage `json:"left,omitempty"`
+	Right     json.RawMessage `json:"right,omitempty"`
+	Value     json.RawMessage `json:"value,omitempty"`
+}
+
+// MarshalLeafJSON returns the JSON representation of the tree whose root is l, encoding the values of terminal leaves with codec.
+// A non-terminal leaf is encoded as {"feature": featureID, "threshold": threshold, "left": left, "right": right}, and a terminal leaf is encoded as {"value": value}.
+// A non-terminal leaf with operator Less has also "operator": "lt", and the operator is LessOrEqual ("le") if omitted.
+//
+// This function returns an error if the tree is malformed (see Leaf.Validate), or at encoding values.
+func MarshalLeafJSON(l *Leaf, codec LeafValueCodec) ([]byte, error) {
+	if err := l.Validate(); err != nil {
+		return nil, err
+	}
+	return l.marshalJSON(codec, LeafPath{})
+}
+
+func (l *Leaf) marshalJSON(codec LeafValueCodec, path LeafPath) ([]byte, error) {
+	if l.IsTerminal() {
+		value, err := codec.EncodeValue(l.value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		return json.Marshal(&leafJSON{Value: value})
+	}
+	left, err := l.left.marshalJSON(codec, append(path, false))
+	if err != nil {
+		return nil, err
+	}
+	right, err := l.right.marshalJSON(codec, append(path, true))
+	if err != nil {
+		return nil, err
+	}
+	featureID, threshold := l.featureID, l.threshold
+	operator := ""
+	if l.operator == Less {
+		operator = "lt"
+	}
+	return json.Marshal(&leafJSON{
+		Feature:   &featureID,
+		Threshold: &threshold,
+		Operator:  operator,
+		Left:      left,
+		Right:     right,
+	})
+}
+
+// UnmarshalLeafJSON returns a new tree represented by JSON data, decoding the values of terminal leaves with codec.
+// See MarshalLeafJSON for the schema.
+//
+// This function returns an error if data is malformed, or at decoding values.
+func UnmarshalLeafJSON(data []byte, codec LeafValueCodec) (*Leaf, error) {
+	return unmarshalLeafJSON(data, codec, LeafPath{})
+}
+
+func unmarshalLeafJSON(data []byte, codec LeafValueCodec, path LeafPath) (*Leaf, error) {
+	var lj leafJSON
+	if err := json.Unmarshal(data, &lj); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	if lj.Value != nil {
+		if lj.Feature != nil || lj.Threshold != nil || lj.Operator != "" || lj.Left != nil || lj.Right != nil {
+			return nil, fmt.Errorf("%s: terminal leaf must have only value", path)
+		}
+		value, err := codec.DecodeValue(lj.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		return NewTerminalLeaf(value)
+	}
+	if lj.Feature == nil || lj.Threshold == nil || lj.Left == nil || lj.Right == nil {
+		return nil, fmt.Errorf("%s: non-terminal leaf must have feature, threshold, left and right", path)
+	}
+	if *lj.Feature == _FEATURE_ID_ILLEGAL {
+		return nil, fmt.Errorf("%s: featureID must be valid", path)
+	}
+	operator := LessOrEqual
+	switch lj.Operator {
+	case "", "le":
+	case "lt":
+		operator = Less
+	default:
+		return nil, fmt.Errorf("%s: illegal operator: %q", path, lj.Operator)
+	}
+	left, err := unmarshalLeafJSON(lj.Left, codec, append(path, false))
+	if err != nil {
+		return nil, err
+	}
+	right, err := unmarshalLeafJSON(lj.Right, codec, append(path, true))
+	if err != nil {
+		return nil, err
+	}
+	return &Leaf{
+		featureID: *lj.Feature,
+		threshold: *lj.Threshold,
+		operator:  operator,
+		left:      left,
+		right:     right,
+	}, nil
+}
+
+// MarshalJSON is for interface json.Marshaler.
+// This encodes the values of terminal leaves with DefaultLeafValueCodec.
+// See MarshalLeafJSON for the schema.
+func (l *Leaf) MarshalJSON() ([]byte, error) {
+	return MarshalLeafJSON(l, DefaultLeafValueCodec)
+}
+
+// UnmarshalJSON is for interface json.Unmarshaler.
+// This decodes the values of terminal leaves with DefaultLeafValueCodec.
+// See MarshalLeafJSON for the schema.
+func (l *Leaf) UnmarshalJSON(data []byte) error {
+	leaf, err := UnmarshalLeafJSON(data, DefaultLeafValueCodec)
+	if err != nil {
+		return err
+	}
+	*l = *leaf
+	return nil
+}
+
+// leafGob is a leaf in the gob representation of Leaf, which is the sequence of the leaves in pre-order.
+// Value is the value of a terminal leaf, and nil for a non-terminal leaf.
+type leafGob struct {
+	Feature   FeatureID
+	Threshold float32
+	Operator  SplitOperator
+	Value     interface{}
+}
+
+func (l *Leaf) appendGob(leaves []leafGob) []leafGob {
+	if l.IsTerminal() {
+		return append(leaves, leafGob{Feature: l.featureID, Value: l.value})
+	}
+	leaves = append(leaves, leafGob{Feature: l.featureID, Threshold: l.threshold, Operator: l.operator})
+	return l.right.appendGob(l.left.appendGob(leaves))
+}
+
+// leafFromGob returns a new tree whose root is leaves[p], and the position following the tree.
+func leafFromGob(leaves []leafGob, p int) (*Leaf, int, error) {
+	if p >= len(leaves) {
+		return nil, p, fmt.Errorf("tree is truncated")
+	}
+	if leaves[p].Feature == _FEATURE_ID_TERMINAL_LEAF {
+		return &Leaf{featureID: _FEATURE_ID_TERMINAL_LEAF, value: leaves[p].Value}, p + 1, nil
+	}
+	left, q, err := leafFromGob(leaves, p+1)
+	if err != nil {
+		return nil, q, err
+	}
+	right, q, err := leafFromGob(leaves, q)
+	if err != nil {
+		return nil, q, err
+	}
+	return &Leaf{
+		featureID: leaves[p].Feature,
+		threshold: leaves[p].Threshold,
+		operator:  leaves[p].Operator,
+		left:      left,
+		right:     right,
+	}, q, nil
+}
+
+// GobEncode is for interface gob.GobEncoder.
+// The values of terminal leaves are encoded as interface values by encoding/gob, so the types other than the basic ones must be registered with gob.Register.
+// Unlike MarshalJSON, any registered value type (e.g., float64 and int) round-trips.
+//
+// This function returns an error if the tree is malformed (see Leaf.Validate), or at encoding values.
+func (l *Leaf) GobEncode() ([]byte, error) {
+	if err := l.Validate(); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(l.appendGob([]leafGob{})); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode is for interface gob.GobDecoder.
+// See GobEncode for the value types.
+//
+// This function returns an error if data is malformed, or at decoding values.
+func (l *Leaf) GobDecode(data []byte) error {
+	var leaves []leafGob
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&leaves); err != nil {
+		return err
+	}
+	leaf, p, err := leafFromGob(leaves, 0)
+	if err != nil {
+		return err
+	}
+	if p != len(leaves) {
+		return fmt.Errorf("%d leaves follow the tree", len(leaves)-p)
+	}
+	if err := leaf.Validate(); err != nil {
+		return err
+	}
+	*l = *leaf
+	return nil
+}
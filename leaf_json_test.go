@@ -0,0 +1,141 @@
+package confeito
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestLeafJSON(t *testing.T) {
+	tree := newTestTree(t)
+	data := goassert.New(t).SucceedNew(json.Marshal(tree)).([]byte)
+	goassert.New(t, `{"feature":1,"threshold":-0.5,"left":{"feature":2,"threshold":0.5,"left":{"value":1},"right":{"value":2}},"right":{"feature":3,"threshold":1.5,"left":{"value":3},"right":{"feature":4,"threshold":2.5,"left":{"value":4},"right":{"value":5}}}}`).Equal(string(data))
+	decoded := &Leaf{}
+	goassert.New(t).SucceedWithoutError(json.Unmarshal(data, decoded))
+	goassert.New(t, true).Equal(tree.Equal(decoded, 0.0))
+	goassert.New(t, float32(1.0)).EqualWithoutError(decoded.Left().Left().Value())
+
+	terminal := goassert.New(t).SucceedNew(NewTerminalLeaf(float32(0.1))).(*Leaf)
+	data = goassert.New(t).SucceedNew(json.Marshal(terminal)).([]byte)
+	goassert.New(t, `{"value":0.1}`).Equal(string(data))
+	goassert.New(t).SucceedWithoutError(json.Unmarshal(data, decoded))
+	goassert.New(t, float32(0.1)).EqualWithoutError(decoded.Value())
+	for _, value := range []interface{}{nil, "a", true, []interface{}{"b"}, []interface{}{0.25, 0.75}, map[string]interface{}{"a": 1.5, "b": []interface{}{2.0, "c"}}} {
+		terminal = goassert.New(t).SucceedNew(NewTerminalLeaf(value)).(*Leaf)
+		data = goassert.New(t).SucceedNew(json.Marshal(terminal)).([]byte)
+		goassert.New(t).SucceedWithoutError(json.Unmarshal(data, decoded))
+		goassert.New(t, value).EqualWithoutError(decoded.Value())
+	}
+	// The numbers in arrays are decoded as float64 as encoding/json does.
+	terminal = goassert.New(t).SucceedNew(NewTerminalLeaf([]float32{0.25, 0.75})).(*Leaf)
+	data = goassert.New(t).SucceedNew(json.Marshal(terminal)).([]byte)
+	goassert.New(t).SucceedWithoutError(json.Unmarshal(data, decoded))
+	goassert.New(t, []interface{}{0.25, 0.75}).EqualWithoutError(decoded.Value())
+	goassert.New(t).ExpectError(DefaultLeafValueCodec.DecodeValue(json.RawMessage(`[1e400]`)))
+
+	less := newTestTree(t)
+	goassert.New(t).SucceedWithoutError(less.Right().SetOperator(Less))
+	data = goassert.New(t).SucceedNew(json.Marshal(less.Right())).([]byte)
+	goassert.New(t, `{"feature":3,"threshold":1.5,"operator":"lt","left":{"value":3},"right":{"feature":4,"threshold":2.5,"left":{"value":4},"right":{"value":5}}}`).Equal(string(data))
+	goassert.New(t).SucceedWithoutError(json.Unmarshal(data, decoded))
+	goassert.New(t, true).Equal(less.Right().Equal(decoded, 0.0))
+
+	cyclic := newTestTree(t)
+	cyclic.Left().SetLeft(cyclic)
+	goassert.New(t, "json: error calling MarshalJSON for type *confeito.Leaf: root.left.left: cycle to root").ExpectError(json.Marshal(cyclic))
+	for _, c := range []struct {
+		data, err string
+	}{
+		{`[]`, "root: json: cannot unmarshal array into Go value of type confeito.leafJSON"},
+		{`{}`, "root: non-terminal leaf must have feature, threshold, left and right"},
+		{`{"value":1,"feature":0}`, "root: terminal leaf must have only value"},
+		{`{"value":1,"operator":"lt"}`, "root: terminal leaf must have only value"},
+		{`{"feature":0,"threshold":0,"operator":"<","left":{"value":1},"right":{"value":2}}`, "root: illegal operator: \"<\""},
+		{`{"feature":0,"threshold":0,"left":{"value":1}}`, "root: non-terminal leaf must have feature, threshold, left and right"},
+		{`{"feature":4294967295,"threshold":0,"left":{"value":1},"right":{"value":2}}`, "root: featureID must be valid"},
+		{`{"feature":0,"threshold":0,"left":{"value":1},"right":{"feature":1}}`, "root.right: non-terminal leaf must have feature, threshold, left and right"},
+	} {
+		goassert.New(t, c.err).ExpectError(UnmarshalLeafJSON([]byte(c.data), DefaultLeafValueCodec))
+	}
+}
+
+// testValueCodec encodes testValue as a string.
+type testValueCodec struct{}
+
+type testValue struct {
+	label string
+	score int
+}
+
+func (testValueCodec) EncodeValue(value interface{}) (json.RawMessage, error) {
+	v, ok := value.(testValue)
+	if !ok {
+		return nil, fmt.Errorf("value must be testValue")
+	}
+	return json.Marshal(fmt.Sprintf("%s:%d", v.label, v.score))
+}
+
+func (testValueCodec) DecodeValue(data json.RawMessage) (interface{}, error) {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	var v testValue
+	for p := len(s) - 1; p >= 0; p-- {
+		if s[p] == ':' {
+			score, err := strconv.Atoi(s[p+1:])
+			v.label, v.score = s[:p], score
+			return v, err
+		}
+	}
+	return nil, fmt.Errorf("illegal testValue: %s", s)
+}
+
+func TestLeafJSONCodec(t *testing.T) {
+	tree := goassert.New(t).SucceedNew(NewLeaf(0, 1.0, testValue{"a", 1}, testValue{"b", 2})).(*Leaf)
+	data := goassert.New(t).SucceedNew(MarshalLeafJSON(tree, testValueCodec{})).([]byte)
+	goassert.New(t, `{"feature":0,"threshold":1,"left":{"value":"a:1"},"right":{"value":"b:2"}}`).Equal(string(data))
+	decoded := goassert.New(t).SucceedNew(UnmarshalLeafJSON(data, testValueCodec{})).(*Leaf)
+	goassert.New(t, true).Equal(tree.Equal(decoded, 0.0))
+	goassert.New(t, "root.right: illegal testValue: b").ExpectError(UnmarshalLeafJSON([]byte(`{"feature":0,"threshold":1,"left":{"value":"a:1"},"right":{"value":"b"}}`), testValueCodec{}))
+	tree.SetRight(goassert.New(t).SucceedNew(NewTerminalLeaf(float32(0.0))).(*Leaf))
+	goassert.New(t, "root.right: value must be testValue").ExpectError(MarshalLeafJSON(tree, testValueCodec{}))
+}
+
+func TestLeafGob(t *testing.T) {
+	trees := []*Leaf{newTestTree(t), goassert.New(t).SucceedNew(NewTerminalLeaf(float32(1.0))).(*Leaf)}
+	var buf bytes.Buffer
+	goassert.New(t).SucceedWithoutError(gob.NewEncoder(&buf).Encode(trees))
+	decoded := []*Leaf{}
+	goassert.New(t).SucceedWithoutError(gob.NewDecoder(&buf).Decode(&decoded))
+	goassert.New(t, len(trees)).Equal(len(decoded))
+	for i := range trees {
+		goassert.New(t, true).Equal(trees[i].Equal(decoded[i], 0.0))
+	}
+	// The values keep their types, unlike the JSON representation.
+	tree := goassert.New(t).SucceedNew(NewLeaf(0, 1.0, float64(0.1), int(2))).(*Leaf)
+	goassert.New(t).SucceedWithoutError(tree.SetOperator(Less))
+	data := goassert.New(t).SucceedNew(tree.GobEncode()).([]byte)
+	var decodedTree Leaf
+	goassert.New(t).SucceedWithoutError(decodedTree.GobDecode(data))
+	goassert.New(t, Less).EqualWithoutError(decodedTree.Operator())
+	goassert.New(t, float64(0.1)).EqualWithoutError(decodedTree.Left().Value())
+	goassert.New(t, int(2)).EqualWithoutError(decodedTree.Right().Value())
+	tree.SetRight(tree)
+	goassert.New(t, "root.right: cycle to root").ExpectError(tree.GobEncode())
+	buf.Reset()
+	goassert.New(t).SucceedWithoutError(gob.NewEncoder(&buf).Encode([]leafGob{{Feature: 0, Threshold: 1.0}, {Feature: _FEATURE_ID_TERMINAL_LEAF}}))
+	goassert.New(t, "tree is truncated").ExpectError(decodedTree.GobDecode(buf.Bytes()))
+	buf.Reset()
+	goassert.New(t).SucceedWithoutError(gob.NewEncoder(&buf).Encode([]leafGob{{Feature: _FEATURE_ID_TERMINAL_LEAF}, {Feature: _FEATURE_ID_TERMINAL_LEAF}}))
+	goassert.New(t, "1 leaves follow the tree").ExpectError(decodedTree.GobDecode(buf.Bytes()))
+	buf.Reset()
+	goassert.New(t).SucceedWithoutError(gob.NewEncoder(&buf).Encode([]leafGob{{Feature: 0, Threshold: float32(math.NaN())}, {Feature: _FEATURE_ID_TERMINAL_LEAF}, {Feature: _FEATURE_ID_TERMINAL_LEAF}}))
+	goassert.New(t, "root: threshold must be finite: NaN").ExpectError(decodedTree.GobDecode(buf.Bytes()))
+}
@@ -0,0 +1,150 @@
+package confeito
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// leafParser is a recursive descent parser of the string representation of Leaf.
+type leafParser struct {
+	s   string
+	pos int
+}
+
+// errorf returns an error at the current position.
+func (p *leafParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("column %d: %s", p.pos+1, fmt.Sprintf(format, args...))
+}
+
+// found returns the human-readable representation of the current character.
+func (p *leafParser) found() string {
+	if p.pos >= len(p.s) {
+		return "end of string"
+	}
+	return strconv.Quote(p.s[p.pos : p.pos+1])
+}
+
+func (p *leafParser) skipSpaces() {
+	for p.pos < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// expect consumes token after spaces.
+func (p *leafParser) expect(token string) error {
+	p.skipSpaces()
+	if !strings.HasPrefix(p.s[p.pos:], token) {
+		return p.errorf("expected %q, but found %s", token, p.found())
+	}
+	p.pos += len(token)
+	return nil
+}
+
+// word consumes a number-like word after spaces.
+func (p *leafParser) word() string {
+	p.skipSpaces()
+	start := p.pos
+	for p.pos < len(p.s) && strings.IndexByte(" \t\r\n()[]?:<=", p.s[p.pos]) < 0 {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *leafParser) parseFloat32(name string) (float32, error) {
+	start := p.pos
+	w := p.word()
+	if w == "" {
+		return 0.0, p.errorf("expected %s, but found %s", name, p.found())
+	}
+	value, err := strconv.ParseFloat(w, 32)
+	if err != nil {
+		p.pos = start
+		p.skipSpaces()
+		return 0.0, p.errorf("illegal %s %q", name, w)
+	}
+	return float32(value), nil
+}
+
+func (p *leafParser) parseLeaf() (*Leaf, error) {
+	p.skipSpaces()
+	if p.pos >= len(p.s) || p.s[p.pos] != '(' {
+		value, err := p.parseFloat32("value")
+		if err != nil {
+			return nil, err
+		}
+		return NewTerminalLeaf(value)
+	}
+	p.pos++
+	if err := p.expect("feature"); err != nil {
+		return nil, err
+	}
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+	start := p.pos
+	w := p.word()
+	featureID, err := strconv.ParseUint(w, 10, 32)
+	if err != nil || FeatureID(featureID) == _FEATURE_ID_ILLEGAL {
+		p.pos = start
+		p.skipSpaces()
+		return nil, p.errorf("illegal feature ID %q", w)
+	}
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	if err := p.expect("<"); err != nil {
+		return nil, err
+	}
+	operator := Less
+	if p.pos < len(p.s) && p.s[p.pos] == '=' {
+		operator = LessOrEqual
+		p.pos++
+	}
+	threshold, err := p.parseFloat32("threshold")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("?"); err != nil {
+		return nil, err
+	}
+	left, err := p.parseLeaf()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	right, err := p.parseLeaf()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return &Leaf{
+		featureID: FeatureID(featureID),
+		threshold: threshold,
+		operator:  operator,
+		left:      left,
+		right:     right,
+	}, nil
+}
+
+// ParseLeaf returns a new tree represented by s in the format of Leaf.String, like "(feature[0] <= 0.5 ? 1 : (feature[1] < -1 ? 2 : 3))".
+// The values of terminal leaves must be numbers, and they are parsed as float32.
+// Spaces between tokens are ignored.
+//
+// This function returns an error with the column (1-origin) if s is malformed.
+func ParseLeaf(s string) (*Leaf, error) {
+	p := &leafParser{s: s}
+	leaf, err := p.parseLeaf()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.s) {
+		return nil, p.errorf("expected end of string, but found %s", p.found())
+	}
+	return leaf, nil
+}
@@ -0,0 +1,57 @@
+package confeito
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestParseLeaf(t *testing.T) {
+	tree := newTestTree(t)
+	parsed := goassert.New(t).SucceedNew(ParseLeaf(tree.String())).(*Leaf)
+	goassert.New(t, true).Equal(tree.Equal(parsed, 0.0))
+	parsed = goassert.New(t).SucceedNew(ParseLeaf(" ( feature[ 0 ]<=1e-05?-1.5:\n(feature[1] <= +Inf ? NaN : 2) ) ")).(*Leaf)
+	goassert.New(t, "(feature[0] <= 1e-05 ? -1.5 : (feature[1] <= +Inf ? NaN : 2))").Equal(parsed.String())
+	goassert.New(t, float32(3.0)).EqualWithoutError(goassert.New(t).SucceedNew(ParseLeaf("3")).(*Leaf).Value())
+	parsed = goassert.New(t).SucceedNew(ParseLeaf("(feature[0]<1?2:(feature[1]<=1?3:4))")).(*Leaf)
+	goassert.New(t, "(feature[0] < 1 ? 2 : (feature[1] <= 1 ? 3 : 4))").Equal(parsed.String())
+	goassert.New(t, Less).EqualWithoutError(parsed.Operator())
+}
+
+func TestParseLeafRoundTrip(t *testing.T) {
+	X, y := newRandomForestDataset(200, false)
+	trainer := NewCARTTrainer(CriterionMSE)
+	trainer.MaxLeaves = ForestMaxLeaves
+	tree := goassert.New(t).SucceedNew(trainer.Train(X, y)).(*Leaf)
+	parsed := goassert.New(t).SucceedNew(ParseLeaf(tree.String())).(*Leaf)
+	goassert.New(t, true).Equal(tree.Equal(parsed, 0.0))
+	rng := rand.New(rand.NewSource(0))
+	for i := 0; i < 100; i++ {
+		value := float32(rng.NormFloat64() * 1e6)
+		leaf := goassert.New(t).SucceedNew(NewLeaf(FeatureID(rng.Uint32()>>1), value, value, -value)).(*Leaf)
+		parsed := goassert.New(t).SucceedNew(ParseLeaf(leaf.String())).(*Leaf)
+		goassert.New(t, true).Equal(leaf.Equal(parsed, 0.0))
+	}
+}
+
+func TestParseLeafError(t *testing.T) {
+	for _, c := range []struct {
+		s, err string
+	}{
+		{"", "column 1: expected value, but found end of string"},
+		{"a", "column 1: illegal value \"a\""},
+		{"1 2", "column 3: expected end of string, but found \"2\""},
+		{"(feat[0] <= 1 ? 2 : 3)", "column 2: expected \"feature\", but found \"f\""},
+		{"(feature[x] <= 1 ? 2 : 3)", "column 10: illegal feature ID \"x\""},
+		{"(feature[4294967295] <= 1 ? 2 : 3)", "column 10: illegal feature ID \"4294967295\""},
+		{"(feature[0] > 1 ? 2 : 3)", "column 13: expected \"<\", but found \">\""},
+		{"(feature[0] <= ? 2 : 3)", "column 16: expected threshold, but found \"?\""},
+		{"(feature[0] <= 1 : 2 : 3)", "column 18: expected \"?\", but found \":\""},
+		{"(feature[0] <= 1 ? 2 ? 3)", "column 22: expected \":\", but found \"?\""},
+		{"(feature[0] <= 1 ? 2 : (feature[1] <= 1 ? 2 : 3)", "column 49: expected \")\", but found end of string"},
+		{"(feature[0] <= 1 ? 2 : 3x)", "column 24: illegal value \"3x\""},
+	} {
+		goassert.New(t, c.err).ExpectError(ParseLeaf(c.s))
+	}
+}
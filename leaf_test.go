@@ -2,6 +2,7 @@ package confeito
 
 import (
 	"fmt"
+	"math"
 	"testing"
 
 	"github.com/hiro4bbh/go-assert"
@@ -63,3 +64,127 @@ func TestLeaf(t *testing.T) {
 	goassert.New(t, float32(5.0)).EqualWithoutError(leaf1.Predict(x5))
 	goassert.New(t, float32(4.0)).EqualWithoutError(leaf1.Predict(x6))
 }
+
+func TestLeafOperator(t *testing.T) {
+	goassert.New(t, "<=").Equal(LessOrEqual.String())
+	goassert.New(t, "<").Equal(Less.String())
+	goassert.New(t, "SplitOperator(2)").Equal(SplitOperator(2).String())
+
+	leaf := goassert.New(t).SucceedNew(NewLeaf(0, 0.5, float32(1.0), float32(2.0))).(*Leaf)
+	goassert.New(t, LessOrEqual).EqualWithoutError(leaf.Operator())
+	goassert.New(t, float32(1.0)).EqualWithoutError(leaf.Predict(DenseFeatureVector{0.5}))
+	clone := leaf.Clone()
+	goassert.New(t).SucceedWithoutError(leaf.SetOperator(Less))
+	goassert.New(t, Less).EqualWithoutError(leaf.Operator())
+	goassert.New(t, "(feature[0] < 0.5 ? 1 : 2)").Equal(leaf.String())
+	goassert.New(t, float32(1.0)).EqualWithoutError(leaf.Predict(DenseFeatureVector{0.25}))
+	goassert.New(t, float32(2.0)).EqualWithoutError(leaf.Predict(DenseFeatureVector{0.5}))
+	// NaN goes to the left with either operator.
+	goassert.New(t, float32(1.0)).EqualWithoutError(leaf.Predict(DenseFeatureVector{float32(math.NaN())}))
+	goassert.New(t, float32(1.0)).EqualWithoutError(clone.Predict(DenseFeatureVector{float32(math.NaN())}))
+	goassert.New(t, false).Equal(leaf.Equal(clone, 0.0))
+	goassert.New(t, true).Equal(leaf.Equal(leaf.Clone(), 0.0))
+
+	goassert.New(t, "illegal operator: SplitOperator(2)").ExpectError(leaf.SetOperator(SplitOperator(2)))
+	goassert.New(t, "terminal leaf does not have operator").ExpectError(leaf.Left().Operator())
+	goassert.New(t, "terminal leaf cannot have operator").ExpectError(leaf.Left().SetOperator(Less))
+	leaf.operator = SplitOperator(2)
+	goassert.New(t, "root: illegal operator: SplitOperator(2)").ExpectError(leaf.Validate())
+}
+
+func newTestTree(t *testing.T) *Leaf {
+	// (feature[1] <= -0.5 ? (feature[2] <= 0.5 ? 1 : 2) : (feature[3] <= 1.5 ? 3 : (feature[4] <= 2.5 ? 4 : 5)))
+	leaf1 := goassert.New(t).SucceedNew(NewLeaf(1, -0.5, float32(1.0), float32(2.0))).(*Leaf)
+	leaf2 := goassert.New(t).SucceedNew(NewLeaf(2, 0.5, float32(1.0), float32(2.0))).(*Leaf)
+	leaf3 := goassert.New(t).SucceedNew(NewLeaf(3, 1.5, float32(3.0), float32(4.0))).(*Leaf)
+	leaf4 := goassert.New(t).SucceedNew(NewLeaf(4, 2.5, float32(4.0), float32(5.0))).(*Leaf)
+	leaf1.SetLeft(leaf2)
+	leaf1.SetRight(leaf3)
+	leaf3.SetRight(leaf4)
+	return leaf1
+}
+
+func TestLeafWalk(t *testing.T) {
+	tree := newTestTree(t)
+	visited := []string{}
+	goassert.New(t).SucceedWithoutError(tree.Walk(PreOrder, func(leaf *Leaf, path LeafPath) error {
+		visited = append(visited, fmt.Sprintf("%s=%s", path, leaf))
+		return nil
+	}))
+	goassert.New(t, []string{
+		"root=(feature[1] <= -0.5 ? (feature[2] <= 0.5 ? 1 : 2) : (feature[3] <= 1.5 ? 3 : (feature[4] <= 2.5 ? 4 : 5)))",
+		"root.left=(feature[2] <= 0.5 ? 1 : 2)",
+		"root.left.left=1",
+		"root.left.right=2",
+		"root.right=(feature[3] <= 1.5 ? 3 : (feature[4] <= 2.5 ? 4 : 5))",
+		"root.right.left=3",
+		"root.right.right=(feature[4] <= 2.5 ? 4 : 5)",
+		"root.right.right.left=4",
+		"root.right.right.right=5",
+	}).Equal(visited)
+	visited = []string{}
+	goassert.New(t, "stop").ExpectError(tree.Walk(PostOrder, func(leaf *Leaf, path LeafPath) error {
+		visited = append(visited, path.String())
+		if !leaf.IsTerminal() {
+			return fmt.Errorf("stop")
+		}
+		return nil
+	}))
+	goassert.New(t, []string{"root.left.left", "root.left.right", "root.left"}).Equal(visited)
+}
+
+func TestLeafMetrics(t *testing.T) {
+	terminal := goassert.New(t).SucceedNew(NewTerminalLeaf(float32(1.0))).(*Leaf)
+	goassert.New(t, 0).Equal(terminal.Depth())
+	goassert.New(t, 1).Equal(terminal.NumLeaves())
+	goassert.New(t, 1).Equal(terminal.NumNodes())
+	tree := newTestTree(t)
+	goassert.New(t, 3).Equal(tree.Depth())
+	goassert.New(t, 5).Equal(tree.NumLeaves())
+	goassert.New(t, 9).Equal(tree.NumNodes())
+}
+
+func TestLeafCloneEqual(t *testing.T) {
+	tree := newTestTree(t)
+	clone := tree.Clone()
+	goassert.New(t, true).Equal(tree.Equal(clone, 0.0))
+	goassert.New(t, tree.String()).Equal(clone.String())
+	clone.Right().SetLeft(goassert.New(t).SucceedNew(NewTerminalLeaf(float32(3.0001))).(*Leaf))
+	goassert.New(t, "(feature[3] <= 1.5 ? 3 : (feature[4] <= 2.5 ? 4 : 5))").Equal(tree.Right().String())
+	goassert.New(t, false).Equal(tree.Equal(clone, 0.0))
+	goassert.New(t, true).Equal(tree.Equal(clone, 0.001))
+	clone.Right().SetLeft(goassert.New(t).SucceedNew(NewTerminalLeaf("3")).(*Leaf))
+	goassert.New(t, false).Equal(tree.Equal(clone, 0.001))
+	goassert.New(t, false).Equal(tree.Equal(tree.Left(), 0.001))
+	goassert.New(t, false).Equal(tree.Equal(nil, 0.001))
+	goassert.New(t, true).Equal((*Leaf)(nil).Equal(nil, 0.0))
+	terminal := goassert.New(t).SucceedNew(NewTerminalLeaf([]int{1})).(*Leaf)
+	goassert.New(t, true).Equal(terminal.Equal(terminal.Clone(), 0.0))
+	goassert.New(t, false).Equal(terminal.Equal(goassert.New(t).SucceedNew(NewTerminalLeaf([]int{2})).(*Leaf), 0.0))
+}
+
+func TestLeafValidate(t *testing.T) {
+	goassert.New(t).SucceedWithoutError(newTestTree(t).Validate())
+	goassert.New(t, "root: leaf must not be nil").ExpectError((*Leaf)(nil).Validate())
+
+	cyclic := newTestTree(t)
+	cyclic.Right().SetLeft(cyclic)
+	goassert.New(t, "root.right.left: cycle to root").ExpectError(cyclic.Validate())
+	err := cyclic.Validate().(*LeafError)
+	goassert.New(t, LeafPath{true, false}, "cycle to root").Equal(err.Path, err.Reason)
+
+	shared := newTestTree(t)
+	shared.Right().SetLeft(shared.Left())
+	goassert.New(t, "root.right.left: shared with root.left").ExpectError(shared.Validate())
+
+	nan := goassert.New(t).SucceedNew(NewLeaf(0, float32(math.NaN()), float32(0.0), float32(1.0))).(*Leaf)
+	goassert.New(t, "root: threshold must be finite: NaN").ExpectError(nan.Validate())
+	inf := newTestTree(t)
+	inf.Left().SetRight(goassert.New(t).SucceedNew(NewLeaf(0, float32(math.Inf(-1)), float32(0.0), float32(1.0))).(*Leaf))
+	goassert.New(t, "root.left.right: threshold must be finite: -Inf").ExpectError(inf.Validate())
+
+	goassert.New(t, "root: non-terminal leaf must have left and right leaf").ExpectError((&Leaf{}).Validate())
+	terminal := goassert.New(t).SucceedNew(NewTerminalLeaf(float32(0.0))).(*Leaf)
+	terminal.left = terminal
+	goassert.New(t, "root: terminal leaf (having the illegal feature ID) must not have left or right leaf").ExpectError(terminal.Validate())
+}
@@ -0,0 +1,101 @@
+package confeito
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// LibSVMReader reads data points in the LibSVM/SVMlight format from io.Reader line by line.
+// Each line is "label [qid:queryID] index:value ... [# comment]", where the indices must be in strictly ascending order.
+// The blank lines and the comment lines starting with "#" are skipped.
+// Each data point has SparseFeatureVector of the features, whose feature IDs are the indices (minus 1 if OneBased is true).
+//
+// This implements interface DataReader.
+type LibSVMReader struct {
+	// OneBased is true if the indices start from 1, which is the convention of LIBSVM, otherwise the indices are used as feature IDs.
+	OneBased bool
+	reader   *bufio.Reader
+	line     int
+}
+
+// NewLibSVMReader returns a new LibSVMReader reading r.
+func NewLibSVMReader(r io.Reader) *LibSVMReader {
+	return &LibSVMReader{reader: bufio.NewReader(r)}
+}
+
+// Read is for interface DataReader.
+//
+// This function returns io.EOF at the end of the input, or an error with the line number if the line is malformed.
+func (reader *LibSVMReader) Read() (*DataRecord, error) {
+	for {
+		s, err := reader.reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if s == "" && err == io.EOF {
+			return nil, io.EOF
+		}
+		reader.line++
+		if i := strings.IndexByte(s, '#'); i >= 0 {
+			s = s[:i]
+		}
+		fields := strings.Fields(s)
+		if len(fields) == 0 {
+			continue
+		}
+		record, e := reader.parseLine(fields)
+		if e != nil {
+			return nil, fmt.Errorf("line %d: %s", reader.line, e)
+		}
+		return record, nil
+	}
+}
+
+// parseLine returns a new DataRecord of the fields in the current line.
+func (reader *LibSVMReader) parseLine(fields []string) (*DataRecord, error) {
+	label, err := strconv.ParseFloat(fields[0], 32)
+	if err != nil {
+		return nil, fmt.Errorf("illegal label %q", fields[0])
+	}
+	record := &DataRecord{Line: reader.line, Label: float32(label)}
+	fields = fields[1:]
+	if len(fields) > 0 && strings.HasPrefix(fields[0], "qid:") {
+		queryID, err := strconv.Atoi(fields[0][len("qid:"):])
+		if err != nil {
+			return nil, fmt.Errorf("illegal query ID %q", fields[0])
+		}
+		record.QueryID, record.HasQueryID = queryID, true
+		fields = fields[1:]
+	}
+	x := make(SparseFeatureVector, 0, len(fields))
+	for _, field := range fields {
+		i := strings.IndexByte(field, ':')
+		if i < 0 {
+			return nil, fmt.Errorf("expected index:value, but found %q", field)
+		}
+		index, err := strconv.ParseUint(field[:i], 10, 32)
+		if err != nil || (reader.OneBased && index == 0) {
+			return nil, fmt.Errorf("illegal index %q", field[:i])
+		}
+		if reader.OneBased {
+			index--
+		}
+		featureID := FeatureID(index)
+		if featureID == _FEATURE_ID_ILLEGAL {
+			return nil, fmt.Errorf("illegal index %q", field[:i])
+		}
+		if len(x) > 0 && featureID <= x[len(x)-1].Key {
+			return nil, fmt.Errorf("indices must be in strictly ascending order: %q", field[:i])
+		}
+		value, err := strconv.ParseFloat(field[i+1:], 32)
+		if err != nil {
+			return nil, fmt.Errorf("illegal value %q", field[i+1:])
+		}
+		x = append(x, KeyValue{Key: featureID, Value: float32(value)})
+	}
+	record.X = x
+	return record, nil
+}
@@ -0,0 +1,41 @@
+package confeito
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestLibSVMReader(t *testing.T) {
+	reader := NewLibSVMReader(strings.NewReader("# comment line\n1 qid:3 0:0.5 2:-1 # comment\n\n-1.5 1:2\n0"))
+	goassert.New(t, &DataRecord{Line: 2, Label: 1.0, QueryID: 3, HasQueryID: true, X: SparseFeatureVector{{0, 0.5}, {2, -1.0}}}).EqualWithoutError(reader.Read())
+	goassert.New(t, &DataRecord{Line: 4, Label: -1.5, X: SparseFeatureVector{{1, 2.0}}}).EqualWithoutError(reader.Read())
+	goassert.New(t, &DataRecord{Line: 5, Label: 0.0, X: SparseFeatureVector{}}).EqualWithoutError(reader.Read())
+	goassert.New(t, io.EOF.Error()).ExpectError(reader.Read())
+
+	reader = NewLibSVMReader(strings.NewReader("1 1:0.5 3:1\n"))
+	reader.OneBased = true
+	goassert.New(t, &DataRecord{Line: 1, Label: 1.0, X: SparseFeatureVector{{0, 0.5}, {2, 1.0}}}).EqualWithoutError(reader.Read())
+
+	for _, c := range []struct {
+		oneBased bool
+		input    string
+		err      string
+	}{
+		{false, "1 0:1\nx 0:1", `line 2: illegal label "x"`},
+		{false, "1 qid:x 0:1", `line 1: illegal query ID "qid:x"`},
+		{false, "1 0", `line 1: expected index:value, but found "0"`},
+		{false, "1 -1:1", `line 1: illegal index "-1"`},
+		{true, "1 0:1", `line 1: illegal index "0"`},
+		{false, "1 4294967295:1", `line 1: illegal index "4294967295"`},
+		{false, "1 2:1 1:1", `line 1: indices must be in strictly ascending order: "1"`},
+		{false, "1 2:1 2:1", `line 1: indices must be in strictly ascending order: "2"`},
+		{false, "1 2:x", `line 1: illegal value "x"`},
+	} {
+		reader := NewLibSVMReader(strings.NewReader(c.input))
+		reader.OneBased = c.oneBased
+		goassert.New(t, c.err).ExpectError(ReadAllData(reader))
+	}
+}
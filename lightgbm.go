@@ -0,0 +1,392 @@
+package confeito
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// lightgbmZeroThreshold is the absolute value below which LightGBM regards feature values as zero (kZeroThreshold).
+const lightgbmZeroThreshold = 1e-35
+
+// lightgbmMissingTypes is the missing types of LightGBM in the order of the bits 2-3 of decision_type.
+var lightgbmMissingTypes = []string{"None", "Zero", "NaN"}
+
+// LightGBMOptions is the options of importing LightGBM models by UnmarshalLightGBM and UnmarshalLightGBMJSON.
+// The zero value imports the first class, and imports the splits sending missing values to the right lossily.
+type LightGBMOptions struct {
+	// Class is the class whose score is imported, which is ignored for the models having one tree per iteration.
+	Class int
+	// StrictMissingValues rejects the splits sending missing (NaN) feature values to the right if true.
+	// Leaf takes the left leaf for missing feature values, so such splits are imported as they are by default, and the predictions for NaN differ from LightGBM.
+	StrictMissingValues bool
+}
+
+// newLightGBMSplit returns a new non-terminal leaf of the LightGBM split "x <= threshold" on featureID, whose children are left and right.
+// LightGBM regards NaN as zero with missing type None, and sends zero and NaN to the default side with missing type Zero, or NaN to the default side with missing type NaN.
+//
+// This function returns an error if the split is illegal, zero does not go to the same side as the threshold with missing type Zero, or NaN goes to the right with StrictMissingValues.
+func newLightGBMSplit(featureID int, threshold float64, defaultLeft bool, missingType string, left, right *Leaf, options *LightGBMOptions) (*Leaf, error) {
+	if featureID < 0 || FeatureID(featureID) == _FEATURE_ID_ILLEGAL {
+		return nil, fmt.Errorf("illegal feature %d", featureID)
+	}
+	t := float32Floor(threshold)
+	if math.IsNaN(float64(t)) || math.IsInf(float64(t), 0) {
+		return nil, fmt.Errorf("threshold must be finite: %g", threshold)
+	}
+	var nanLeft bool
+	switch missingType {
+	case "None":
+		nanLeft = 0.0 <= threshold
+	case "Zero":
+		// The values regarded as zero must go to the default side also by the threshold.
+		if defaultLeft && !(lightgbmZeroThreshold <= t) || !defaultLeft && !(t < -lightgbmZeroThreshold) {
+			return nil, fmt.Errorf("missing type Zero with default_left=%t is not supported for threshold %g", defaultLeft, threshold)
+		}
+		nanLeft = defaultLeft
+	case "NaN":
+		nanLeft = defaultLeft
+	default:
+		return nil, fmt.Errorf("unsupported missing type %q", missingType)
+	}
+	if !nanLeft && options.StrictMissingValues {
+		return nil, fmt.Errorf("missing values must go to the left leaf, but missing type %s with default_left=%t does not", missingType, defaultLeft)
+	}
+	return &Leaf{featureID: FeatureID(featureID), threshold: t, left: left, right: right}, nil
+}
+
+// lightgbmEnsemble returns a new Ensemble of trees for class, where the trees of the classes are interleaved by ntreesPerIteration.
+// The leaf values of LightGBM are already scaled by the shrinkage, so the trees have weight 1, or are averaged if averageOutput is true.
+//
+// This function returns an error if ntreesPerIteration is not positive, the number of trees is not a multiple of it, or class is out of range.
+func lightgbmEnsemble(trees []*Leaf, ntreesPerIteration int, averageOutput bool, class int) (*Ensemble, error) {
+	if ntreesPerIteration <= 0 {
+		return nil, fmt.Errorf("num_tree_per_iteration must be positive")
+	}
+	if len(trees)%ntreesPerIteration != 0 {
+		return nil, fmt.Errorf("the number of trees must be a multiple of num_tree_per_iteration")
+	}
+	if ntreesPerIteration == 1 {
+		class = 0
+	} else if !(0 <= class && class < ntreesPerIteration) {
+		return nil, fmt.Errorf("class %d is out of range [0, %d)", class, ntreesPerIteration)
+	}
+	niterations := len(trees) / ntreesPerIteration
+	weight := float32(1.0)
+	if averageOutput && niterations > 0 {
+		weight = float32(1.0 / float64(niterations))
+	}
+	model := &Ensemble{}
+	for i := 0; i < niterations; i++ {
+		model.Trees, model.Weights = append(model.Trees, trees[i*ntreesPerIteration+class]), append(model.Weights, weight)
+	}
+	return model, nil
+}
+
+// lightgbmTextTree is a tree in the text model format of LightGBM.
+type lightgbmTextTree struct {
+	splitFeature  []int
+	threshold     []float64
+	decisionType  []int
+	leftChild     []int
+	rightChild    []int
+	leafValue     []float64
+	numLeaves     int
+	numCategories int
+	isLinear      bool
+}
+
+// parseLightGBMInts returns the space-separated integers in s.
+func parseLightGBMInts(s string) ([]int, error) {
+	fields := strings.Fields(s)
+	values := make([]int, len(fields))
+	for i, field := range fields {
+		value, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("illegal integer %q", field)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// parseLightGBMFloats returns the space-separated floating-point numbers in s.
+func parseLightGBMFloats(s string) ([]float64, error) {
+	fields := strings.Fields(s)
+	values := make([]float64, len(fields))
+	for i, field := range fields {
+		value, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, fmt.Errorf("illegal number %q", field)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// set sets the value of key to tree.
+// The unknown keys are ignored.
+func (tree *lightgbmTextTree) set(key, value string) error {
+	var err error
+	switch key {
+	case "num_leaves":
+		tree.numLeaves, err = strconv.Atoi(value)
+	case "num_cat":
+		tree.numCategories, err = strconv.Atoi(value)
+	case "is_linear":
+		tree.isLinear = value != "0"
+	case "split_feature":
+		tree.splitFeature, err = parseLightGBMInts(value)
+	case "threshold":
+		tree.threshold, err = parseLightGBMFloats(value)
+	case "decision_type":
+		tree.decisionType, err = parseLightGBMInts(value)
+	case "left_child":
+		tree.leftChild, err = parseLightGBMInts(value)
+	case "right_child":
+		tree.rightChild, err = parseLightGBMInts(value)
+	case "leaf_value":
+		tree.leafValue, err = parseLightGBMFloats(value)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s", key, err)
+	}
+	return nil
+}
+
+// leaf returns a new Leaf of tree.
+// The children are the indices of the non-terminal nodes, or the bitwise complements of the indices of the terminal leaves.
+//
+// This function returns an error if tree is malformed or has an unsupported split (see newLightGBMSplit).
+func (tree *lightgbmTextTree) leaf(options *LightGBMOptions) (*Leaf, error) {
+	if tree.numCategories != 0 {
+		return nil, fmt.Errorf("categorical splits are not supported")
+	}
+	if tree.isLinear {
+		return nil, fmt.Errorf("linear trees are not supported")
+	}
+	if tree.numLeaves <= 0 || len(tree.leafValue) != tree.numLeaves {
+		return nil, fmt.Errorf("leaf_value must have num_leaves values")
+	}
+	if tree.numLeaves == 1 {
+		return NewTerminalLeaf(float32(tree.leafValue[0]))
+	}
+	n := tree.numLeaves - 1
+	if len(tree.splitFeature) != n || len(tree.threshold) != n || len(tree.decisionType) != n || len(tree.leftChild) != n || len(tree.rightChild) != n {
+		return nil, fmt.Errorf("split_feature, threshold, decision_type, left_child and right_child must have num_leaves-1 values")
+	}
+	visited, visitedLeaves := make([]bool, n), make([]bool, tree.numLeaves)
+	var build func(child int) (*Leaf, error)
+	build = func(child int) (*Leaf, error) {
+		if child < 0 {
+			leaf := ^child
+			if leaf >= tree.numLeaves || visitedLeaves[leaf] {
+				return nil, fmt.Errorf("leaf %d is out of range or visited twice", leaf)
+			}
+			visitedLeaves[leaf] = true
+			return NewTerminalLeaf(float32(tree.leafValue[leaf]))
+		}
+		if child >= n || visited[child] {
+			return nil, fmt.Errorf("node %d is out of range or visited twice", child)
+		}
+		visited[child] = true
+		decisionType := tree.decisionType[child]
+		if decisionType&1 != 0 {
+			return nil, fmt.Errorf("node %d: categorical splits are not supported", child)
+		}
+		missingType := (decisionType >> 2) & 3
+		if missingType >= len(lightgbmMissingTypes) {
+			return nil, fmt.Errorf("node %d: illegal decision_type %d", child, decisionType)
+		}
+		left, err := build(tree.leftChild[child])
+		if err != nil {
+			return nil, err
+		}
+		right, err := build(tree.rightChild[child])
+		if err != nil {
+			return nil, err
+		}
+		leaf, err := newLightGBMSplit(tree.splitFeature[child], tree.threshold[child], decisionType&2 != 0, lightgbmMissingTypes[missingType], left, right, options)
+		if err != nil {
+			return nil, fmt.Errorf("node %d: %s", child, err)
+		}
+		return leaf, nil
+	}
+	return build(0)
+}
+
+// UnmarshalLightGBM returns a new Ensemble represented by data of a LightGBM model saved in the text model format (Booster.save_model).
+//
+// Each tree has the splits "x <= threshold" with the children left_child and right_child, and the leaf values already scaled by the shrinkage.
+// For multi-class models, the trees of the classes are interleaved, and the trees for options.Class are imported.
+// The trees are averaged if the model has average_output (random forest mode).
+// The splits are exact, because the float64 thresholds are rounded down to float32, but the summation is in float32, so the predictions may differ by the rounding errors.
+//
+// Missing (NaN) values go to the left like Leaf, so the splits sending them to the right are lossy (see LightGBMOptions).
+// The missing type Zero is supported only if the values regarded as zero (|x| <= 1e-35) go to the default side also by the threshold.
+//
+// This function returns an error if data is malformed, a tree is categorical or linear, a split is not supported, or options.Class is out of range.
+func UnmarshalLightGBM(data []byte, options *LightGBMOptions) (*Ensemble, error) {
+	if options == nil {
+		options = &LightGBMOptions{}
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	ntreesPerIteration, averageOutput := 1, false
+	var trees []*lightgbmTextTree
+	var tree *lightgbmTextTree
+	header, end := false, false
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if lineno == 1 {
+			if line != "tree" {
+				return nil, fmt.Errorf("line 1: model must start with \"tree\"")
+			}
+			header = true
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		if line == "end of trees" {
+			end = true
+			break
+		}
+		i := strings.IndexByte(line, '=')
+		if i < 0 {
+			if tree == nil && line == "average_output" {
+				averageOutput = true
+			}
+			continue
+		}
+		key, value := line[:i], line[i+1:]
+		if key == "Tree" {
+			if index, err := strconv.Atoi(value); err != nil || index != len(trees) {
+				return nil, fmt.Errorf("line %d: tree index must be %d", lineno, len(trees))
+			}
+			tree = &lightgbmTextTree{}
+			trees = append(trees, tree)
+			continue
+		}
+		if tree == nil {
+			if key == "num_tree_per_iteration" {
+				n, err := strconv.Atoi(value)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: illegal num_tree_per_iteration %q", lineno, value)
+				}
+				ntreesPerIteration = n
+			}
+			continue
+		}
+		if err := tree.set(key, value); err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineno, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !header {
+		return nil, fmt.Errorf("model must not be empty")
+	}
+	if !end {
+		return nil, fmt.Errorf("model must have \"end of trees\"")
+	}
+	leaves := make([]*Leaf, len(trees))
+	for t, tree := range trees {
+		leaf, err := tree.leaf(options)
+		if err != nil {
+			return nil, fmt.Errorf("tree %d: %s", t, err)
+		}
+		leaves[t] = leaf
+	}
+	return lightgbmEnsemble(leaves, ntreesPerIteration, averageOutput, options.Class)
+}
+
+// lightgbmNodeJSON is the JSON schema of a node in tree_structure of a LightGBM model dumped by Booster.dump_model.
+// The terminal leaves have leaf_value.
+type lightgbmNodeJSON struct {
+	SplitIndex   int               `json:"split_index"`
+	SplitFeature int               `json:"split_feature"`
+	Threshold    json.RawMessage   `json:"threshold"`
+	DecisionType string            `json:"decision_type"`
+	DefaultLeft  bool              `json:"default_left"`
+	MissingType  string            `json:"missing_type"`
+	LeftChild    *lightgbmNodeJSON `json:"left_child"`
+	RightChild   *lightgbmNodeJSON `json:"right_child"`
+	LeafValue    *float64          `json:"leaf_value"`
+}
+
+// lightgbmJSON is the JSON schema of a LightGBM model dumped by Booster.dump_model.
+type lightgbmJSON struct {
+	NumTreePerIteration *int `json:"num_tree_per_iteration"`
+	AverageOutput       bool `json:"average_output"`
+	TreeInfo            []struct {
+		TreeIndex     int               `json:"tree_index"`
+		TreeStructure *lightgbmNodeJSON `json:"tree_structure"`
+	} `json:"tree_info"`
+}
+
+// leaf returns a new Leaf of the tree whose root is node.
+//
+// This function returns an error if node is malformed or has an unsupported split (see newLightGBMSplit).
+func (node *lightgbmNodeJSON) leaf(options *LightGBMOptions) (*Leaf, error) {
+	if node.LeafValue != nil {
+		return NewTerminalLeaf(float32(*node.LeafValue))
+	}
+	if node.DecisionType != "<=" {
+		return nil, fmt.Errorf("split %d: unsupported decision_type %q", node.SplitIndex, node.DecisionType)
+	}
+	if node.LeftChild == nil || node.RightChild == nil {
+		return nil, fmt.Errorf("split %d: left_child and right_child must be given", node.SplitIndex)
+	}
+	threshold, err := strconv.ParseFloat(string(node.Threshold), 64)
+	if err != nil {
+		return nil, fmt.Errorf("split %d: illegal threshold %s", node.SplitIndex, node.Threshold)
+	}
+	left, err := node.LeftChild.leaf(options)
+	if err != nil {
+		return nil, err
+	}
+	right, err := node.RightChild.leaf(options)
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := newLightGBMSplit(node.SplitFeature, threshold, node.DefaultLeft, node.MissingType, left, right, options)
+	if err != nil {
+		return nil, fmt.Errorf("split %d: %s", node.SplitIndex, err)
+	}
+	return leaf, nil
+}
+
+// UnmarshalLightGBMJSON returns a new Ensemble represented by JSON data of a LightGBM model dumped by Booster.dump_model.
+// The model is converted in the same way as UnmarshalLightGBM, where the splits are in tree_structure of each tree in tree_info.
+//
+// This function returns an error if data is malformed, a split is not supported, or options.Class is out of range.
+func UnmarshalLightGBMJSON(data []byte, options *LightGBMOptions) (*Ensemble, error) {
+	if options == nil {
+		options = &LightGBMOptions{}
+	}
+	var lj lightgbmJSON
+	if err := json.Unmarshal(data, &lj); err != nil {
+		return nil, err
+	}
+	if lj.NumTreePerIteration == nil {
+		return nil, fmt.Errorf("num_tree_per_iteration must be given")
+	}
+	leaves := make([]*Leaf, len(lj.TreeInfo))
+	for t, info := range lj.TreeInfo {
+		if info.TreeStructure == nil {
+			return nil, fmt.Errorf("tree %d: tree_structure must be given", info.TreeIndex)
+		}
+		leaf, err := info.TreeStructure.leaf(options)
+		if err != nil {
+			return nil, fmt.Errorf("tree %d: %s", info.TreeIndex, err)
+		}
+		leaves[t] = leaf
+	}
+	return lightgbmEnsemble(leaves, *lj.NumTreePerIteration, lj.AverageOutput, options.Class)
+}
@@ -0,0 +1,136 @@
+package confeito
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+const (
+	// lightgbmTestModel is a LightGBM model in the text model format of a tree with the splits "feature[0] <= 0.5" (missing type None) and "feature[1] <= 0.1" (missing type NaN), and a tree of a single leaf.
+	lightgbmTestModel = `tree
+version=v3
+num_class=1
+num_tree_per_iteration=1
+label_index=0
+max_feature_idx=1
+objective=regression
+feature_names=Column_0 Column_1
+feature_infos=[0:1] [0:1]
+tree_sizes=384 260
+
+Tree=0
+num_leaves=3
+num_cat=0
+split_feature=0 1
+split_gain=1 1
+threshold=0.5 0.10000000000000001
+decision_type=2 10
+left_child=-1 -2
+right_child=1 -3
+leaf_value=1 2 3
+leaf_weight=1 1 1
+leaf_count=1 1 1
+internal_value=0 0
+internal_weight=3 2
+internal_count=3 2
+is_linear=0
+shrinkage=1
+
+
+Tree=1
+num_leaves=1
+num_cat=0
+split_feature=
+split_gain=
+threshold=
+decision_type=
+left_child=
+right_child=
+leaf_value=0.5
+leaf_weight=
+leaf_count=
+internal_value=
+internal_weight=
+internal_count=
+is_linear=0
+shrinkage=1
+
+
+end of trees
+
+feature_importances:
+Column_0=1
+Column_1=1
+
+parameters:
+[boosting: gbdt]
+end of parameters
+
+pandas_categorical:null
+`
+	// lightgbmTestModelJSON is lightgbmTestModel dumped by Booster.dump_model.
+	lightgbmTestModelJSON = `{"name":"tree","version":"v3","num_class":1,"num_tree_per_iteration":1,"label_index":0,"max_feature_idx":1,"objective":"regression","average_output":false,"feature_names":["Column_0","Column_1"],
+"tree_info":[{"tree_index":0,"num_leaves":3,"num_cat":0,"shrinkage":1,"tree_structure":{"split_index":0,"split_feature":0,"split_gain":1,"threshold":0.5,"decision_type":"<=","default_left":true,"missing_type":"None","internal_value":0,"internal_weight":3,"internal_count":3,
+"left_child":{"leaf_index":0,"leaf_value":1,"leaf_weight":1,"leaf_count":1},
+"right_child":{"split_index":1,"split_feature":1,"split_gain":1,"threshold":0.10000000000000001,"decision_type":"<=","default_left":true,"missing_type":"NaN","internal_value":0,"internal_weight":2,"internal_count":2,"left_child":{"leaf_index":1,"leaf_value":2,"leaf_weight":1,"leaf_count":1},"right_child":{"leaf_index":2,"leaf_value":3,"leaf_weight":1,"leaf_count":1}}}},
+{"tree_index":1,"num_leaves":1,"num_cat":0,"shrinkage":1,"tree_structure":{"leaf_value":0.5}}]}`
+)
+
+func TestUnmarshalLightGBM(t *testing.T) {
+	x1, x2, x3 := DenseFeatureVector{0.0, 0.0}, DenseFeatureVector{1.0, float32(0.1)}, DenseFeatureVector{1.0, float32(math.NaN())}
+	for _, model := range []*Ensemble{
+		goassert.New(t).SucceedNew(UnmarshalLightGBM([]byte(lightgbmTestModel), nil)).(*Ensemble),
+		goassert.New(t).SucceedNew(UnmarshalLightGBMJSON([]byte(lightgbmTestModelJSON), nil)).(*Ensemble),
+	} {
+		goassert.New(t, []float32{1.0, 1.0}).Equal(model.Weights)
+		goassert.New(t, "(feature[0] <= 0.5 ? 1 : (feature[1] <= 0.099999994 ? 2 : 3))").Equal(model.Trees[0].String())
+		goassert.New(t, "0.5").Equal(model.Trees[1].String())
+		// float32(0.1) > 0.1, so it goes to the right as LightGBM does.
+		goassert.New(t, float32(1.5)).EqualWithoutError(model.PredictSum(x1))
+		goassert.New(t, float32(3.5)).EqualWithoutError(model.PredictSum(x2))
+		goassert.New(t, float32(2.5)).EqualWithoutError(model.PredictSum(x3))
+	}
+
+	// The trees of the classes are interleaved.
+	multiclass := strings.Replace(lightgbmTestModel, "num_tree_per_iteration=1", "num_tree_per_iteration=2", 1)
+	model := goassert.New(t).SucceedNew(UnmarshalLightGBM([]byte(multiclass), &LightGBMOptions{Class: 1})).(*Ensemble)
+	goassert.New(t, "0.5").Equal(model.Trees[0].String())
+	goassert.New(t, "class 2 is out of range [0, 2)").ExpectError(UnmarshalLightGBM([]byte(multiclass), &LightGBMOptions{Class: 2}))
+	// The trees are averaged with average_output.
+	model = goassert.New(t).SucceedNew(UnmarshalLightGBM([]byte(strings.Replace(lightgbmTestModel, "objective=regression\n", "objective=regression\naverage_output\n", 1)), nil)).(*Ensemble)
+	goassert.New(t, []float32{0.5, 0.5}).Equal(model.Weights)
+	model = goassert.New(t).SucceedNew(UnmarshalLightGBMJSON([]byte(strings.Replace(lightgbmTestModelJSON, `"average_output":false`, `"average_output":true`, 1)), nil)).(*Ensemble)
+	goassert.New(t, []float32{0.5, 0.5}).Equal(model.Weights)
+
+	// The missing type None sends NaN to the left only if 0 <= threshold.
+	negative := []byte(strings.Replace(lightgbmTestModel, "threshold=0.5 ", "threshold=-0.5 ", 1))
+	goassert.New(t).SucceedNew(UnmarshalLightGBM(negative, nil))
+	goassert.New(t, "tree 0: node 0: missing values must go to the left leaf, but missing type None with default_left=true does not").ExpectError(UnmarshalLightGBM(negative, &LightGBMOptions{StrictMissingValues: true}))
+	// The missing type Zero is supported only if zero goes to the default side by the threshold.
+	zeroLeft := []byte(strings.Replace(lightgbmTestModel, "decision_type=2 10", "decision_type=6 10", 1))
+	model = goassert.New(t).SucceedNew(UnmarshalLightGBM(zeroLeft, &LightGBMOptions{StrictMissingValues: true})).(*Ensemble)
+	goassert.New(t, float32(1.5)).EqualWithoutError(model.PredictSum(DenseFeatureVector{float32(math.NaN()), 0.0}))
+	goassert.New(t, "tree 0: node 0: missing type Zero with default_left=false is not supported for threshold 0.5").ExpectError(UnmarshalLightGBM([]byte(strings.Replace(lightgbmTestModel, "decision_type=2 10", "decision_type=4 10", 1)), nil))
+	goassert.New(t, "tree 0: node 1: missing values must go to the left leaf, but missing type NaN with default_left=false does not").ExpectError(UnmarshalLightGBM([]byte(strings.Replace(lightgbmTestModel, "decision_type=2 10", "decision_type=2 8", 1)), &LightGBMOptions{StrictMissingValues: true}))
+
+	goassert.New(t, "model must not be empty").ExpectError(UnmarshalLightGBM([]byte(""), nil))
+	goassert.New(t, "line 1: model must start with \"tree\"").ExpectError(UnmarshalLightGBM([]byte("{}"), nil))
+	goassert.New(t, "model must have \"end of trees\"").ExpectError(UnmarshalLightGBM([]byte(strings.Replace(lightgbmTestModel, "end of trees", "", 1)), nil))
+	goassert.New(t, "line 31: tree index must be 1").ExpectError(UnmarshalLightGBM([]byte(strings.Replace(lightgbmTestModel, "Tree=1", "Tree=2", 1)), nil))
+	goassert.New(t, "line 19: left_child: illegal integer \"x\"").ExpectError(UnmarshalLightGBM([]byte(strings.Replace(lightgbmTestModel, "left_child=-1 -2", "left_child=-1 x", 1)), nil))
+	goassert.New(t, "tree 0: node 0: categorical splits are not supported").ExpectError(UnmarshalLightGBM([]byte(strings.Replace(lightgbmTestModel, "decision_type=2 10", "decision_type=3 10", 1)), nil))
+	goassert.New(t, "tree 0: linear trees are not supported").ExpectError(UnmarshalLightGBM([]byte(strings.Replace(lightgbmTestModel, "is_linear=0", "is_linear=1", 1)), nil))
+	goassert.New(t, "tree 0: node 1 is out of range or visited twice").ExpectError(UnmarshalLightGBM([]byte(strings.Replace(lightgbmTestModel, "right_child=1 -3", "right_child=1 1", 1)), nil))
+	goassert.New(t, "tree 0: leaf 0 is out of range or visited twice").ExpectError(UnmarshalLightGBM([]byte(strings.Replace(lightgbmTestModel, "left_child=-1 -2", "left_child=-1 -1", 1)), nil))
+	goassert.New(t, "tree 1: leaf_value must have num_leaves values").ExpectError(UnmarshalLightGBM([]byte(strings.Replace(lightgbmTestModel, "leaf_value=0.5", "leaf_value=", 1)), nil))
+	goassert.New(t, "the number of trees must be a multiple of num_tree_per_iteration").ExpectError(UnmarshalLightGBM([]byte(strings.Replace(lightgbmTestModel, "num_tree_per_iteration=1", "num_tree_per_iteration=3", 1)), nil))
+
+	goassert.New(t, "num_tree_per_iteration must be given").ExpectError(UnmarshalLightGBMJSON([]byte(`{}`), nil))
+	goassert.New(t, "tree 0: tree_structure must be given").ExpectError(UnmarshalLightGBMJSON([]byte(`{"num_tree_per_iteration":1,"tree_info":[{}]}`), nil))
+	goassert.New(t, "tree 0: split 0: unsupported decision_type \"==\"").ExpectError(UnmarshalLightGBMJSON([]byte(strings.Replace(lightgbmTestModelJSON, `"threshold":0.5,"decision_type":"<="`, `"threshold":"1||2","decision_type":"=="`, 1)), nil))
+	goassert.New(t, "tree 0: split 1: unsupported missing type \"Other\"").ExpectError(UnmarshalLightGBMJSON([]byte(strings.Replace(lightgbmTestModelJSON, `"missing_type":"NaN"`, `"missing_type":"Other"`, 1)), nil))
+	goassert.New(t, "tree 0: split 0: illegal feature -1").ExpectError(UnmarshalLightGBMJSON([]byte(strings.Replace(lightgbmTestModelJSON, `"split_feature":0`, `"split_feature":-1`, 1)), nil))
+}
@@ -0,0 +1,268 @@
+package confeito
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ObliviousMaxDepth is the maximum depth of ObliviousTree.
+const ObliviousMaxDepth = 24
+
+// ObliviousTree is a symmetric (oblivious) tree, whose nodes at the same depth have the same split.
+// The d-th split "x[FeatureIDs[d]] <= Thresholds[d]" gives the d-th bit of the leaf index, which is 1 if the split goes to the right.
+// Thus, the tree of depth len(FeatureIDs) has 2^depth terminal leaves with Values indexed by the leaf index.
+// Like Leaf, missing (NaN) values go to the left.
+type ObliviousTree struct {
+	FeatureIDs []FeatureID
+	Thresholds []float32
+	Values     []float32
+}
+
+// Validate validates tree.
+//
+// This function returns an error if tree is deeper than ObliviousMaxDepth, FeatureIDs and Thresholds have different lengths, a feature ID is illegal, a threshold is not finite, or the number of Values is not 2^depth.
+func (tree *ObliviousTree) Validate() error {
+	depth := len(tree.FeatureIDs)
+	if depth > ObliviousMaxDepth {
+		return fmt.Errorf("depth must not be greater than %d", ObliviousMaxDepth)
+	}
+	if len(tree.Thresholds) != depth {
+		return fmt.Errorf("the number of thresholds must be equal to the number of feature IDs")
+	}
+	for d, featureID := range tree.FeatureIDs {
+		if featureID == _FEATURE_ID_ILLEGAL {
+			return fmt.Errorf("split %d: feature ID must be legal one", d)
+		}
+		if threshold := float64(tree.Thresholds[d]); math.IsNaN(threshold) || math.IsInf(threshold, 0) {
+			return fmt.Errorf("split %d: threshold must be finite: %g", d, threshold)
+		}
+	}
+	if len(tree.Values) != 1<<uint(depth) {
+		return fmt.Errorf("the number of values must be %d", 1<<uint(depth))
+	}
+	return nil
+}
+
+// Predict returns the predicted value of the given feature.
+//
+// This function returns an error at getting feature values of x.
+func (tree *ObliviousTree) Predict(x FeatureVector) (float32, error) {
+	index := 0
+	for d, featureID := range tree.FeatureIDs {
+		if value, _ := x.Get(featureID); value > tree.Thresholds[d] {
+			index |= 1 << uint(d)
+		}
+	}
+	return tree.Values[index], nil
+}
+
+// Leaf returns a new tree (*Leaf) equivalent to tree, where the root has the first split.
+// The tree has 2^depth terminal leaves, so Forest supports only the trees of depth at most 6.
+//
+// This function returns an error if tree is malformed (see Validate).
+func (tree *ObliviousTree) Leaf() (*Leaf, error) {
+	if err := tree.Validate(); err != nil {
+		return nil, err
+	}
+	var build func(d, index int) *Leaf
+	build = func(d, index int) *Leaf {
+		if d == len(tree.FeatureIDs) {
+			return &Leaf{featureID: _FEATURE_ID_TERMINAL_LEAF, value: tree.Values[index]}
+		}
+		return &Leaf{
+			featureID: tree.FeatureIDs[d],
+			threshold: tree.Thresholds[d],
+			left:      build(d+1, index),
+			right:     build(d+1, index|1<<uint(d)),
+		}
+	}
+	return build(0, 0), nil
+}
+
+// ObliviousForest is a weighted sum of ObliviousTree designed for fast prediction.
+// The distinct splits (borders) of all trees are evaluated once for each feature with a binary search, and the leaf index of each tree is packed from the border bits of its splits.
+// This is much faster than Forest for the oblivious trees, because the leaf index is computed with a few lookups per tree.
+//
+// ObliviousForest is immutable, so all methods are safe for concurrent use.
+type ObliviousForest struct {
+	// featureIDs has the distinct feature IDs, and the borders of featureIDs[i] are in [borderOffsets[i], borderOffsets[i+1]) sorted by the threshold.
+	featureIDs    []FeatureID
+	borderOffsets []int
+	thresholds    []float32
+	// The d-th split of tree t is the border splits[splitOffsets[t]+d].
+	splits       []int
+	splitOffsets []int
+	// The values of tree t are in values[valueOffsets[t]:valueOffsets[t+1]].
+	values       []float32
+	valueOffsets []int
+	weights      []float32
+}
+
+// NewObliviousForest returns a new ObliviousForest of trees[t] weighted by weights[t].
+//
+// This function returns an error if the numbers of trees and weights differ, a weight is not finite, or a tree is malformed (see ObliviousTree.Validate).
+func NewObliviousForest(trees []*ObliviousTree, weights []float32) (*ObliviousForest, error) {
+	if len(trees) != len(weights) {
+		return nil, fmt.Errorf("the number of weights must be equal to the number of trees")
+	}
+	type border struct {
+		featureID FeatureID
+		threshold float32
+	}
+	borderSet := map[border]bool{}
+	for t, tree := range trees {
+		if weight := float64(weights[t]); math.IsNaN(weight) || math.IsInf(weight, 0) {
+			return nil, fmt.Errorf("weight must be finite")
+		}
+		if err := tree.Validate(); err != nil {
+			return nil, fmt.Errorf("tree %d: %s", t, err)
+		}
+		for d, featureID := range tree.FeatureIDs {
+			borderSet[border{featureID, tree.Thresholds[d]}] = true
+		}
+	}
+	borders := make([]border, 0, len(borderSet))
+	for b := range borderSet {
+		borders = append(borders, b)
+	}
+	sort.Slice(borders, func(i, j int) bool {
+		if borders[i].featureID != borders[j].featureID {
+			return borders[i].featureID < borders[j].featureID
+		}
+		return borders[i].threshold < borders[j].threshold
+	})
+	forest := &ObliviousForest{
+		thresholds:   make([]float32, len(borders)),
+		splitOffsets: []int{0},
+		valueOffsets: []int{0},
+		weights:      append([]float32{}, weights...),
+	}
+	borderIndices := make(map[border]int, len(borders))
+	for b, border := range borders {
+		if b == 0 || border.featureID != borders[b-1].featureID {
+			forest.featureIDs = append(forest.featureIDs, border.featureID)
+			forest.borderOffsets = append(forest.borderOffsets, b)
+		}
+		forest.thresholds[b] = border.threshold
+		borderIndices[border] = b
+	}
+	forest.borderOffsets = append(forest.borderOffsets, len(borders))
+	for _, tree := range trees {
+		for d, featureID := range tree.FeatureIDs {
+			forest.splits = append(forest.splits, borderIndices[border{featureID, tree.Thresholds[d]}])
+		}
+		forest.values = append(forest.values, tree.Values...)
+		forest.splitOffsets = append(forest.splitOffsets, len(forest.splits))
+		forest.valueOffsets = append(forest.valueOffsets, len(forest.values))
+	}
+	return forest, nil
+}
+
+// NumTrees returns the number of trees in forest.
+func (forest *ObliviousForest) NumTrees() int {
+	return len(forest.weights)
+}
+
+// Weights returns a slice of the weight of each tree of forest.
+func (forest *ObliviousForest) Weights() []float32 {
+	return append([]float32{}, forest.weights...)
+}
+
+// Tree returns a copy of the i-th tree of forest.
+//
+// This function returns an error if i is out of range.
+func (forest *ObliviousForest) Tree(i int) (*ObliviousTree, error) {
+	if !(0 <= i && i < forest.NumTrees()) {
+		return nil, fmt.Errorf("i is out of range [0, %d)", forest.NumTrees())
+	}
+	tree := &ObliviousTree{
+		FeatureIDs: []FeatureID{},
+		Thresholds: []float32{},
+		Values:     append([]float32{}, forest.values[forest.valueOffsets[i]:forest.valueOffsets[i+1]]...),
+	}
+	for _, b := range forest.splits[forest.splitOffsets[i]:forest.splitOffsets[i+1]] {
+		f := sort.Search(len(forest.featureIDs), func(f int) bool { return forest.borderOffsets[f+1] > b })
+		tree.FeatureIDs, tree.Thresholds = append(tree.FeatureIDs, forest.featureIDs[f]), append(tree.Thresholds, forest.thresholds[b])
+	}
+	return tree, nil
+}
+
+// Ensemble returns a new Ensemble having the trees (*Leaf) equivalent to the trees of forest with Weights.
+// The trees can be exported or compiled into Forest, which supports only the trees of depth at most 6.
+func (forest *ObliviousForest) Ensemble() *Ensemble {
+	ensemble := &Ensemble{Weights: forest.Weights()}
+	for t := 0; t < forest.NumTrees(); t++ {
+		tree, _ := forest.Tree(t)
+		leaf, _ := tree.Leaf()
+		ensemble.Trees = append(ensemble.Trees, leaf)
+	}
+	return ensemble
+}
+
+// Predict returns a slice of the value predicted by each tree of forest.
+//
+// This function returns an error at getting feature values of x.
+func (forest *ObliviousForest) Predict(x FeatureVector) ([]float32, error) {
+	bits := forest.evaluateBorders(x)
+	values := make([]float32, forest.NumTrees())
+	for t := range values {
+		values[t] = forest.values[forest.valueOffsets[t]+forest.leafIndex(bits, t)]
+	}
+	return values, nil
+}
+
+// PredictLeaves returns a slice of the leaf index (see ObliviousTree) of the terminal leaf reached by x in each tree of forest.
+//
+// This function returns an error at getting feature values of x.
+func (forest *ObliviousForest) PredictLeaves(x FeatureVector) ([]int, error) {
+	bits := forest.evaluateBorders(x)
+	leaves := make([]int, forest.NumTrees())
+	for t := range leaves {
+		leaves[t] = forest.leafIndex(bits, t)
+	}
+	return leaves, nil
+}
+
+// PredictSum returns the sum of the values predicted by each tree of forest weighted by Weights.
+//
+// This function returns an error at getting feature values of x.
+func (forest *ObliviousForest) PredictSum(x FeatureVector) (float32, error) {
+	bits := forest.evaluateBorders(x)
+	sum := float32(0.0)
+	for t, weight := range forest.weights {
+		sum += weight * forest.values[forest.valueOffsets[t]+forest.leafIndex(bits, t)]
+	}
+	return sum, nil
+}
+
+// evaluateBorders returns the bits of the borders for x, which is 1 if the border goes to the right.
+func (forest *ObliviousForest) evaluateBorders(x FeatureVector) []uint8 {
+	bits := make([]uint8, len(forest.thresholds))
+	for f, featureID := range forest.featureIDs {
+		value, _ := x.Get(featureID)
+		// The borders having the thresholds less than value go to the right.
+		left, right := forest.borderOffsets[f], forest.borderOffsets[f+1]
+		for left < right {
+			middle := (left + right) / 2
+			if forest.thresholds[middle] < value {
+				left = middle + 1
+			} else {
+				right = middle
+			}
+		}
+		for b := forest.borderOffsets[f]; b < right; b++ {
+			bits[b] = 1
+		}
+	}
+	return bits
+}
+
+// leafIndex returns the leaf index of tree t with the border bits.
+func (forest *ObliviousForest) leafIndex(bits []uint8, t int) int {
+	index := 0
+	for d, b := range forest.splits[forest.splitOffsets[t]:forest.splitOffsets[t+1]] {
+		index |= int(bits[b]) << uint(d)
+	}
+	return index
+}
@@ -0,0 +1,137 @@
+package confeito
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+// newRandomObliviousTrees returns ntrees random oblivious trees of depth on dim features, whose thresholds are shared frequently.
+func newRandomObliviousTrees(rng *rand.Rand, ntrees, depth, dim int) []*ObliviousTree {
+	trees := make([]*ObliviousTree, ntrees)
+	for t := range trees {
+		tree := &ObliviousTree{Values: make([]float32, 1<<uint(depth))}
+		for d := 0; d < depth; d++ {
+			tree.FeatureIDs = append(tree.FeatureIDs, FeatureID(rng.Intn(dim)))
+			tree.Thresholds = append(tree.Thresholds, float32(rng.Intn(8))/4.0)
+		}
+		for i := range tree.Values {
+			tree.Values[i] = rng.Float32()
+		}
+		trees[t] = tree
+	}
+	return trees
+}
+
+func TestObliviousTree(t *testing.T) {
+	tree := &ObliviousTree{
+		FeatureIDs: []FeatureID{0, 1},
+		Thresholds: []float32{0.5, 1.0},
+		Values:     []float32{1.0, 2.0, 3.0, 4.0},
+	}
+	goassert.New(t).SucceedWithoutError(tree.Validate())
+	goassert.New(t, float32(1.0)).EqualWithoutError(tree.Predict(DenseFeatureVector{0.5, 1.0}))
+	goassert.New(t, float32(2.0)).EqualWithoutError(tree.Predict(DenseFeatureVector{1.0, 1.0}))
+	goassert.New(t, float32(3.0)).EqualWithoutError(tree.Predict(DenseFeatureVector{0.0, 2.0}))
+	goassert.New(t, float32(4.0)).EqualWithoutError(tree.Predict(DenseFeatureVector{1.0, 2.0}))
+	goassert.New(t, float32(3.0)).EqualWithoutError(tree.Predict(DenseFeatureVector{float32(math.NaN()), 2.0}))
+	leaf := goassert.New(t).SucceedNew(tree.Leaf()).(*Leaf)
+	goassert.New(t, "(feature[0] <= 0.5 ? (feature[1] <= 1 ? 1 : 3) : (feature[1] <= 1 ? 2 : 4))").Equal(leaf.String())
+
+	stump := &ObliviousTree{Values: []float32{1.0}}
+	goassert.New(t, float32(1.0)).EqualWithoutError(stump.Predict(DenseFeatureVector{}))
+	goassert.New(t, "1").Equal(goassert.New(t).SucceedNew(stump.Leaf()).(*Leaf).String())
+
+	goassert.New(t, "the number of thresholds must be equal to the number of feature IDs").ExpectError((&ObliviousTree{FeatureIDs: []FeatureID{0}, Values: []float32{1.0, 2.0}}).Leaf())
+	goassert.New(t, "split 0: feature ID must be legal one").ExpectError((&ObliviousTree{FeatureIDs: []FeatureID{_FEATURE_ID_ILLEGAL}, Thresholds: []float32{0.0}, Values: []float32{1.0, 2.0}}).Leaf())
+	goassert.New(t, "split 0: threshold must be finite: NaN").ExpectError((&ObliviousTree{FeatureIDs: []FeatureID{0}, Thresholds: []float32{float32(math.NaN())}, Values: []float32{1.0, 2.0}}).Leaf())
+	goassert.New(t, "the number of values must be 2").ExpectError((&ObliviousTree{FeatureIDs: []FeatureID{0}, Thresholds: []float32{0.0}, Values: []float32{1.0}}).Leaf())
+	goassert.New(t, "depth must not be greater than 24").ExpectError((&ObliviousTree{FeatureIDs: make([]FeatureID, 25)}).Leaf())
+}
+
+func TestObliviousForest(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	trees := newRandomObliviousTrees(rng, 100, 6, 8)
+	weights := make([]float32, len(trees))
+	for i := range weights {
+		weights[i] = rng.Float32()
+	}
+	forest := goassert.New(t).SucceedNew(NewObliviousForest(trees, weights)).(*ObliviousForest)
+	goassert.New(t, 100).Equal(forest.NumTrees())
+	goassert.New(t, weights).Equal(forest.Weights())
+	for i, tree := range trees {
+		goassert.New(t, tree).EqualWithoutError(forest.Tree(i))
+	}
+	goassert.New(t, "i is out of range [0, 100)").ExpectError(forest.Tree(100))
+	ensemble := forest.Ensemble()
+	goassert.New(t, weights).Equal(ensemble.Weights)
+	leafForest := goassert.New(t).SucceedNew(ensemble.Forest()).(*Forest)
+	for i := 0; i < 100; i++ {
+		x := make(DenseFeatureVector, 8)
+		for j := range x {
+			x[j] = float32(rng.Intn(10)) / 4.0
+			if rng.Intn(10) == 0 {
+				x[j] = float32(math.NaN())
+			}
+		}
+		expected := make([]float32, len(trees))
+		sum := float32(0.0)
+		for k, tree := range trees {
+			expected[k] = goassert.New(t).SucceedNew(tree.Predict(x)).(float32)
+			sum += weights[k] * expected[k]
+		}
+		goassert.New(t, expected).EqualWithoutError(forest.Predict(x))
+		leaves := goassert.New(t).SucceedNew(forest.PredictLeaves(x)).([]int)
+		for k, tree := range trees {
+			goassert.New(t, expected[k]).Equal(tree.Values[leaves[k]])
+		}
+		goassert.New(t, sum).EqualWithoutError(forest.PredictSum(x))
+		goassert.New(t, sum).EqualWithoutError(ensemble.PredictSum(x))
+		goassert.New(t, sum).EqualWithoutError(leafForest.PredictSum(x))
+	}
+
+	goassert.New(t, "the number of weights must be equal to the number of trees").ExpectError(NewObliviousForest(trees, nil))
+	goassert.New(t, "weight must be finite").ExpectError(NewObliviousForest(trees[:1], []float32{float32(math.Inf(1))}))
+	goassert.New(t, "tree 0: the number of values must be 1").ExpectError(NewObliviousForest([]*ObliviousTree{{}}, []float32{1.0}))
+}
+
+func BenchmarkObliviousForest(b *testing.B) {
+	dim, ntrees, depth := 256, 4096, 6
+	rng := rand.New(rand.NewSource(0))
+	trees := newRandomObliviousTrees(rng, ntrees, depth, dim)
+	weights := make([]float32, ntrees)
+	for t := range weights {
+		weights[t] = 1.0
+	}
+	forest := goassert.New(b).SucceedNew(NewObliviousForest(trees, weights)).(*ObliviousForest)
+	x := make(DenseFeatureVector, dim)
+	for i := range x {
+		x[i] = float32(rng.Intn(10)) / 4.0
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		forest.PredictSum(x)
+	}
+}
+
+func BenchmarkObliviousForestAsForest(b *testing.B) {
+	dim, ntrees, depth := 256, 4096, 6
+	rng := rand.New(rand.NewSource(0))
+	trees := newRandomObliviousTrees(rng, ntrees, depth, dim)
+	weights := make([]float32, ntrees)
+	for t := range weights {
+		weights[t] = 1.0
+	}
+	obliviousForest := goassert.New(b).SucceedNew(NewObliviousForest(trees, weights)).(*ObliviousForest)
+	forest := goassert.New(b).SucceedNew(obliviousForest.Ensemble().Forest()).(*Forest)
+	x := make(DenseFeatureVector, dim)
+	for i := range x {
+		x[i] = float32(rng.Intn(10)) / 4.0
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		forest.PredictSum(x)
+	}
+}
@@ -0,0 +1,422 @@
+package confeito
+
+import (
+	"fmt"
+	"sort"
+)
+
+// The domain of the ONNX-ML operators.
+const _ONNX_ML_DOMAIN = "ai.onnx.ml"
+
+// onnxAttribute is an attribute of an ONNX node.
+// The scalar values are stored as the repeated values of length 1.
+type onnxAttribute struct {
+	floats    []float32
+	ints      []int64
+	strings   []string
+	hasTensor bool
+}
+
+// onnxNode is a node of an ONNX graph.
+type onnxNode struct {
+	opType     string
+	domain     string
+	attributes map[string]*onnxAttribute
+}
+
+// parseONNXAttribute returns the name and the attribute encoded as AttributeProto in data.
+func parseONNXAttribute(data []byte) (string, *onnxAttribute, error) {
+	fields, err := parseProtoMessage(data)
+	if err != nil {
+		return "", nil, err
+	}
+	name, attr := "", &onnxAttribute{}
+	for _, field := range fields {
+		switch field.number {
+		case 1:
+			name = string(field.bytes)
+		case 2, 7:
+			floats, err := field.float32s()
+			if err != nil {
+				return "", nil, err
+			}
+			attr.floats = append(attr.floats, floats...)
+		case 3, 8:
+			ints, err := field.int64s()
+			if err != nil {
+				return "", nil, err
+			}
+			attr.ints = append(attr.ints, ints...)
+		case 4, 9:
+			attr.strings = append(attr.strings, string(field.bytes))
+		case 5, 11:
+			attr.hasTensor = true
+		}
+	}
+	return name, attr, nil
+}
+
+// parseONNXNode returns the node encoded as NodeProto in data.
+func parseONNXNode(data []byte) (*onnxNode, error) {
+	fields, err := parseProtoMessage(data)
+	if err != nil {
+		return nil, err
+	}
+	node := &onnxNode{attributes: make(map[string]*onnxAttribute)}
+	for _, field := range fields {
+		switch field.number {
+		case 4:
+			node.opType = string(field.bytes)
+		case 7:
+			node.domain = string(field.bytes)
+		case 5:
+			name, attr, err := parseONNXAttribute(field.bytes)
+			if err != nil {
+				return nil, err
+			}
+			node.attributes[name] = attr
+		}
+	}
+	return node, nil
+}
+
+// ints returns the ints of attribute name, or nil if it is not given.
+func (node *onnxNode) ints(name string) []int64 {
+	if attr, ok := node.attributes[name]; ok {
+		return attr.ints
+	}
+	return nil
+}
+
+// floats returns the floats of attribute name, or nil if it is not given.
+func (node *onnxNode) floats(name string) []float32 {
+	if attr, ok := node.attributes[name]; ok {
+		return attr.floats
+	}
+	return nil
+}
+
+// strings returns the strings of attribute name, or nil if it is not given.
+func (node *onnxNode) strings(name string) []string {
+	if attr, ok := node.attributes[name]; ok {
+		return attr.strings
+	}
+	return nil
+}
+
+// stringValue returns the string of attribute name, or defaultValue if it is not given.
+func (node *onnxNode) stringValue(name, defaultValue string) string {
+	if strings := node.strings(name); len(strings) > 0 {
+		return strings[0]
+	}
+	return defaultValue
+}
+
+// findONNXTreeEnsembleNode returns the TreeEnsembleRegressor or TreeEnsembleClassifier node in the ONNX model encoded as ModelProto in data.
+//
+// This function returns an error if data is malformed, or the graph does not have exactly one tree ensemble node.
+func findONNXTreeEnsembleNode(data []byte) (*onnxNode, error) {
+	fields, err := parseProtoMessage(data)
+	if err != nil {
+		return nil, fmt.Errorf("model: %s", err)
+	}
+	var graph []byte
+	for _, field := range fields {
+		if field.number == 7 {
+			graph = field.bytes
+		}
+	}
+	if graph == nil {
+		return nil, fmt.Errorf("model must have graph")
+	}
+	if fields, err = parseProtoMessage(graph); err != nil {
+		return nil, fmt.Errorf("graph: %s", err)
+	}
+	var ensembleNode *onnxNode
+	for _, field := range fields {
+		if field.number != 1 {
+			continue
+		}
+		node, err := parseONNXNode(field.bytes)
+		if err != nil {
+			return nil, fmt.Errorf("node: %s", err)
+		}
+		if node.domain != _ONNX_ML_DOMAIN || (node.opType != "TreeEnsembleRegressor" && node.opType != "TreeEnsembleClassifier") {
+			continue
+		}
+		if ensembleNode != nil {
+			return nil, fmt.Errorf("graph must have exactly one tree ensemble node")
+		}
+		ensembleNode = node
+	}
+	if ensembleNode == nil {
+		return nil, fmt.Errorf("graph must have exactly one tree ensemble node")
+	}
+	return ensembleNode, nil
+}
+
+// ONNXOptions is the options of importing ONNX models by UnmarshalONNX.
+// The zero value imports the first target or class, and ignores the missing value tracks which Leaf cannot represent.
+type ONNXOptions struct {
+	// Target is the target ID of TreeEnsembleRegressor or the class ID of TreeEnsembleClassifier whose score is imported.
+	Target int
+	// StrictMissingValueTracks rejects the branches whose missing value tracks (nodes_missing_value_tracks_true) send missing (NaN) feature values to the right if true.
+	// Leaf takes the left leaf for missing feature values, so such branches are imported as they are by default, and the import is lossy: the predictions for NaN differ from ONNX runtimes.
+	// Such branches include BRANCH_LEQ and BRANCH_LT without the missing value tracks, and the predictions for the other feature values are exact.
+	StrictMissingValueTracks bool
+}
+
+// ONNXModel is a tree ensemble imported from an ONNX model by UnmarshalONNX.
+type ONNXModel struct {
+	Ensemble
+	// PostTransform is the post_transform attribute (e.g., "NONE" and "LOGISTIC").
+	// It is not applied to the score of Ensemble.
+	PostTransform string
+}
+
+// onnxTreeEnsemble has the attributes of TreeEnsembleRegressor or TreeEnsembleClassifier.
+type onnxTreeEnsemble struct {
+	node              *onnxNode
+	treeIDs           []int64
+	nodeIDs           []int64
+	featureIDs        []int64
+	trueNodeIDs       []int64
+	falseNodeIDs      []int64
+	modes             []string
+	values            []float32
+	missingTracksTrue []int64
+	options           *ONNXOptions
+	leafValues        map[[2]int64]float32
+}
+
+// buildTree returns a new tree whose root is the node at index i.
+func (ensemble *onnxTreeEnsemble) buildTree(i int, index map[[2]int64]int, visited []bool) (*Leaf, error) {
+	treeID, nodeID := ensemble.treeIDs[i], ensemble.nodeIDs[i]
+	if visited[i] {
+		return nil, fmt.Errorf("tree %d: node %d is visited twice", treeID, nodeID)
+	}
+	visited[i] = true
+	mode := ensemble.modes[i]
+	if mode == "LEAF" {
+		return NewTerminalLeaf(ensemble.leafValues[[2]int64{treeID, nodeID}])
+	}
+	// The true branch is taken if feature value (mode) threshold.
+	// ">=" and ">" are the negations of "<" and "<=", so their true branches are the right leaves.
+	operator, trueIsLeft := LessOrEqual, true
+	switch mode {
+	case "BRANCH_LEQ":
+	case "BRANCH_LT":
+		operator = Less
+	case "BRANCH_GTE":
+		operator, trueIsLeft = Less, false
+	case "BRANCH_GT":
+		trueIsLeft = false
+	default:
+		return nil, fmt.Errorf("tree %d: node %d: unsupported mode %q", treeID, nodeID, mode)
+	}
+	missingTrue := len(ensemble.missingTracksTrue) > 0 && ensemble.missingTracksTrue[i] != 0
+	if missingTrue != trueIsLeft && ensemble.options.StrictMissingValueTracks {
+		return nil, fmt.Errorf("tree %d: node %d: missing values must go to the left leaf, but %s with nodes_missing_value_tracks_true=%t does not", treeID, nodeID, mode, missingTrue)
+	}
+	if !(0 <= ensemble.featureIDs[i] && ensemble.featureIDs[i] < int64(_FEATURE_ID_ILLEGAL)) {
+		return nil, fmt.Errorf("tree %d: node %d: illegal feature ID %d", treeID, nodeID, ensemble.featureIDs[i])
+	}
+	children := [2]*Leaf{}
+	for c, childID := range []int64{ensemble.trueNodeIDs[i], ensemble.falseNodeIDs[i]} {
+		child, err := ensemble.buildTree(index[[2]int64{treeID, childID}], index, visited)
+		if err != nil {
+			return nil, err
+		}
+		children[c] = child
+	}
+	if !trueIsLeft {
+		children[0], children[1] = children[1], children[0]
+	}
+	return &Leaf{
+		featureID: FeatureID(ensemble.featureIDs[i]),
+		threshold: ensemble.values[i],
+		operator:  operator,
+		left:      children[0],
+		right:     children[1],
+	}, nil
+}
+
+// findRoots returns the index of the root node of each tree, which is the only node not referenced as a child in the tree.
+//
+// This function returns an error if a child node is not found, or a tree does not have exactly one root.
+func (ensemble *onnxTreeEnsemble) findRoots(index map[[2]int64]int) (map[int64]int, error) {
+	children := make(map[[2]int64]bool)
+	for i, mode := range ensemble.modes {
+		if mode == "LEAF" {
+			continue
+		}
+		for _, childID := range []int64{ensemble.trueNodeIDs[i], ensemble.falseNodeIDs[i]} {
+			key := [2]int64{ensemble.treeIDs[i], childID}
+			if _, ok := index[key]; !ok {
+				return nil, fmt.Errorf("tree %d: node %d: child node %d is not found", ensemble.treeIDs[i], ensemble.nodeIDs[i], childID)
+			}
+			children[key] = true
+		}
+	}
+	roots := make(map[int64]int)
+	for i, treeID := range ensemble.treeIDs {
+		if children[[2]int64{treeID, ensemble.nodeIDs[i]}] {
+			continue
+		}
+		if _, ok := roots[treeID]; ok {
+			return nil, fmt.Errorf("tree %d must have exactly one root", treeID)
+		}
+		roots[treeID] = i
+	}
+	for _, treeID := range ensemble.treeIDs {
+		if _, ok := roots[treeID]; !ok {
+			return nil, fmt.Errorf("tree %d must have exactly one root", treeID)
+		}
+	}
+	return roots, nil
+}
+
+// UnmarshalONNX returns a new ONNXModel of the TreeEnsembleRegressor or TreeEnsembleClassifier node in the ONNX model encoded in data.
+// The other nodes in the graph (e.g., ZipMap) are ignored.
+//
+// The score of options.Target is the weighted sum of trees, where each terminal leaf has the sum of its target_weights (or class_weights) of the target, each tree has weight 1 (or 1/ntrees with aggregate_function AVERAGE), and base_values is the constant tree with weight 1.
+// The branches BRANCH_LEQ, BRANCH_LT, BRANCH_GTE and BRANCH_GT are converted into the splits "x <= threshold" and "x < threshold" with the same thresholds.
+// Thus, the splits are exact for the feature values which are not NaN.
+// See ONNXOptions for the missing value tracks.
+//
+// This function returns an error if data is malformed, or the model has an unsupported mode, aggregate function or tensor attribute.
+func UnmarshalONNX(data []byte, options *ONNXOptions) (*ONNXModel, error) {
+	if options == nil {
+		options = &ONNXOptions{}
+	}
+	node, err := findONNXTreeEnsembleNode(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"nodes_values_as_tensor", "base_values_as_tensor", "target_weights_as_tensor", "class_weights_as_tensor", "nodes_hitrates_as_tensor"} {
+		if attr, ok := node.attributes[name]; ok && attr.hasTensor {
+			return nil, fmt.Errorf("%s: %s is not supported", node.opType, name)
+		}
+	}
+	ensemble := &onnxTreeEnsemble{
+		node:              node,
+		treeIDs:           node.ints("nodes_treeids"),
+		nodeIDs:           node.ints("nodes_nodeids"),
+		featureIDs:        node.ints("nodes_featureids"),
+		trueNodeIDs:       node.ints("nodes_truenodeids"),
+		falseNodeIDs:      node.ints("nodes_falsenodeids"),
+		modes:             node.strings("nodes_modes"),
+		values:            node.floats("nodes_values"),
+		missingTracksTrue: node.ints("nodes_missing_value_tracks_true"),
+		options:           options,
+	}
+	n := len(ensemble.treeIDs)
+	if n == 0 {
+		return nil, fmt.Errorf("%s: nodes_treeids must not be empty", node.opType)
+	}
+	for _, attr := range []struct {
+		name   string
+		length int
+	}{
+		{"nodes_nodeids", len(ensemble.nodeIDs)},
+		{"nodes_featureids", len(ensemble.featureIDs)},
+		{"nodes_truenodeids", len(ensemble.trueNodeIDs)},
+		{"nodes_falsenodeids", len(ensemble.falseNodeIDs)},
+		{"nodes_modes", len(ensemble.modes)},
+		{"nodes_values", len(ensemble.values)},
+	} {
+		if attr.length != n {
+			return nil, fmt.Errorf("%s: %s must have length %d", node.opType, attr.name, n)
+		}
+	}
+	if len(ensemble.missingTracksTrue) != 0 && len(ensemble.missingTracksTrue) != n {
+		return nil, fmt.Errorf("%s: nodes_missing_value_tracks_true must have length %d", node.opType, n)
+	}
+	// The leaf weights and the number of targets depend on the operator.
+	prefix, ntargets, aggregate := "target", 0, "SUM"
+	switch node.opType {
+	case "TreeEnsembleRegressor":
+		ntargets = 1
+		if nTargets := node.ints("n_targets"); len(nTargets) > 0 {
+			ntargets = int(nTargets[0])
+		}
+		aggregate = node.stringValue("aggregate_function", "SUM")
+	case "TreeEnsembleClassifier":
+		prefix = "class"
+		ntargets = len(node.ints("classlabels_int64s")) + len(node.strings("classlabels_strings"))
+		if ntargets == 0 {
+			return nil, fmt.Errorf("%s: classlabels_int64s or classlabels_strings must be given", node.opType)
+		}
+	}
+	if !(0 <= options.Target && options.Target < ntargets) {
+		return nil, fmt.Errorf("%s: target %d is out of range [0, %d)", node.opType, options.Target, ntargets)
+	}
+	leafTreeIDs, leafNodeIDs := node.ints(prefix+"_treeids"), node.ints(prefix+"_nodeids")
+	leafTargetIDs, leafWeights := node.ints(prefix+"_ids"), node.floats(prefix+"_weights")
+	m := len(leafTreeIDs)
+	if len(leafNodeIDs) != m || len(leafTargetIDs) != m || len(leafWeights) != m {
+		return nil, fmt.Errorf("%s: %s_treeids, %s_nodeids, %s_ids and %s_weights must have the same length", node.opType, prefix, prefix, prefix, prefix)
+	}
+	ensemble.leafValues = make(map[[2]int64]float32)
+	for k := 0; k < m; k++ {
+		if leafTargetIDs[k] == int64(options.Target) {
+			ensemble.leafValues[[2]int64{leafTreeIDs[k], leafNodeIDs[k]}] += leafWeights[k]
+		}
+	}
+	// Build the trees in the ascending order of tree IDs.
+	index := make(map[[2]int64]int)
+	for i := 0; i < n; i++ {
+		key := [2]int64{ensemble.treeIDs[i], ensemble.nodeIDs[i]}
+		if _, ok := index[key]; ok {
+			return nil, fmt.Errorf("%s: tree %d: node %d is duplicated", node.opType, key[0], key[1])
+		}
+		index[key] = i
+	}
+	roots, err := ensemble.findRoots(index)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", node.opType, err)
+	}
+	treeIDs := make([]int64, 0, len(roots))
+	for treeID := range roots {
+		treeIDs = append(treeIDs, treeID)
+	}
+	sort.Slice(treeIDs, func(i, j int) bool {
+		return treeIDs[i] < treeIDs[j]
+	})
+	var weight float32
+	switch aggregate {
+	case "SUM":
+		weight = 1.0
+	case "AVERAGE":
+		weight = float32(1.0 / float64(len(treeIDs)))
+	default:
+		return nil, fmt.Errorf("%s: unsupported aggregate_function %q", node.opType, aggregate)
+	}
+	model := &ONNXModel{
+		PostTransform: node.stringValue("post_transform", "NONE"),
+	}
+	visited := make([]bool, n)
+	for _, treeID := range treeIDs {
+		tree, err := ensemble.buildTree(roots[treeID], index, visited)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", node.opType, err)
+		}
+		model.Trees, model.Weights = append(model.Trees, tree), append(model.Weights, weight)
+	}
+	for i, ok := range visited {
+		if !ok {
+			return nil, fmt.Errorf("%s: tree %d: node %d is not reachable from the root", node.opType, ensemble.treeIDs[i], ensemble.nodeIDs[i])
+		}
+	}
+	if baseValues := node.floats("base_values"); len(baseValues) > 0 {
+		if len(baseValues) != ntargets {
+			return nil, fmt.Errorf("%s: base_values must have length %d", node.opType, ntargets)
+		}
+		baseLeaf, err := NewTerminalLeaf(baseValues[options.Target])
+		if err != nil {
+			return nil, err
+		}
+		model.Trees, model.Weights = append(model.Trees, baseLeaf), append(model.Weights, 1.0)
+	}
+	return model, nil
+}
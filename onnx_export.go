@@ -0,0 +1,179 @@
+package confeito
+
+import (
+	"fmt"
+)
+
+// The types of ONNX AttributeProto.
+const (
+	_ONNX_ATTRIBUTE_INT     = 2
+	_ONNX_ATTRIBUTE_STRING  = 3
+	_ONNX_ATTRIBUTE_FLOATS  = 6
+	_ONNX_ATTRIBUTE_INTS    = 7
+	_ONNX_ATTRIBUTE_STRINGS = 8
+)
+
+// The element type FLOAT of ONNX TensorProto.
+const _ONNX_TENSOR_FLOAT = 1
+
+// encodeONNXAttribute returns the AttributeProto of name with value, which is either of string, int64, []string, []int64 or []float32.
+func encodeONNXAttribute(name string, value interface{}) []byte {
+	data := appendProtoBytes(nil, 1, []byte(name))
+	switch value := value.(type) {
+	case string:
+		data = appendProtoBytes(data, 4, []byte(value))
+		data = appendProtoVarint(data, 20, _ONNX_ATTRIBUTE_STRING)
+	case int64:
+		data = appendProtoVarint(data, 3, uint64(value))
+		data = appendProtoVarint(data, 20, _ONNX_ATTRIBUTE_INT)
+	case []string:
+		for _, s := range value {
+			data = appendProtoBytes(data, 9, []byte(s))
+		}
+		data = appendProtoVarint(data, 20, _ONNX_ATTRIBUTE_STRINGS)
+	case []int64:
+		data = appendProtoPackedInt64s(data, 8, value)
+		data = appendProtoVarint(data, 20, _ONNX_ATTRIBUTE_INTS)
+	case []float32:
+		data = appendProtoPackedFloats(data, 7, value)
+		data = appendProtoVarint(data, 20, _ONNX_ATTRIBUTE_FLOATS)
+	default:
+		panic(fmt.Sprintf("unsupported attribute value: %#v", value))
+	}
+	return data
+}
+
+// encodeONNXTensorValueInfo returns the ValueInfoProto of the float tensor name with dims, each of which is either of string (symbolic) or int64.
+func encodeONNXTensorValueInfo(name string, dims ...interface{}) []byte {
+	shape := []byte{}
+	for _, dim := range dims {
+		switch dim := dim.(type) {
+		case string:
+			shape = appendProtoBytes(shape, 1, appendProtoBytes(nil, 2, []byte(dim)))
+		case int64:
+			shape = appendProtoBytes(shape, 1, appendProtoVarint(nil, 1, uint64(dim)))
+		}
+	}
+	tensorType := appendProtoBytes(appendProtoVarint(nil, 1, _ONNX_TENSOR_FLOAT), 2, shape)
+	return appendProtoBytes(appendProtoBytes(nil, 1, []byte(name)), 2, appendProtoBytes(nil, 1, tensorType))
+}
+
+// onnxTreeEnsembleBuilder has the attributes of TreeEnsembleRegressor being built.
+type onnxTreeEnsembleBuilder struct {
+	treeIDs           []int64
+	nodeIDs           []int64
+	featureIDs        []int64
+	trueNodeIDs       []int64
+	falseNodeIDs      []int64
+	missingTracksTrue []int64
+	modes             []string
+	values            []float32
+	targetTreeIDs     []int64
+	targetNodeIDs     []int64
+	targetIDs         []int64
+	targetWeights     []float32
+	nfeatures         int64
+}
+
+// addLeaf adds the node of leaf in tree treeID with weight in pre-order, and returns its node ID.
+func (builder *onnxTreeEnsembleBuilder) addLeaf(leaf *Leaf, treeID int64, weight float32, nextNodeID *int64, path LeafPath) (int64, error) {
+	nodeID := *nextNodeID
+	*nextNodeID++
+	i := len(builder.nodeIDs)
+	builder.treeIDs, builder.nodeIDs = append(builder.treeIDs, treeID), append(builder.nodeIDs, nodeID)
+	builder.trueNodeIDs, builder.falseNodeIDs = append(builder.trueNodeIDs, 0), append(builder.falseNodeIDs, 0)
+	if leaf.IsTerminal() {
+		value, err := treeFloat32Value(leaf, path)
+		if err != nil {
+			return 0, err
+		}
+		builder.featureIDs, builder.modes, builder.values = append(builder.featureIDs, 0), append(builder.modes, "LEAF"), append(builder.values, 0.0)
+		builder.missingTracksTrue = append(builder.missingTracksTrue, 0)
+		builder.targetTreeIDs, builder.targetNodeIDs = append(builder.targetTreeIDs, treeID), append(builder.targetNodeIDs, nodeID)
+		builder.targetIDs, builder.targetWeights = append(builder.targetIDs, 0), append(builder.targetWeights, weight*value)
+		return nodeID, nil
+	}
+	mode := "BRANCH_LEQ"
+	if leaf.operator == Less {
+		mode = "BRANCH_LT"
+	}
+	builder.featureIDs, builder.modes, builder.values = append(builder.featureIDs, int64(leaf.featureID)), append(builder.modes, mode), append(builder.values, leaf.threshold)
+	// Leaf takes the left leaf for NaN, so the missing values go to the true branch.
+	builder.missingTracksTrue = append(builder.missingTracksTrue, 1)
+	if int64(leaf.featureID) >= builder.nfeatures {
+		builder.nfeatures = int64(leaf.featureID) + 1
+	}
+	trueNodeID, err := builder.addLeaf(leaf.left, treeID, weight, nextNodeID, append(path, false))
+	if err != nil {
+		return 0, err
+	}
+	falseNodeID, err := builder.addLeaf(leaf.right, treeID, weight, nextNodeID, append(path, true))
+	if err != nil {
+		return 0, err
+	}
+	builder.trueNodeIDs[i], builder.falseNodeIDs[i] = trueNodeID, falseNodeID
+	return nodeID, nil
+}
+
+// MarshalONNX returns the ONNX model having the TreeEnsembleRegressor node which implements the score of ensemble (see Ensemble.PredictSum).
+// The model has the input "X" of float tensor [N, nfeatures] and the output "variable" of float tensor [N, 1], where nfeatures is the maximum feature ID used in ensemble plus 1.
+// Each split "x <= threshold" or "x < threshold" is encoded as BRANCH_LEQ or BRANCH_LT taking the true branch for missing values as Leaf does, and the weight of each tree is multiplied into the values of its terminal leaves.
+// Thus, UnmarshalONNX imports the model as the same trees with the scaled values and weights 1.
+// The trees of Forest can be exported with Forest.Ensemble.
+//
+// This function returns an error if ensemble is empty, the numbers of Trees and Weights differ, a tree is malformed (see Leaf.Validate), or a value is not float32.
+func MarshalONNX(ensemble *Ensemble) ([]byte, error) {
+	if len(ensemble.Trees) == 0 {
+		return nil, fmt.Errorf("ensemble must not be empty")
+	}
+	if len(ensemble.Trees) != len(ensemble.Weights) {
+		return nil, fmt.Errorf("the number of weights must be equal to the number of trees")
+	}
+	builder := &onnxTreeEnsembleBuilder{nfeatures: 1}
+	for t, tree := range ensemble.Trees {
+		if err := tree.Validate(); err != nil {
+			return nil, fmt.Errorf("tree %d: %s", t, err)
+		}
+		nextNodeID := int64(0)
+		if _, err := builder.addLeaf(tree, int64(t), ensemble.Weights[t], &nextNodeID, LeafPath{}); err != nil {
+			return nil, fmt.Errorf("tree %d: %s", t, err)
+		}
+	}
+	node := appendProtoBytes(nil, 1, []byte("X"))
+	node = appendProtoBytes(node, 2, []byte("variable"))
+	node = appendProtoBytes(node, 3, []byte("TreeEnsembleRegressor"))
+	node = appendProtoBytes(node, 4, []byte("TreeEnsembleRegressor"))
+	for _, attr := range []struct {
+		name  string
+		value interface{}
+	}{
+		{"aggregate_function", "SUM"},
+		{"n_targets", int64(1)},
+		{"nodes_falsenodeids", builder.falseNodeIDs},
+		{"nodes_featureids", builder.featureIDs},
+		{"nodes_missing_value_tracks_true", builder.missingTracksTrue},
+		{"nodes_modes", builder.modes},
+		{"nodes_nodeids", builder.nodeIDs},
+		{"nodes_treeids", builder.treeIDs},
+		{"nodes_truenodeids", builder.trueNodeIDs},
+		{"nodes_values", builder.values},
+		{"post_transform", "NONE"},
+		{"target_ids", builder.targetIDs},
+		{"target_nodeids", builder.targetNodeIDs},
+		{"target_treeids", builder.targetTreeIDs},
+		{"target_weights", builder.targetWeights},
+	} {
+		node = appendProtoBytes(node, 5, encodeONNXAttribute(attr.name, attr.value))
+	}
+	node = appendProtoBytes(node, 7, []byte(_ONNX_ML_DOMAIN))
+	graph := appendProtoBytes(nil, 1, node)
+	graph = appendProtoBytes(graph, 2, []byte("confeito"))
+	graph = appendProtoBytes(graph, 11, encodeONNXTensorValueInfo("X", "N", builder.nfeatures))
+	graph = appendProtoBytes(graph, 12, encodeONNXTensorValueInfo("variable", "N", int64(1)))
+	model := appendProtoVarint(nil, 1, 8)
+	model = appendProtoBytes(model, 2, []byte("confeito"))
+	model = appendProtoBytes(model, 7, graph)
+	model = appendProtoBytes(model, 8, appendProtoVarint(appendProtoBytes(nil, 1, []byte("")), 2, 17))
+	model = appendProtoBytes(model, 8, appendProtoVarint(appendProtoBytes(nil, 1, []byte(_ONNX_ML_DOMAIN)), 2, 3))
+	return model, nil
+}
@@ -0,0 +1,65 @@
+package confeito
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestMarshalONNX(t *testing.T) {
+	tree := goassert.New(t).SucceedNew(ParseLeaf("(feature[2] <= 0.5 ? 1 : (feature[0] < -1 ? 2 : 3))")).(*Leaf)
+	data := goassert.New(t).SucceedNew(MarshalONNX(&Ensemble{Trees: []*Leaf{tree}, Weights: []float32{0.5}})).([]byte)
+	fields := goassert.New(t).SucceedNew(parseProtoMessage(data)).([]protoField)
+	goassert.New(t, 5).Equal(len(fields))
+	goassert.New(t, protoField{number: 1, wireType: _PROTO_WIRE_VARINT, value: 8}).Equal(fields[0])
+	goassert.New(t, "confeito").Equal(string(fields[1].bytes))
+	goassert.New(t, string(appendProtoVarint(appendProtoBytes(nil, 1, []byte(_ONNX_ML_DOMAIN)), 2, 3))).Equal(string(fields[4].bytes))
+	node := goassert.New(t).SucceedNew(findONNXTreeEnsembleNode(data)).(*onnxNode)
+	goassert.New(t, "TreeEnsembleRegressor").Equal(node.opType)
+	goassert.New(t, []int64{0, 1, 2, 3, 4}).Equal(node.ints("nodes_nodeids"))
+	goassert.New(t, []string{"BRANCH_LEQ", "LEAF", "BRANCH_LT", "LEAF", "LEAF"}).Equal(node.strings("nodes_modes"))
+	goassert.New(t, []int64{1, 0, 3, 0, 0}).Equal(node.ints("nodes_truenodeids"))
+	goassert.New(t, []int64{2, 0, 4, 0, 0}).Equal(node.ints("nodes_falsenodeids"))
+	goassert.New(t, []int64{1, 0, 1, 0, 0}).Equal(node.ints("nodes_missing_value_tracks_true"))
+	goassert.New(t, []float32{0.5, 1.0, 1.5}).Equal(node.floats("target_weights"))
+	model := goassert.New(t).SucceedNew(UnmarshalONNX(data, nil)).(*ONNXModel)
+	goassert.New(t, "(feature[2] <= 0.5 ? 0.5 : (feature[0] < -1 ? 1 : 1.5))").Equal(model.Trees[0].String())
+	goassert.New(t, []float32{1.0}).Equal(model.Weights)
+
+	goassert.New(t, "ensemble must not be empty").ExpectError(MarshalONNX(&Ensemble{}))
+	goassert.New(t, "the number of weights must be equal to the number of trees").ExpectError(MarshalONNX(&Ensemble{Trees: []*Leaf{tree}}))
+	goassert.New(t, "tree 0: root.right: value must be float32: \"a\"").ExpectError(MarshalONNX(&Ensemble{Trees: []*Leaf{goassert.New(t).SucceedNew(NewLeaf(0, 0.0, float32(0.0), "a")).(*Leaf)}, Weights: []float32{1.0}}))
+	goassert.New(t, "tree 0: root: leaf must not be nil").ExpectError(MarshalONNX(&Ensemble{Trees: []*Leaf{nil}, Weights: []float32{1.0}}))
+}
+
+// TestMarshalONNXRoundTrip verifies that the exported model is imported as the same trees predicting the same scores.
+func TestMarshalONNXRoundTrip(t *testing.T) {
+	X, y := newRandomForestDataset(500, false)
+	trainer := NewGradientBoostingTrainer(SquaredLoss{})
+	trainer.NumTrees, trainer.MaxLeaves = 20, ForestMaxLeaves
+	trees := goassert.New(t).SucceedNew(trainer.Train(X, y)).([]*Leaf)
+	thresholds := setAlternateOperators(t, trees)
+	forest := goassert.New(t).SucceedNew(NewForestFromTrees(trees...)).(*Forest)
+	goassert.New(t).SucceedWithoutError(forest.SetDecay(0.9))
+	data := goassert.New(t).SucceedNew(MarshalONNX(forest.Ensemble())).([]byte)
+	model := goassert.New(t).SucceedNew(UnmarshalONNX(data, nil)).(*ONNXModel)
+	goassert.New(t, len(trees)).Equal(len(model.Trees))
+	for i, tree := range model.Trees {
+		goassert.New(t, trees[i].NumLeaves()).Equal(tree.NumLeaves())
+		goassert.New(t, float32(1.0)).Equal(model.Weights[i])
+	}
+	imported := goassert.New(t).SucceedNew(model.Forest()).(*Forest)
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		x := DenseFeatureVector(newHarnessInput(rng, thresholds))
+		expected := goassert.New(t).SucceedNew(forest.PredictSum(x)).(float32)
+		goassert.New(t, expected).EqualWithoutError(imported.PredictSum(x))
+	}
+	// Unweighted trees are imported as they are.
+	forest = goassert.New(t).SucceedNew(NewForestFromTrees(trees...)).(*Forest)
+	model = goassert.New(t).SucceedNew(UnmarshalONNX(goassert.New(t).SucceedNew(MarshalONNX(forest.Ensemble())).([]byte), nil)).(*ONNXModel)
+	for i, tree := range model.Trees {
+		goassert.New(t, true).Equal(trees[i].Equal(tree, 0.0))
+	}
+}
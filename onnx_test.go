@@ -0,0 +1,189 @@
+package confeito
+
+import (
+	"math"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+// newONNXAttribute returns the AttributeProto of name with value, where value []byte is the tensor.
+func newONNXAttribute(name string, value interface{}) []byte {
+	if tensor, ok := value.([]byte); ok {
+		return appendProtoBytes(appendProtoBytes(nil, 1, []byte(name)), 5, tensor)
+	}
+	return encodeONNXAttribute(name, value)
+}
+
+// newONNXModel returns the ModelProto having the graph of the nodes.
+func newONNXModel(nodes ...[]byte) []byte {
+	graph := []byte{}
+	for _, node := range nodes {
+		graph = appendProtoBytes(graph, 1, node)
+	}
+	return appendProtoBytes(appendProtoVarint(nil, 1, 8), 7, graph)
+}
+
+// newONNXNode returns the NodeProto of opType in domain ai.onnx.ml with the attributes.
+func newONNXNode(opType string, attributes map[string]interface{}) []byte {
+	data := appendProtoBytes(nil, 4, []byte(opType))
+	data = appendProtoBytes(data, 7, []byte(_ONNX_ML_DOMAIN))
+	for _, name := range []string{
+		"aggregate_function", "base_values", "base_values_as_tensor", "class_ids", "class_nodeids", "class_treeids", "class_weights", "classlabels_int64s", "n_targets",
+		"nodes_falsenodeids", "nodes_featureids", "nodes_missing_value_tracks_true", "nodes_modes", "nodes_nodeids", "nodes_treeids", "nodes_truenodeids", "nodes_values",
+		"post_transform", "target_ids", "target_nodeids", "target_treeids", "target_weights",
+	} {
+		if value, ok := attributes[name]; ok {
+			data = appendProtoBytes(data, 5, newONNXAttribute(name, value))
+		}
+	}
+	return data
+}
+
+// newONNXRegressorAttributes returns the attributes of TreeEnsembleRegressor having two trees.
+// Tree 0 is (x[0] < 0.5 ? 1 : (x[1] >= 1.0 ? 3 : 4)) for target 0, and tree 1 is the constant 10 for target 0 and 100 for target 1.
+func newONNXRegressorAttributes() map[string]interface{} {
+	return map[string]interface{}{
+		"aggregate_function":              "AVERAGE",
+		"base_values":                     []float32{0.5, 0.25},
+		"n_targets":                       int64(2),
+		"nodes_treeids":                   []int64{0, 0, 0, 0, 0, 1},
+		"nodes_nodeids":                   []int64{0, 1, 2, 3, 4, 0},
+		"nodes_featureids":                []int64{0, 0, 1, 0, 0, 0},
+		"nodes_modes":                     []string{"BRANCH_LT", "LEAF", "BRANCH_GTE", "LEAF", "LEAF", "LEAF"},
+		"nodes_values":                    []float32{0.5, 0, 1.0, 0, 0, 0},
+		"nodes_truenodeids":               []int64{1, 0, 3, 0, 0, 0},
+		"nodes_falsenodeids":              []int64{2, 0, 4, 0, 0, 0},
+		"nodes_missing_value_tracks_true": []int64{1, 0, 0, 0, 0, 0},
+		"target_treeids":                  []int64{0, 0, 0, 1, 1},
+		"target_nodeids":                  []int64{1, 3, 4, 0, 0},
+		"target_ids":                      []int64{0, 0, 0, 0, 1},
+		"target_weights":                  []float32{1, 3, 4, 10, 100},
+	}
+}
+
+func TestUnmarshalONNXRegressor(t *testing.T) {
+	attributes := newONNXRegressorAttributes()
+	data := newONNXModel(newONNXNode("Cast", nil), newONNXNode("TreeEnsembleRegressor", attributes))
+	model := goassert.New(t).SucceedNew(UnmarshalONNX(data, nil)).(*ONNXModel)
+	goassert.New(t, "NONE").Equal(model.PostTransform)
+	goassert.New(t, []float32{0.5, 0.5, 1.0}).Equal(model.Weights)
+	goassert.New(t, "(feature[0] < 0.5 ? 1 : (feature[1] < 1 ? 4 : 3))").Equal(model.Trees[0].String())
+	nan := float32(math.NaN())
+	for _, testCase := range []struct {
+		x        DenseFeatureVector
+		expected float32
+	}{
+		{DenseFeatureVector{0.5, 1.0}, 7.0},
+		{DenseFeatureVector{0.4, 5.0}, 6.0},
+		{DenseFeatureVector{0.6, 0.9}, 7.5},
+		{DenseFeatureVector{nan, 5.0}, 6.0},
+		{DenseFeatureVector{0.6, nan}, 7.5},
+	} {
+		goassert.New(t, testCase.expected).EqualWithoutError(model.PredictSum(testCase.x))
+	}
+	forest := goassert.New(t).SucceedNew(model.Forest()).(*Forest)
+	goassert.New(t, float32(7.0)).EqualWithoutError(forest.PredictSum(DenseFeatureVector{0.5, 1.0}))
+	model = goassert.New(t).SucceedNew(UnmarshalONNX(data, &ONNXOptions{Target: 1})).(*ONNXModel)
+	goassert.New(t, float32(50.25)).EqualWithoutError(model.PredictSum(DenseFeatureVector{0.5, 1.0}))
+	goassert.New(t, "TreeEnsembleRegressor: target 2 is out of range [0, 2)").ExpectError(UnmarshalONNX(data, &ONNXOptions{Target: 2}))
+
+	attributes["aggregate_function"] = "SUM"
+	delete(attributes, "base_values")
+	model = goassert.New(t).SucceedNew(UnmarshalONNX(newONNXModel(newONNXNode("TreeEnsembleRegressor", attributes)), nil)).(*ONNXModel)
+	goassert.New(t, []float32{1.0, 1.0}).Equal(model.Weights)
+	goassert.New(t, float32(13.0)).EqualWithoutError(model.PredictSum(DenseFeatureVector{0.5, 1.0}))
+}
+
+func TestUnmarshalONNXClassifier(t *testing.T) {
+	attributes := map[string]interface{}{
+		"classlabels_int64s": []int64{0, 1, 2},
+		"post_transform":     "SOFTMAX",
+		"nodes_treeids":      []int64{0, 0, 0},
+		"nodes_nodeids":      []int64{0, 1, 2},
+		"nodes_featureids":   []int64{3, 0, 0},
+		"nodes_modes":        []string{"BRANCH_GT", "LEAF", "LEAF"},
+		"nodes_values":       []float32{-1.0, 0, 0},
+		"nodes_truenodeids":  []int64{1, 0, 0},
+		"nodes_falsenodeids": []int64{2, 0, 0},
+		"class_treeids":      []int64{0, 0, 0, 0},
+		"class_nodeids":      []int64{1, 1, 2, 2},
+		"class_ids":          []int64{2, 0, 2, 1},
+		"class_weights":      []float32{0.75, 0.25, 0.5, 0.5},
+	}
+	data := newONNXModel(newONNXNode("TreeEnsembleClassifier", attributes), newONNXNode("ZipMap", nil))
+	model := goassert.New(t).SucceedNew(UnmarshalONNX(data, &ONNXOptions{Target: 2})).(*ONNXModel)
+	goassert.New(t, "SOFTMAX").Equal(model.PostTransform)
+	goassert.New(t, "(feature[3] <= -1 ? 0.5 : 0.75)").Equal(model.Trees[0].String())
+	goassert.New(t, float32(0.75)).EqualWithoutError(model.PredictSum(DenseFeatureVector{0, 0, 0, -0.5}))
+	goassert.New(t, float32(0.5)).EqualWithoutError(model.PredictSum(DenseFeatureVector{0, 0, 0, -1.0}))
+	model = goassert.New(t).SucceedNew(UnmarshalONNX(data, &ONNXOptions{Target: 0})).(*ONNXModel)
+	goassert.New(t, "(feature[3] <= -1 ? 0 : 0.25)").Equal(model.Trees[0].String())
+	goassert.New(t, "TreeEnsembleClassifier: target 3 is out of range [0, 3)").ExpectError(UnmarshalONNX(data, &ONNXOptions{Target: 3}))
+	delete(attributes, "classlabels_int64s")
+	goassert.New(t, "TreeEnsembleClassifier: classlabels_int64s or classlabels_strings must be given").ExpectError(UnmarshalONNX(newONNXModel(newONNXNode("TreeEnsembleClassifier", attributes)), nil))
+}
+
+func TestUnmarshalONNXError(t *testing.T) {
+	newData := func(update func(attributes map[string]interface{})) []byte {
+		attributes := newONNXRegressorAttributes()
+		update(attributes)
+		return newONNXModel(newONNXNode("TreeEnsembleRegressor", attributes))
+	}
+	goassert.New(t, "model: offset 0: malformed key").ExpectError(UnmarshalONNX([]byte{0x80}, nil))
+	goassert.New(t, "model must have graph").ExpectError(UnmarshalONNX(appendProtoVarint(nil, 1, 8), nil))
+	goassert.New(t, "graph must have exactly one tree ensemble node").ExpectError(UnmarshalONNX(newONNXModel(newONNXNode("Cast", nil)), nil))
+	regressor := newONNXNode("TreeEnsembleRegressor", newONNXRegressorAttributes())
+	goassert.New(t, "graph must have exactly one tree ensemble node").ExpectError(UnmarshalONNX(newONNXModel(regressor, regressor), nil))
+	goassert.New(t, "TreeEnsembleRegressor: tree 0: node 0: unsupported mode \"BRANCH_EQ\"").ExpectError(UnmarshalONNX(newData(func(attributes map[string]interface{}) {
+		attributes["nodes_modes"] = []string{"BRANCH_EQ", "LEAF", "BRANCH_GTE", "LEAF", "LEAF", "LEAF"}
+	}), nil))
+	// BRANCH_LEQ without the missing value tracks sends NaN to the right.
+	data := newData(func(attributes map[string]interface{}) {
+		attributes["nodes_modes"] = []string{"BRANCH_LEQ", "LEAF", "BRANCH_GTE", "LEAF", "LEAF", "LEAF"}
+		delete(attributes, "nodes_missing_value_tracks_true")
+	})
+	// It is imported as it is by default, and rejected with StrictMissingValueTracks.
+	model := goassert.New(t).SucceedNew(UnmarshalONNX(data, nil)).(*ONNXModel)
+	goassert.New(t, "(feature[0] <= 0.5 ? 1 : (feature[1] < 1 ? 4 : 3))").Equal(model.Trees[0].String())
+	goassert.New(t, "TreeEnsembleRegressor: tree 0: node 0: missing values must go to the left leaf, but BRANCH_LEQ with nodes_missing_value_tracks_true=false does not").ExpectError(UnmarshalONNX(data, &ONNXOptions{StrictMissingValueTracks: true}))
+	goassert.New(t, "TreeEnsembleRegressor: tree 0: node 2: missing values must go to the left leaf, but BRANCH_GTE with nodes_missing_value_tracks_true=true does not").ExpectError(UnmarshalONNX(newData(func(attributes map[string]interface{}) {
+		attributes["nodes_missing_value_tracks_true"] = []int64{1, 0, 1, 0, 0, 0}
+	}), &ONNXOptions{StrictMissingValueTracks: true}))
+	goassert.New(t, "TreeEnsembleRegressor: nodes_missing_value_tracks_true must have length 6").ExpectError(UnmarshalONNX(newData(func(attributes map[string]interface{}) {
+		attributes["nodes_missing_value_tracks_true"] = []int64{1}
+	}), nil))
+	goassert.New(t, "TreeEnsembleRegressor: unsupported aggregate_function \"MAX\"").ExpectError(UnmarshalONNX(newData(func(attributes map[string]interface{}) {
+		attributes["aggregate_function"] = "MAX"
+	}), nil))
+	goassert.New(t, "TreeEnsembleRegressor: base_values_as_tensor is not supported").ExpectError(UnmarshalONNX(newData(func(attributes map[string]interface{}) {
+		attributes["base_values_as_tensor"] = []byte{}
+	}), nil))
+	goassert.New(t, "TreeEnsembleRegressor: base_values must have length 2").ExpectError(UnmarshalONNX(newData(func(attributes map[string]interface{}) {
+		attributes["base_values"] = []float32{1}
+	}), nil))
+	goassert.New(t, "TreeEnsembleRegressor: nodes_treeids must not be empty").ExpectError(UnmarshalONNX(newData(func(attributes map[string]interface{}) {
+		delete(attributes, "nodes_treeids")
+	}), nil))
+	goassert.New(t, "TreeEnsembleRegressor: nodes_values must have length 6").ExpectError(UnmarshalONNX(newData(func(attributes map[string]interface{}) {
+		attributes["nodes_values"] = []float32{0.5}
+	}), nil))
+	goassert.New(t, "TreeEnsembleRegressor: target_treeids, target_nodeids, target_ids and target_weights must have the same length").ExpectError(UnmarshalONNX(newData(func(attributes map[string]interface{}) {
+		attributes["target_weights"] = []float32{1}
+	}), nil))
+	goassert.New(t, "TreeEnsembleRegressor: tree 0: node 1 is duplicated").ExpectError(UnmarshalONNX(newData(func(attributes map[string]interface{}) {
+		attributes["nodes_nodeids"] = []int64{0, 1, 1, 3, 4, 0}
+	}), nil))
+	goassert.New(t, "TreeEnsembleRegressor: tree 0 must have exactly one root").ExpectError(UnmarshalONNX(newData(func(attributes map[string]interface{}) {
+		attributes["nodes_falsenodeids"] = []int64{2, 0, 3, 0, 0, 0}
+	}), nil))
+	goassert.New(t, "TreeEnsembleRegressor: tree 0: node 0 is not reachable from the root").ExpectError(UnmarshalONNX(newData(func(attributes map[string]interface{}) {
+		attributes["nodes_truenodeids"] = []int64{1, 0, 0, 0, 0, 0}
+	}), nil))
+	goassert.New(t, "TreeEnsembleRegressor: tree 0: node 2: child node 5 is not found").ExpectError(UnmarshalONNX(newData(func(attributes map[string]interface{}) {
+		attributes["nodes_falsenodeids"] = []int64{2, 0, 5, 0, 0, 0}
+	}), nil))
+	goassert.New(t, "TreeEnsembleRegressor: tree 0: node 2: illegal feature ID -1").ExpectError(UnmarshalONNX(newData(func(attributes map[string]interface{}) {
+		attributes["nodes_featureids"] = []int64{0, 0, -1, 0, 0, 0}
+	}), nil))
+}
@@ -0,0 +1,337 @@
+package confeito
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+)
+
+// pmmlSimplePredicate is the SimplePredicate element of PMML.
+type pmmlSimplePredicate struct {
+	Field    string `xml:"field,attr"`
+	Operator string `xml:"operator,attr"`
+	Value    string `xml:"value,attr"`
+}
+
+// pmmlPredicate has the predicate elements of PMML, where only one of them is given.
+type pmmlPredicate struct {
+	True               *struct{}            `xml:"True"`
+	False              *struct{}            `xml:"False"`
+	SimplePredicate    *pmmlSimplePredicate `xml:"SimplePredicate"`
+	CompoundPredicate  *struct{}            `xml:"CompoundPredicate"`
+	SimpleSetPredicate *struct{}            `xml:"SimpleSetPredicate"`
+}
+
+// name returns the element name of the predicate.
+func (predicate *pmmlPredicate) name() string {
+	switch {
+	case predicate.True != nil:
+		return "True"
+	case predicate.False != nil:
+		return "False"
+	case predicate.SimplePredicate != nil:
+		return "SimplePredicate"
+	case predicate.CompoundPredicate != nil:
+		return "CompoundPredicate"
+	case predicate.SimpleSetPredicate != nil:
+		return "SimpleSetPredicate"
+	default:
+		return "no predicate"
+	}
+}
+
+// pmmlNode is the Node element of PMML.
+type pmmlNode struct {
+	pmmlPredicate
+	Score string      `xml:"score,attr"`
+	Nodes []*pmmlNode `xml:"Node"`
+}
+
+// pmmlMiningSchema is the MiningSchema element of PMML.
+type pmmlMiningSchema struct {
+	Fields []struct {
+		Name      string `xml:"name,attr"`
+		UsageType string `xml:"usageType,attr"`
+	} `xml:"MiningField"`
+}
+
+// pmmlTargets is the Targets element of PMML.
+type pmmlTargets struct {
+	Targets []struct {
+		RescaleFactor   *float64 `xml:"rescaleFactor,attr"`
+		RescaleConstant *float64 `xml:"rescaleConstant,attr"`
+	} `xml:"Target"`
+}
+
+// pmmlTreeModel is the TreeModel element of PMML.
+type pmmlTreeModel struct {
+	FunctionName string           `xml:"functionName,attr"`
+	MiningSchema pmmlMiningSchema `xml:"MiningSchema"`
+	Targets      *pmmlTargets     `xml:"Targets"`
+	Node         *pmmlNode        `xml:"Node"`
+}
+
+// pmmlSegment is the Segment element of PMML.
+type pmmlSegment struct {
+	pmmlPredicate
+	Weight      *float64         `xml:"weight,attr"`
+	TreeModel   *pmmlTreeModel   `xml:"TreeModel"`
+	MiningModel *pmmlMiningModel `xml:"MiningModel"`
+}
+
+// pmmlMiningModel is the MiningModel element of PMML.
+type pmmlMiningModel struct {
+	FunctionName string           `xml:"functionName,attr"`
+	MiningSchema pmmlMiningSchema `xml:"MiningSchema"`
+	Targets      *pmmlTargets     `xml:"Targets"`
+	Segmentation *struct {
+		MultipleModelMethod string         `xml:"multipleModelMethod,attr"`
+		Segments            []*pmmlSegment `xml:"Segment"`
+	} `xml:"Segmentation"`
+}
+
+// pmmlDocument is the PMML element.
+type pmmlDocument struct {
+	XMLName     xml.Name         `xml:"PMML"`
+	TreeModel   *pmmlTreeModel   `xml:"TreeModel"`
+	MiningModel *pmmlMiningModel `xml:"MiningModel"`
+}
+
+// float32Less returns the split equivalent to "x < v" for every float32 x, which is "x < float32(v)" if v is a float32 value, otherwise "x <= float32Floor(v)".
+func float32Less(v float64) (SplitOperator, float32) {
+	if f := float32(v); float64(f) == v {
+		return Less, f
+	}
+	return LessOrEqual, float32Floor(v)
+}
+
+// pmmlTreeBuilder builds the trees of PMML with the feature IDs.
+type pmmlTreeBuilder struct {
+	featureIDs map[string]FeatureID
+}
+
+// split returns the feature ID, the operator, the threshold and whether the first child is the left one of the split by the predicates of the two children.
+// The first child is taken if its predicate is true, otherwise the second child, whose predicate must be True or the complement, is taken.
+func (builder *pmmlTreeBuilder) split(first, second *pmmlPredicate) (featureID FeatureID, operator SplitOperator, threshold float32, firstIsLeft bool, err error) {
+	if first.SimplePredicate == nil {
+		err = fmt.Errorf("unsupported predicate %s", first.name())
+		return
+	}
+	predicate := first.SimplePredicate
+	featureID, ok := builder.featureIDs[predicate.Field]
+	if !ok {
+		err = fmt.Errorf("unknown field %q", predicate.Field)
+		return
+	}
+	value, e := strconv.ParseFloat(predicate.Value, 64)
+	if e != nil {
+		err = fmt.Errorf("illegal value %q", predicate.Value)
+		return
+	}
+	// The feature values are float32, so the thresholds are rounded for the float32 comparisons.
+	complement := ""
+	switch predicate.Operator {
+	case "lessOrEqual":
+		operator, threshold, firstIsLeft, complement = LessOrEqual, float32Floor(value), true, "greaterThan"
+	case "lessThan":
+		operator, threshold = float32Less(value)
+		firstIsLeft, complement = true, "greaterOrEqual"
+	case "greaterThan":
+		operator, threshold, firstIsLeft, complement = LessOrEqual, float32Floor(value), false, "lessOrEqual"
+	case "greaterOrEqual":
+		operator, threshold = float32Less(value)
+		firstIsLeft, complement = false, "lessThan"
+	default:
+		err = fmt.Errorf("unsupported operator %q", predicate.Operator)
+		return
+	}
+	if second.True == nil {
+		other := second.SimplePredicate
+		if other == nil || other.Field != predicate.Field || other.Operator != complement || other.Value != predicate.Value {
+			err = fmt.Errorf("second predicate must be True or the complement of the first predicate")
+			return
+		}
+	}
+	return
+}
+
+// buildTree returns a new tree of node.
+func (builder *pmmlTreeBuilder) buildTree(node *pmmlNode, path LeafPath) (*Leaf, error) {
+	if len(node.Nodes) == 0 {
+		score, err := strconv.ParseFloat(node.Score, 32)
+		if err != nil {
+			return nil, &LeafError{Path: append(LeafPath{}, path...), Reason: fmt.Sprintf("illegal score %q", node.Score)}
+		}
+		return NewTerminalLeaf(float32(score))
+	}
+	if len(node.Nodes) != 2 {
+		return nil, &LeafError{Path: append(LeafPath{}, path...), Reason: fmt.Sprintf("node must have 0 or 2 children, but has %d", len(node.Nodes))}
+	}
+	featureID, operator, threshold, firstIsLeft, err := builder.split(&node.Nodes[0].pmmlPredicate, &node.Nodes[1].pmmlPredicate)
+	if err != nil {
+		return nil, &LeafError{Path: append(LeafPath{}, path...), Reason: err.Error()}
+	}
+	leftNode, rightNode := node.Nodes[0], node.Nodes[1]
+	if !firstIsLeft {
+		leftNode, rightNode = rightNode, leftNode
+	}
+	left, err := builder.buildTree(leftNode, append(path, false))
+	if err != nil {
+		return nil, err
+	}
+	right, err := builder.buildTree(rightNode, append(path, true))
+	if err != nil {
+		return nil, err
+	}
+	return &Leaf{
+		featureID: featureID,
+		threshold: threshold,
+		operator:  operator,
+		left:      left,
+		right:     right,
+	}, nil
+}
+
+// buildTreeModel returns a new tree of model.
+func (builder *pmmlTreeBuilder) buildTreeModel(model *pmmlTreeModel) (*Leaf, error) {
+	if model.FunctionName != "regression" {
+		return nil, fmt.Errorf("unsupported functionName %q", model.FunctionName)
+	}
+	if model.Node == nil {
+		return nil, fmt.Errorf("TreeModel must have Node")
+	}
+	if model.Node.True == nil {
+		return nil, fmt.Errorf("predicate of root node must be True")
+	}
+	return builder.buildTree(model.Node, LeafPath{})
+}
+
+// PMMLOptions is the options of importing PMML models by UnmarshalPMML.
+type PMMLOptions struct {
+	// FeatureIDs is the feature IDs of the fields.
+	// If it is nil, then the active fields in MiningSchema of the model have the feature IDs in order.
+	FeatureIDs map[string]FeatureID
+}
+
+// PMMLModel is a tree ensemble imported from a PMML model by UnmarshalPMML.
+type PMMLModel struct {
+	Ensemble
+	// FeatureIDs is the feature IDs of the fields used in the model.
+	FeatureIDs map[string]FeatureID
+}
+
+// UnmarshalPMML returns a new PMMLModel of the regression TreeModel or MiningModel in the PMML document data.
+//
+// The splits must be binary, where the first child has SimplePredicate with operator lessOrEqual, lessThan, greaterThan or greaterOrEqual, and the second child has True or the complement predicate.
+// The splits are converted into "x <= threshold" or "x < threshold" with the thresholds rounded to float32 values, so they are exact for the float32 feature values.
+// MiningModel must have the Segmentation of TreeModel segments with predicate True and multipleModelMethod sum, average or weightedAverage, which give weight 1, 1/nsegments or the normalized segment weights to the trees respectively.
+// rescaleFactor and rescaleConstant of Target in the top-level model are also applied, where the constant is added as a constant tree with weight 1.
+// The missing value strategies of PMML are not supported, so Leaf takes the left leaf for missing (NaN) values.
+//
+// This function returns an error if data is malformed, or the model has an unsupported element or attribute.
+func UnmarshalPMML(data []byte, options *PMMLOptions) (*PMMLModel, error) {
+	if options == nil {
+		options = &PMMLOptions{}
+	}
+	var doc pmmlDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	var schema *pmmlMiningSchema
+	var targets *pmmlTargets
+	switch {
+	case doc.TreeModel != nil && doc.MiningModel == nil:
+		schema, targets = &doc.TreeModel.MiningSchema, doc.TreeModel.Targets
+	case doc.MiningModel != nil && doc.TreeModel == nil:
+		schema, targets = &doc.MiningModel.MiningSchema, doc.MiningModel.Targets
+	default:
+		return nil, fmt.Errorf("PMML must have exactly one TreeModel or MiningModel")
+	}
+	model := &PMMLModel{FeatureIDs: options.FeatureIDs}
+	if model.FeatureIDs == nil {
+		model.FeatureIDs = make(map[string]FeatureID)
+		for _, field := range schema.Fields {
+			if field.UsageType == "" || field.UsageType == "active" {
+				model.FeatureIDs[field.Name] = FeatureID(len(model.FeatureIDs))
+			}
+		}
+	}
+	builder := &pmmlTreeBuilder{featureIDs: model.FeatureIDs}
+	if doc.TreeModel != nil {
+		model.Trees, model.Weights = make([]*Leaf, 1), []float32{1.0}
+		tree, err := builder.buildTreeModel(doc.TreeModel)
+		if err != nil {
+			return nil, fmt.Errorf("TreeModel: %s", err)
+		}
+		model.Trees[0] = tree
+	} else {
+		miningModel := doc.MiningModel
+		if miningModel.FunctionName != "regression" {
+			return nil, fmt.Errorf("MiningModel: unsupported functionName %q", miningModel.FunctionName)
+		}
+		if miningModel.Segmentation == nil || len(miningModel.Segmentation.Segments) == 0 {
+			return nil, fmt.Errorf("MiningModel must have Segmentation with Segment")
+		}
+		segments := miningModel.Segmentation.Segments
+		weights := make([]float64, len(segments))
+		for s, segment := range segments {
+			if segment.True == nil {
+				return nil, fmt.Errorf("segment %d: unsupported predicate %s", s, segment.name())
+			}
+			if segment.TreeModel == nil {
+				return nil, fmt.Errorf("segment %d: model must be TreeModel", s)
+			}
+			if segment.TreeModel.Targets != nil {
+				return nil, fmt.Errorf("segment %d: Targets is not supported", s)
+			}
+			tree, err := builder.buildTreeModel(segment.TreeModel)
+			if err != nil {
+				return nil, fmt.Errorf("segment %d: %s", s, err)
+			}
+			model.Trees = append(model.Trees, tree)
+			weights[s] = 1.0
+			if segment.Weight != nil {
+				weights[s] = *segment.Weight
+			}
+		}
+		sumWeights := 0.0
+		for _, weight := range weights {
+			sumWeights += weight
+		}
+		if miningModel.Segmentation.MultipleModelMethod == "weightedAverage" && !(sumWeights > 0.0) {
+			return nil, fmt.Errorf("MiningModel: sum of segment weights must be positive for weightedAverage: %g", sumWeights)
+		}
+		for _, weight := range weights {
+			switch miningModel.Segmentation.MultipleModelMethod {
+			case "sum":
+				weight = 1.0
+			case "average":
+				weight = 1.0 / float64(len(weights))
+			case "weightedAverage":
+				weight /= sumWeights
+			default:
+				return nil, fmt.Errorf("MiningModel: unsupported multipleModelMethod %q", miningModel.Segmentation.MultipleModelMethod)
+			}
+			model.Weights = append(model.Weights, float32(weight))
+		}
+	}
+	if targets != nil {
+		if len(targets.Targets) != 1 {
+			return nil, fmt.Errorf("Targets must have exactly one Target")
+		}
+		target := targets.Targets[0]
+		if target.RescaleFactor != nil {
+			for t := range model.Weights {
+				model.Weights[t] = float32(float64(model.Weights[t]) * *target.RescaleFactor)
+			}
+		}
+		if target.RescaleConstant != nil {
+			constant, err := NewTerminalLeaf(float32(*target.RescaleConstant))
+			if err != nil {
+				return nil, err
+			}
+			model.Trees, model.Weights = append(model.Trees, constant), append(model.Weights, 1.0)
+		}
+	}
+	return model, nil
+}
@@ -0,0 +1,108 @@
+package confeito
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+const pmmlTestMiningSchema = `<MiningSchema><MiningField name="y" usageType="target"/><MiningField name="x1"/><MiningField name="x2" usageType="active"/></MiningSchema>`
+
+// pmmlTree is a TreeModel using all supported operators.
+const pmmlTree = `<TreeModel functionName="regression">` + pmmlTestMiningSchema + `
+<Node><True/>
+	<Node><SimplePredicate field="x1" operator="lessOrEqual" value="0.5"/>
+		<Node score="1"><SimplePredicate field="x2" operator="lessThan" value="1"/></Node>
+		<Node score="2"><True/></Node>
+	</Node>
+	<Node><SimplePredicate field="x1" operator="greaterThan" value="0.5"/>
+		<Node score="3"><SimplePredicate field="x2" operator="greaterOrEqual" value="0.1"/></Node>
+		<Node score="4"><SimplePredicate field="x2" operator="lessThan" value="0.1"/></Node>
+	</Node>
+</Node>
+</TreeModel>`
+
+// pmmlStump is a TreeModel having a single split.
+const pmmlStump = `<TreeModel functionName="regression">` + pmmlTestMiningSchema + `
+<Node><True/>
+	<Node score="-1"><SimplePredicate field="x2" operator="lessOrEqual" value="0"/></Node>
+	<Node score="1"><True/></Node>
+</Node>
+</TreeModel>`
+
+// newPMML returns the PMML document having model.
+func newPMML(model string) []byte {
+	return []byte(`<?xml version="1.0"?><PMML xmlns="http://www.dmg.org/PMML-4_4" version="4.4"><DataDictionary/>` + model + `</PMML>`)
+}
+
+// newPMMLMiningModel returns the MiningModel having the TreeModel segments with method.
+func newPMMLMiningModel(method string, weights []string, trees ...string) string {
+	segments := ""
+	for i, tree := range trees {
+		segments += `<Segment weight="` + weights[i] + `"><True/>` + tree + `</Segment>`
+	}
+	return `<MiningModel functionName="regression">` + pmmlTestMiningSchema + `<Segmentation multipleModelMethod="` + method + `">` + segments + `</Segmentation></MiningModel>`
+}
+
+func TestFloat32Less(t *testing.T) {
+	operator, threshold := float32Less(1.0)
+	goassert.New(t, Less, float32(1.0)).Equal(operator, threshold)
+	operator, threshold = float32Less(0.1)
+	goassert.New(t, LessOrEqual, math.Nextafter32(float32(0.1), 0.0)).Equal(operator, threshold)
+	operator, threshold = float32Less(-0.1)
+	goassert.New(t, LessOrEqual, float32(-0.1)).Equal(operator, threshold)
+}
+
+func TestUnmarshalPMML(t *testing.T) {
+	x1, x2, x3, x4 := DenseFeatureVector{0.5, 0.0}, DenseFeatureVector{0.0, 1.0}, DenseFeatureVector{1.0, float32(0.1)}, DenseFeatureVector{1.0, 0.0}
+	model := goassert.New(t).SucceedNew(UnmarshalPMML(newPMML(pmmlTree), nil)).(*PMMLModel)
+	goassert.New(t, map[string]FeatureID{"x1": 0, "x2": 1}).Equal(model.FeatureIDs)
+	goassert.New(t, []float32{1.0}).Equal(model.Weights)
+	goassert.New(t, "(feature[0] <= 0.5 ? (feature[1] < 1 ? 1 : 2) : (feature[1] <= 0.099999994 ? 4 : 3))").Equal(model.Trees[0].String())
+	goassert.New(t, float32(1.0)).EqualWithoutError(model.PredictSum(x1))
+	goassert.New(t, float32(2.0)).EqualWithoutError(model.PredictSum(x2))
+	// float32(0.1) > 0.1, so it satisfies greaterOrEqual.
+	goassert.New(t, float32(3.0)).EqualWithoutError(model.PredictSum(x3))
+	goassert.New(t, float32(4.0)).EqualWithoutError(model.PredictSum(x4))
+
+	model = goassert.New(t).SucceedNew(UnmarshalPMML(newPMML(pmmlTree), &PMMLOptions{FeatureIDs: map[string]FeatureID{"x1": 1, "x2": 0}})).(*PMMLModel)
+	goassert.New(t, float32(2.0)).EqualWithoutError(model.PredictSum(DenseFeatureVector{1.0, 0.0}))
+
+	model = goassert.New(t).SucceedNew(UnmarshalPMML(newPMML(newPMMLMiningModel("sum", []string{"1", "1"}, pmmlTree, pmmlStump)), nil)).(*PMMLModel)
+	goassert.New(t, []float32{1.0, 1.0}).Equal(model.Weights)
+	goassert.New(t, float32(0.0)).EqualWithoutError(model.PredictSum(x1))
+	goassert.New(t, float32(3.0)).EqualWithoutError(model.PredictSum(x2))
+	forest := goassert.New(t).SucceedNew(model.Forest()).(*Forest)
+	for _, x := range []FeatureVector{x1, x2, x3, x4} {
+		expected := goassert.New(t).SucceedNew(model.PredictSum(x)).(float32)
+		goassert.New(t, expected).EqualWithoutError(forest.PredictSum(x))
+	}
+	model = goassert.New(t).SucceedNew(UnmarshalPMML(newPMML(newPMMLMiningModel("average", []string{"1", "3"}, pmmlTree, pmmlStump)), nil)).(*PMMLModel)
+	goassert.New(t, []float32{0.5, 0.5}).Equal(model.Weights)
+	model = goassert.New(t).SucceedNew(UnmarshalPMML(newPMML(newPMMLMiningModel("weightedAverage", []string{"1", "3"}, pmmlTree, pmmlStump)), nil)).(*PMMLModel)
+	goassert.New(t, []float32{0.25, 0.75}).Equal(model.Weights)
+	goassert.New(t, float32(1.25)).EqualWithoutError(model.PredictSum(x2))
+
+	targets := `<Targets><Target field="y" rescaleFactor="2" rescaleConstant="10"/></Targets>`
+	model = goassert.New(t).SucceedNew(UnmarshalPMML(newPMML(strings.Replace(newPMMLMiningModel("sum", []string{"1"}, pmmlStump), "</MiningSchema>", "</MiningSchema>"+targets, 1)), nil)).(*PMMLModel)
+	goassert.New(t, []float32{2.0, 1.0}).Equal(model.Weights)
+	goassert.New(t, float32(12.0)).EqualWithoutError(model.PredictSum(x2))
+
+	goassert.New(t, "PMML must have exactly one TreeModel or MiningModel").ExpectError(UnmarshalPMML(newPMML(""), nil))
+	goassert.New(t, `MiningModel: unsupported multipleModelMethod "max"`).ExpectError(UnmarshalPMML(newPMML(newPMMLMiningModel("max", []string{"1"}, pmmlStump)), nil))
+	goassert.New(t, "MiningModel: sum of segment weights must be positive for weightedAverage: 0").ExpectError(UnmarshalPMML(newPMML(newPMMLMiningModel("weightedAverage", []string{"1", "-1"}, pmmlTree, pmmlStump)), nil))
+	goassert.New(t, "MiningModel: sum of segment weights must be positive for weightedAverage: NaN").ExpectError(UnmarshalPMML(newPMML(newPMMLMiningModel("weightedAverage", []string{"NaN"}, pmmlStump)), nil))
+	goassert.New(t, "MiningModel must have Segmentation with Segment").ExpectError(UnmarshalPMML(newPMML(newPMMLMiningModel("sum", nil)), nil))
+	goassert.New(t, "segment 0: unsupported predicate False").ExpectError(UnmarshalPMML(newPMML(strings.Replace(newPMMLMiningModel("sum", []string{"1"}, pmmlStump), "<Segment weight=\"1\"><True/>", "<Segment><False/>", 1)), nil))
+	goassert.New(t, `TreeModel: unsupported functionName "classification"`).ExpectError(UnmarshalPMML(newPMML(strings.Replace(pmmlStump, "regression", "classification", 1)), nil))
+	goassert.New(t, "TreeModel: predicate of root node must be True").ExpectError(UnmarshalPMML(newPMML(strings.Replace(pmmlStump, "<Node><True/>", "<Node><False/>", 1)), nil))
+	goassert.New(t, `TreeModel: root: unsupported operator "equal"`).ExpectError(UnmarshalPMML(newPMML(strings.Replace(pmmlStump, "lessOrEqual", "equal", 1)), nil))
+	goassert.New(t, `TreeModel: root: unsupported predicate CompoundPredicate`).ExpectError(UnmarshalPMML(newPMML(strings.Replace(pmmlStump, `<SimplePredicate field="x2" operator="lessOrEqual" value="0"/>`, `<CompoundPredicate booleanOperator="or"/>`, 1)), nil))
+	goassert.New(t, `TreeModel: root: unknown field "x3"`).ExpectError(UnmarshalPMML(newPMML(strings.Replace(pmmlStump, `field="x2"`, `field="x3"`, 1)), nil))
+	goassert.New(t, `TreeModel: root: illegal value "a"`).ExpectError(UnmarshalPMML(newPMML(strings.Replace(pmmlStump, `value="0"`, `value="a"`, 1)), nil))
+	goassert.New(t, "TreeModel: root: second predicate must be True or the complement of the first predicate").ExpectError(UnmarshalPMML(newPMML(strings.Replace(pmmlStump, `<Node score="1"><True/>`, `<Node score="1"><SimplePredicate field="x2" operator="greaterThan" value="1"/>`, 1)), nil))
+	goassert.New(t, `TreeModel: root.left: illegal score ""`).ExpectError(UnmarshalPMML(newPMML(strings.Replace(pmmlStump, `score="-1"`, "", 1)), nil))
+	goassert.New(t, "TreeModel: root: node must have 0 or 2 children, but has 1").ExpectError(UnmarshalPMML(newPMML(strings.Replace(pmmlStump, `<Node score="1"><True/></Node>`, "", 1)), nil))
+}
@@ -0,0 +1,156 @@
+package confeito
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// The wire types of protocol buffers.
+const (
+	_PROTO_WIRE_VARINT  = 0
+	_PROTO_WIRE_FIXED64 = 1
+	_PROTO_WIRE_BYTES   = 2
+	_PROTO_WIRE_FIXED32 = 5
+)
+
+// protoField is a field of a protocol buffers message.
+// value has the value of wire type varint, fixed64 or fixed32, and bytes has the value of wire type bytes.
+type protoField struct {
+	number   int
+	wireType int
+	value    uint64
+	bytes    []byte
+}
+
+// parseProtoMessage returns the fields of the protocol buffers message encoded in data in order.
+// This is the minimal decoder of the wire format, so the fields are not interpreted.
+//
+// This function returns an error if data is malformed, or has a group.
+func parseProtoMessage(data []byte) ([]protoField, error) {
+	fields := []protoField{}
+	for pos := 0; pos < len(data); {
+		key, n := binary.Uvarint(data[pos:])
+		if n <= 0 {
+			return nil, fmt.Errorf("offset %d: malformed key", pos)
+		}
+		pos += n
+		field := protoField{number: int(key >> 3), wireType: int(key & 7)}
+		if field.number <= 0 {
+			return nil, fmt.Errorf("offset %d: illegal field number %d", pos, field.number)
+		}
+		switch field.wireType {
+		case _PROTO_WIRE_VARINT:
+			if field.value, n = binary.Uvarint(data[pos:]); n <= 0 {
+				return nil, fmt.Errorf("offset %d: malformed varint", pos)
+			}
+			pos += n
+		case _PROTO_WIRE_FIXED64:
+			if len(data)-pos < 8 {
+				return nil, fmt.Errorf("offset %d: truncated fixed64", pos)
+			}
+			field.value = binary.LittleEndian.Uint64(data[pos:])
+			pos += 8
+		case _PROTO_WIRE_BYTES:
+			length, n := binary.Uvarint(data[pos:])
+			if n <= 0 {
+				return nil, fmt.Errorf("offset %d: malformed length", pos)
+			}
+			pos += n
+			if uint64(len(data)-pos) < length {
+				return nil, fmt.Errorf("offset %d: truncated bytes", pos)
+			}
+			field.bytes = data[pos : pos+int(length)]
+			pos += int(length)
+		case _PROTO_WIRE_FIXED32:
+			if len(data)-pos < 4 {
+				return nil, fmt.Errorf("offset %d: truncated fixed32", pos)
+			}
+			field.value = uint64(binary.LittleEndian.Uint32(data[pos:]))
+			pos += 4
+		default:
+			return nil, fmt.Errorf("offset %d: unsupported wire type %d", pos, field.wireType)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// appendProtoKey appends the key of field number with wireType to b.
+// The append functions are the minimal encoder of the wire format.
+func appendProtoKey(b []byte, number, wireType int) []byte {
+	return binary.AppendUvarint(b, uint64(number)<<3|uint64(wireType))
+}
+
+// appendProtoVarint appends the varint field number with value to b.
+func appendProtoVarint(b []byte, number int, value uint64) []byte {
+	return binary.AppendUvarint(appendProtoKey(b, number, _PROTO_WIRE_VARINT), value)
+}
+
+// appendProtoFixed32 appends the fixed32 field number with value to b.
+func appendProtoFixed32(b []byte, number int, value uint32) []byte {
+	return binary.LittleEndian.AppendUint32(appendProtoKey(b, number, _PROTO_WIRE_FIXED32), value)
+}
+
+// appendProtoBytes appends the bytes field number with data to b.
+func appendProtoBytes(b []byte, number int, data []byte) []byte {
+	return append(binary.AppendUvarint(appendProtoKey(b, number, _PROTO_WIRE_BYTES), uint64(len(data))), data...)
+}
+
+// appendProtoPackedFloats appends the packed repeated float field number with values to b.
+func appendProtoPackedFloats(b []byte, number int, values []float32) []byte {
+	packed := make([]byte, 0, 4*len(values))
+	for _, value := range values {
+		packed = binary.LittleEndian.AppendUint32(packed, math.Float32bits(value))
+	}
+	return appendProtoBytes(b, number, packed)
+}
+
+// appendProtoPackedInt64s appends the packed repeated int64 field number with values to b.
+func appendProtoPackedInt64s(b []byte, number int, values []int64) []byte {
+	packed := []byte{}
+	for _, value := range values {
+		packed = binary.AppendUvarint(packed, uint64(value))
+	}
+	return appendProtoBytes(b, number, packed)
+}
+
+// float32s returns the float values of the repeated float field, which is either packed or not.
+func (field *protoField) float32s() ([]float32, error) {
+	switch field.wireType {
+	case _PROTO_WIRE_FIXED32:
+		return []float32{math.Float32frombits(uint32(field.value))}, nil
+	case _PROTO_WIRE_BYTES:
+		if len(field.bytes)%4 != 0 {
+			return nil, fmt.Errorf("field %d: malformed packed floats", field.number)
+		}
+		values := make([]float32, len(field.bytes)/4)
+		for i := range values {
+			values[i] = math.Float32frombits(binary.LittleEndian.Uint32(field.bytes[4*i:]))
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("field %d: illegal wire type %d for float", field.number, field.wireType)
+	}
+}
+
+// int64s returns the int64 values of the repeated int64 field, which is either packed or not.
+func (field *protoField) int64s() ([]int64, error) {
+	switch field.wireType {
+	case _PROTO_WIRE_VARINT:
+		return []int64{int64(field.value)}, nil
+	case _PROTO_WIRE_BYTES:
+		values := []int64{}
+		for pos := 0; pos < len(field.bytes); {
+			value, n := binary.Uvarint(field.bytes[pos:])
+			if n <= 0 {
+				return nil, fmt.Errorf("field %d: malformed packed varints", field.number)
+			}
+			values = append(values, int64(value))
+			pos += n
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("field %d: illegal wire type %d for int64", field.number, field.wireType)
+	}
+}
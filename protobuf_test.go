@@ -0,0 +1,42 @@
+package confeito
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestParseProtoMessage(t *testing.T) {
+	data := appendProtoVarint(nil, 1, 300)
+	data = binary.LittleEndian.AppendUint64(appendProtoKey(data, 2, _PROTO_WIRE_FIXED64), 0x0102030405060708)
+	data = appendProtoBytes(data, 3, []byte("abc"))
+	data = appendProtoFixed32(data, 4, math.Float32bits(1.5))
+	fields := goassert.New(t).SucceedNew(parseProtoMessage(data)).([]protoField)
+	goassert.New(t, []protoField{
+		{number: 1, wireType: _PROTO_WIRE_VARINT, value: 300},
+		{number: 2, wireType: _PROTO_WIRE_FIXED64, value: 0x0102030405060708},
+		{number: 3, wireType: _PROTO_WIRE_BYTES, bytes: []byte("abc")},
+		{number: 4, wireType: _PROTO_WIRE_FIXED32, value: uint64(math.Float32bits(1.5))},
+	}).Equal(fields)
+	goassert.New(t, []float32{1.5}).EqualWithoutError(fields[3].float32s())
+	goassert.New(t, []int64{300}).EqualWithoutError(fields[0].int64s())
+	goassert.New(t, "field 3: malformed packed floats").ExpectError(fields[2].float32s())
+	goassert.New(t, "field 2: illegal wire type 1 for int64").ExpectError(fields[1].int64s())
+	goassert.New(t, "field 1: illegal wire type 0 for float").ExpectError(fields[0].float32s())
+
+	packed := binary.LittleEndian.AppendUint32(binary.LittleEndian.AppendUint32(nil, math.Float32bits(-1.0)), math.Float32bits(2.0))
+	goassert.New(t, []float32{-1.0, 2.0}).EqualWithoutError((&protoField{number: 1, wireType: _PROTO_WIRE_BYTES, bytes: packed}).float32s())
+	packed = binary.AppendUvarint(binary.AppendUvarint(nil, 1), uint64(0xffffffffffffffff))
+	goassert.New(t, []int64{1, -1}).EqualWithoutError((&protoField{number: 1, wireType: _PROTO_WIRE_BYTES, bytes: packed}).int64s())
+	goassert.New(t, "field 1: malformed packed varints").ExpectError((&protoField{number: 1, wireType: _PROTO_WIRE_BYTES, bytes: []byte{0x80}}).int64s())
+
+	goassert.New(t, "offset 0: malformed key").ExpectError(parseProtoMessage([]byte{0x80}))
+	goassert.New(t, "offset 1: illegal field number 0").ExpectError(parseProtoMessage([]byte{0x00}))
+	goassert.New(t, "offset 1: malformed varint").ExpectError(parseProtoMessage([]byte{0x08, 0x80}))
+	goassert.New(t, "offset 1: truncated fixed64").ExpectError(parseProtoMessage([]byte{0x09, 0x00}))
+	goassert.New(t, "offset 2: truncated bytes").ExpectError(parseProtoMessage([]byte{0x0a, 0x02, 0x00}))
+	goassert.New(t, "offset 1: truncated fixed32").ExpectError(parseProtoMessage([]byte{0x0d, 0x00}))
+	goassert.New(t, "offset 1: unsupported wire type 3").ExpectError(parseProtoMessage([]byte{0x0b}))
+}
@@ -0,0 +1,215 @@
+package confeito
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"sync"
+)
+
+// RandomForestTrainer is a trainer of random forests and extremely randomized trees (extra-trees).
+//
+// The trained trees should be averaged for regression, or voted for classification.
+// For regression, set the decay rate of Forest to 1.0 and divide Forest.PredictSum by the number of trees.
+type RandomForestTrainer struct {
+	// Criterion is the split criterion.
+	Criterion Criterion
+	// NumTrees is the number of trees.
+	NumTrees int
+	// MaxFeatures is the number of features randomly chosen at each split.
+	// If it is 0, then all features are searched.
+	MaxFeatures int
+	// Bootstrap is true if each tree is trained on a bootstrap sample, otherwise on the whole dataset.
+	Bootstrap bool
+	// ExtraTrees is true if a random threshold is drawn for each feature instead of searching the best one.
+	ExtraTrees bool
+	// MaxDepth is the maximum depth of each tree.
+	// If it is 0, then the depth is unlimited.
+	MaxDepth int
+	// MinSamplesLeaf is the minimum number of samples in each terminal leaf.
+	MinSamplesLeaf int
+	// MaxLeaves is the maximum number of terminal leaves in each tree.
+	// If it is 0, then the number is unlimited.
+	MaxLeaves int
+	// MaxBins is the maximum number of bins of each feature for histogram-based split finding.
+	// If it is 0, then the exact split finding is used.
+	MaxBins int
+	// NumWorkers is the number of goroutines training trees in parallel.
+	// If it is 0, then runtime.NumCPU() goroutines are used.
+	NumWorkers int
+	// Seed is the seed of the random numbers.
+	// The trained trees depend only on Seed, not on NumWorkers.
+	Seed int64
+}
+
+// NewRandomForestTrainer returns a new RandomForestTrainer for random forests with criterion.
+// The default parameters are 100 trees on bootstrap samples, and all features searched at each split.
+func NewRandomForestTrainer(criterion Criterion) *RandomForestTrainer {
+	return &RandomForestTrainer{
+		Criterion:      criterion,
+		NumTrees:       100,
+		MaxFeatures:    0,
+		Bootstrap:      true,
+		ExtraTrees:     false,
+		MaxDepth:       0,
+		MinSamplesLeaf: 1,
+		MaxLeaves:      0,
+		MaxBins:        0,
+		NumWorkers:     0,
+		Seed:           0,
+	}
+}
+
+// NewExtraTreesTrainer returns a new RandomForestTrainer for extremely randomized trees with criterion.
+// The default parameters are 100 trees on the whole dataset, and all features tried at each split.
+func NewExtraTreesTrainer(criterion Criterion) *RandomForestTrainer {
+	trainer := NewRandomForestTrainer(criterion)
+	trainer.Bootstrap = false
+	trainer.ExtraTrees = true
+	return trainer
+}
+
+// validate returns an error if trainer has an illegal parameter.
+func (trainer *RandomForestTrainer) validate() error {
+	if trainer.NumTrees < 0 {
+		return fmt.Errorf("NumTrees must not be negative")
+	}
+	if trainer.MaxFeatures < 0 {
+		return fmt.Errorf("MaxFeatures must not be negative")
+	}
+	if trainer.NumWorkers < 0 {
+		return fmt.Errorf("NumWorkers must not be negative")
+	}
+	return trainer.cartTrainer().validate()
+}
+
+// cartTrainer returns the CARTTrainer with the same parameters of each tree.
+func (trainer *RandomForestTrainer) cartTrainer() *CARTTrainer {
+	return &CARTTrainer{
+		Criterion:      trainer.Criterion,
+		MaxDepth:       trainer.MaxDepth,
+		MinSamplesLeaf: trainer.MinSamplesLeaf,
+		MaxLeaves:      trainer.MaxLeaves,
+		MaxBins:        trainer.MaxBins,
+	}
+}
+
+// Train returns the trees trained on dataset X with targets y, and the out-of-bag error.
+// The out-of-bag error is the mean squared error for CriterionMSE, otherwise the misclassification rate of the majority vote.
+// It is estimated on the samples which are not in the bootstrap sample of some trees, so it is NaN if Bootstrap is false.
+//
+// This function returns an error if trainer has an illegal parameter, the lengths of X and y are different, y has an illegal label, or at getting feature values of X.
+func (trainer *RandomForestTrainer) Train(X []FeatureVector, y []float32) ([]*Leaf, float64, error) {
+	if err := trainer.validate(); err != nil {
+		return nil, math.NaN(), err
+	}
+	if len(X) != len(y) {
+		return nil, math.NaN(), fmt.Errorf("the number of samples and targets must be equal")
+	}
+	set, err := newTrainingSet(X)
+	if err != nil {
+		return nil, math.NaN(), err
+	}
+	criterion, err := trainer.cartTrainer().newCriterion(y)
+	if err != nil {
+		return nil, math.NaN(), err
+	}
+	bins, err := newHistogramBins(set, trainer.MaxBins)
+	if err != nil {
+		return nil, math.NaN(), err
+	}
+	nworkers := trainer.NumWorkers
+	if nworkers == 0 {
+		nworkers = runtime.NumCPU()
+	}
+	trees, inBags := make([]*Leaf, trainer.NumTrees), make([][]bool, trainer.NumTrees)
+	errs := make([]error, trainer.NumTrees)
+	treeIDs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < nworkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range treeIDs {
+				trees[t], inBags[t], errs[t] = trainer.trainTree(set, criterion, bins, t)
+			}
+		}()
+	}
+	for t := 0; t < trainer.NumTrees; t++ {
+		treeIDs <- t
+	}
+	close(treeIDs)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, math.NaN(), err
+		}
+	}
+	return trees, trainer.outOfBagError(set, y, trees, inBags), nil
+}
+
+// trainTree returns the t-th tree and whether each sample is in its bootstrap sample.
+func (trainer *RandomForestTrainer) trainTree(set *trainingSet, criterion splitCriterion, bins *featureBins, t int) (*Leaf, []bool, error) {
+	rng := rand.New(rand.NewSource(trainer.Seed + int64(t)))
+	samples, inBag := make([]int, set.nsamples), make([]bool, set.nsamples)
+	for i := range samples {
+		if trainer.Bootstrap {
+			samples[i] = rng.Intn(set.nsamples)
+		} else {
+			samples[i] = i
+		}
+		inBag[samples[i]] = true
+	}
+	grower := &treeGrower{
+		set:              set,
+		criterion:        criterion,
+		maxDepth:         trainer.MaxDepth,
+		minSamplesLeaf:   trainer.MinSamplesLeaf,
+		maxLeaves:        trainer.MaxLeaves,
+		maxFeatures:      trainer.MaxFeatures,
+		randomThresholds: trainer.ExtraTrees,
+		rng:              rng,
+		bins:             bins,
+	}
+	tree, err := grower.grow(samples)
+	return tree, inBag, err
+}
+
+// outOfBagError returns the out-of-bag error of trees.
+func (trainer *RandomForestTrainer) outOfBagError(set *trainingSet, y []float32, trees []*Leaf, inBags [][]bool) float64 {
+	sum, n := 0.0, 0
+	for i := 0; i < set.nsamples; i++ {
+		mean, nvotes, votes := 0.0, 0, make(map[float32]int)
+		for t, tree := range trees {
+			if inBags[t][i] {
+				continue
+			}
+			value := set.predict(tree, i).(float32)
+			mean += float64(value)
+			votes[value]++
+			nvotes++
+		}
+		if nvotes == 0 {
+			continue
+		}
+		if trainer.Criterion == CriterionMSE {
+			sum += math.Pow(mean/float64(nvotes)-float64(y[i]), 2.0)
+		} else {
+			label := float32(math.Inf(1))
+			for value, count := range votes {
+				if count > votes[label] || (count == votes[label] && value < label) {
+					label = value
+				}
+			}
+			if label != y[i] {
+				sum++
+			}
+		}
+		n++
+	}
+	if n == 0 {
+		return math.NaN()
+	}
+	return sum / float64(n)
+}
@@ -0,0 +1,107 @@
+package confeito
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func newRandomForestDataset(n int, classification bool) ([]FeatureVector, []float32) {
+	rng := rand.New(rand.NewSource(0))
+	X, y := make([]FeatureVector, n), make([]float32, n)
+	for i := range X {
+		x := DenseFeatureVector{rng.Float32(), rng.Float32(), rng.Float32(), rng.Float32()}
+		X[i], y[i] = x, x[0]+x[1]
+		if classification {
+			y[i] = float32(math.Floor(float64(y[i])))
+		}
+	}
+	return X, y
+}
+
+func TestRandomForestTrainerRegression(t *testing.T) {
+	X, y := newRandomForestDataset(300, false)
+	trainer := NewRandomForestTrainer(CriterionMSE)
+	trainer.NumTrees, trainer.MaxFeatures, trainer.MaxLeaves = 20, 2, ForestMaxLeaves
+	trees, oobError, err := trainer.Train(X, y)
+	goassert.New(t).SucceedWithoutError(err)
+	goassert.New(t, 20).Equal(len(trees))
+	if !(oobError < 0.02) {
+		t.Errorf("too large out-of-bag error: %g", oobError)
+	}
+	trainer.NumWorkers = 1
+	trees1, oobError1, err := trainer.Train(X, y)
+	goassert.New(t).SucceedWithoutError(err)
+	goassert.New(t, oobError).Equal(oobError1)
+	for i := range trees {
+		goassert.New(t, trees[i].String()).Equal(trees1[i].String())
+	}
+	forest := goassert.New(t).SucceedNew(NewForestFromTrees(trees...)).(*Forest)
+	goassert.New(t).SucceedWithoutError(forest.PredictSum(X[0]))
+}
+
+func TestRandomForestTrainerClassification(t *testing.T) {
+	X, y := newRandomForestDataset(300, true)
+	for _, trainer := range []*RandomForestTrainer{NewRandomForestTrainer(CriterionGini), NewExtraTreesTrainer(CriterionEntropy)} {
+		trainer.NumTrees = 20
+		trees, oobError, err := trainer.Train(X, y)
+		goassert.New(t).SucceedWithoutError(err)
+		goassert.New(t, 20).Equal(len(trees))
+		if trainer.Bootstrap {
+			if !(oobError < 0.1) {
+				t.Errorf("too large out-of-bag error: %g", oobError)
+			}
+		} else {
+			goassert.New(t, true).Equal(math.IsNaN(oobError))
+		}
+		nerrors := 0
+		for i, x := range X {
+			votes := make(map[interface{}]int)
+			for _, tree := range trees {
+				value, _ := tree.Predict(x)
+				votes[value]++
+			}
+			if votes[y[i]] <= len(trees)/2 {
+				nerrors++
+			}
+		}
+		if nerrors > 15 {
+			t.Errorf("too many training errors: %d", nerrors)
+		}
+	}
+}
+
+func TestExtraTreesTrainer(t *testing.T) {
+	X, y := newRandomForestDataset(100, false)
+	trainer := NewExtraTreesTrainer(CriterionMSE)
+	trainer.NumTrees, trainer.MaxDepth = 2, 1
+	trees, _, err := trainer.Train(X, y)
+	goassert.New(t).SucceedWithoutError(err)
+	goassert.New(t, false).Equal(trees[0].String() == trees[1].String())
+	for _, tree := range trees {
+		featureID, _, _ := tree.Threshold()
+		goassert.New(t, true).Equal(featureID < 4)
+	}
+}
+
+func TestRandomForestTrainerError(t *testing.T) {
+	X, y := []FeatureVector{DenseFeatureVector{0.0}}, []float32{0.0}
+	trainer := NewRandomForestTrainer(CriterionMSE)
+	trainer.NumTrees = -1
+	_, _, err := trainer.Train(X, y)
+	goassert.New(t, "NumTrees must not be negative").ExpectError(err)
+	trainer = NewRandomForestTrainer(CriterionMSE)
+	trainer.MaxFeatures = -1
+	_, _, err = trainer.Train(X, y)
+	goassert.New(t, "MaxFeatures must not be negative").ExpectError(err)
+	trainer = NewRandomForestTrainer(CriterionMSE)
+	trainer.NumWorkers = -1
+	_, _, err = trainer.Train(X, y)
+	goassert.New(t, "NumWorkers must not be negative").ExpectError(err)
+	_, _, err = NewRandomForestTrainer(CriterionGini).Train(X, []float32{0.5})
+	goassert.New(t, "class label must be a non-negative integer: y[0]=0.5").ExpectError(err)
+	_, _, err = NewRandomForestTrainer(CriterionMSE).Train(X, []float32{})
+	goassert.New(t, "the number of samples and targets must be equal").ExpectError(err)
+}
@@ -0,0 +1,184 @@
+package confeito
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// RenderOptions is the options of rendering trees by WriteDOT and WriteASCII.
+// The zero value renders only the splits and the values.
+type RenderOptions struct {
+	// FeatureNames is the names of features used instead of "feature[i]".
+	// The features missing in FeatureNames are rendered as "feature[i]".
+	FeatureNames map[FeatureID]string
+	// Dataset is annotated as the cover, which is the number of the data points reaching each leaf, if it is not nil.
+	Dataset []FeatureVector
+	// Path is highlighted as the path taken for it, if it is not nil.
+	Path FeatureVector
+}
+
+// featureName returns the name of featureID.
+func (options *RenderOptions) featureName(featureID FeatureID) string {
+	if name, ok := options.FeatureNames[featureID]; ok {
+		return name
+	}
+	return fmt.Sprintf("feature[%d]", featureID)
+}
+
+// predictionPath returns the leaves on the path taken for x from l.
+func predictionPath(l *Leaf, x FeatureVector) ([]*Leaf, error) {
+	path := []*Leaf{l}
+	for !l.IsTerminal() {
+		value, err := x.Get(l.featureID)
+		if err != nil {
+			return nil, err
+		}
+		if l.goesRight(value) {
+			l = l.right
+		} else {
+			l = l.left
+		}
+		path = append(path, l)
+	}
+	return path, nil
+}
+
+// leafRenderer has the annotations of the leaves to be rendered.
+type leafRenderer struct {
+	options *RenderOptions
+	covers  map[*Leaf]int
+	onPath  map[*Leaf]bool
+}
+
+// newLeafRenderer returns a new leafRenderer of the tree whose root is l with options.
+//
+// This function returns an error if the tree is malformed, or at getting feature values of the dataset or the path.
+func newLeafRenderer(l *Leaf, options *RenderOptions) (*leafRenderer, error) {
+	if err := l.Validate(); err != nil {
+		return nil, err
+	}
+	if options == nil {
+		options = &RenderOptions{}
+	}
+	r := &leafRenderer{
+		options: options,
+		onPath:  make(map[*Leaf]bool),
+	}
+	if options.Dataset != nil {
+		r.covers = make(map[*Leaf]int)
+		for _, x := range options.Dataset {
+			path, err := predictionPath(l, x)
+			if err != nil {
+				return nil, err
+			}
+			for _, leaf := range path {
+				r.covers[leaf]++
+			}
+		}
+	}
+	if options.Path != nil {
+		path, err := predictionPath(l, options.Path)
+		if err != nil {
+			return nil, err
+		}
+		for _, leaf := range path {
+			r.onPath[leaf] = true
+		}
+	}
+	return r, nil
+}
+
+// label returns the label of leaf without annotations.
+func (r *leafRenderer) label(leaf *Leaf) string {
+	if leaf.IsTerminal() {
+		return fmt.Sprintf("%g", leaf.value)
+	}
+	return fmt.Sprintf("%s %s %g", r.options.featureName(leaf.featureID), leaf.operator, leaf.threshold)
+}
+
+// WriteASCII writes the indented ASCII tree of the tree whose root is l to w.
+// The left leaf is shown as "yes" branch and the right leaf is shown as "no" branch.
+// If options.Dataset is given, then the cover of each leaf is shown as "[cover=n]".
+// If options.Path is given, then the leaves on the path are marked with "*".
+//
+// This function returns an error if the tree is malformed (see Leaf.Validate), at getting feature values, or at writing to w.
+func WriteASCII(w io.Writer, l *Leaf, options *RenderOptions) error {
+	r, err := newLeafRenderer(l, options)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	r.writeASCII(bw, l, "", "")
+	return bw.Flush()
+}
+
+func (r *leafRenderer) writeASCII(w *bufio.Writer, leaf *Leaf, head, indent string) {
+	w.WriteString(head)
+	w.WriteString(r.label(leaf))
+	if r.covers != nil {
+		fmt.Fprintf(w, " [cover=%d]", r.covers[leaf])
+	}
+	if r.onPath[leaf] {
+		w.WriteString(" *")
+	}
+	w.WriteString("\n")
+	if leaf.IsTerminal() {
+		return
+	}
+	r.writeASCII(w, leaf.left, indent+"|-- yes: ", indent+"|   ")
+	r.writeASCII(w, leaf.right, indent+"`-- no: ", indent+"    ")
+}
+
+// WriteDOT writes the Graphviz DOT representation of the tree whose root is l to w.
+// The left edge is labeled as "yes" and the right edge is labeled as "no".
+// If options.Dataset is given, then the cover of each leaf is shown in its label.
+// If options.Path is given, then the leaves and the edges on the path are highlighted.
+//
+// This function returns an error if the tree is malformed (see Leaf.Validate), at getting feature values, or at writing to w.
+func WriteDOT(w io.Writer, l *Leaf, options *RenderOptions) error {
+	r, err := newLeafRenderer(l, options)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	bw.WriteString("digraph tree {\n")
+	bw.WriteString("\tnode [shape=box];\n")
+	r.writeDOT(bw, l, new(int))
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+// writeDOT writes the node of leaf and the subtree, and returns the node ID of leaf.
+func (r *leafRenderer) writeDOT(w *bufio.Writer, leaf *Leaf, nextID *int) int {
+	id := *nextID
+	*nextID++
+	label := r.label(leaf)
+	if r.covers != nil {
+		label += fmt.Sprintf("\ncover=%d", r.covers[leaf])
+	}
+	attrs := fmt.Sprintf("label=%s", strconv.Quote(label))
+	if leaf.IsTerminal() {
+		attrs += ", shape=ellipse"
+	}
+	if r.onPath[leaf] {
+		attrs += ", style=filled, fillcolor=lightblue"
+	}
+	fmt.Fprintf(w, "\tn%d [%s];\n", id, attrs)
+	if leaf.IsTerminal() {
+		return id
+	}
+	for _, child := range []struct {
+		leaf  *Leaf
+		label string
+	}{{leaf.left, "yes"}, {leaf.right, "no"}} {
+		childID := r.writeDOT(w, child.leaf, nextID)
+		attrs := fmt.Sprintf("label=%q", child.label)
+		if r.onPath[leaf] && r.onPath[child.leaf] {
+			attrs += ", color=blue, penwidth=2"
+		}
+		fmt.Fprintf(w, "\tn%d -> n%d [%s];\n", id, childID, attrs)
+	}
+	return id
+}
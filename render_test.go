@@ -0,0 +1,101 @@
+package confeito
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+func TestWriteASCII(t *testing.T) {
+	tree := newTestTree(t)
+	var buf bytes.Buffer
+	goassert.New(t).SucceedWithoutError(WriteASCII(&buf, tree, nil))
+	goassert.New(t, `feature[1] <= -0.5
+|-- yes: feature[2] <= 0.5
+|   |-- yes: 1
+|   `+"`"+`-- no: 2
+`+"`"+`-- no: feature[3] <= 1.5
+    |-- yes: 3
+    `+"`"+`-- no: feature[4] <= 2.5
+        |-- yes: 4
+        `+"`"+`-- no: 5
+`).Equal(buf.String())
+	buf.Reset()
+	goassert.New(t).SucceedWithoutError(WriteASCII(&buf, tree, &RenderOptions{
+		FeatureNames: map[FeatureID]string{1: "age", 3: "income"},
+		Dataset: []FeatureVector{
+			DenseFeatureVector{0.0, -1.0, 0.0},
+			DenseFeatureVector{0.0, 0.0, 0.0, 1.0},
+			DenseFeatureVector{0.0, 0.0, 0.0, 2.0, 3.0},
+		},
+		Path: DenseFeatureVector{0.0, 0.0, 0.0, 2.0, 3.0},
+	}))
+	goassert.New(t, `age <= -0.5 [cover=3] *
+|-- yes: feature[2] <= 0.5 [cover=1]
+|   |-- yes: 1 [cover=1]
+|   `+"`"+`-- no: 2 [cover=0]
+`+"`"+`-- no: income <= 1.5 [cover=2] *
+    |-- yes: 3 [cover=1]
+    `+"`"+`-- no: feature[4] <= 2.5 [cover=1] *
+        |-- yes: 4 [cover=0]
+        `+"`"+`-- no: 5 [cover=1] *
+`).Equal(buf.String())
+}
+
+func TestWriteDOT(t *testing.T) {
+	tree := goassert.New(t).SucceedNew(ParseLeaf("(feature[0] <= 0.5 ? 1 : (feature[1] <= 1.5 ? 2 : 3))")).(*Leaf)
+	var buf bytes.Buffer
+	goassert.New(t).SucceedWithoutError(WriteDOT(&buf, tree, &RenderOptions{
+		FeatureNames: map[FeatureID]string{0: "x\"0"},
+		Dataset:      []FeatureVector{DenseFeatureVector{0.0}, DenseFeatureVector{1.0, 2.0}},
+		Path:         DenseFeatureVector{1.0, 2.0},
+	}))
+	goassert.New(t, `digraph tree {
+	node [shape=box];
+	n0 [label="x\"0 <= 0.5\ncover=2", style=filled, fillcolor=lightblue];
+	n1 [label="1\ncover=1", shape=ellipse];
+	n0 -> n1 [label="yes"];
+	n2 [label="feature[1] <= 1.5\ncover=1", style=filled, fillcolor=lightblue];
+	n3 [label="2\ncover=0", shape=ellipse];
+	n2 -> n3 [label="yes"];
+	n4 [label="3\ncover=1", shape=ellipse, style=filled, fillcolor=lightblue];
+	n2 -> n4 [label="no", color=blue, penwidth=2];
+	n0 -> n2 [label="no", color=blue, penwidth=2];
+}
+`).Equal(buf.String())
+}
+
+func TestRenderError(t *testing.T) {
+	cyclic := newTestTree(t)
+	cyclic.Left().SetLeft(cyclic)
+	var buf bytes.Buffer
+	goassert.New(t, "root.left.left: cycle to root").ExpectError(WriteASCII(&buf, cyclic, nil))
+	goassert.New(t, "root.left.left: cycle to root").ExpectError(WriteDOT(&buf, cyclic, nil))
+	goassert.New(t, "broken feature vector").ExpectError(WriteDOT(&buf, newTestTree(t), &RenderOptions{Path: brokenFeatureVector{}}))
+	goassert.New(t, "broken feature vector").ExpectError(WriteASCII(&buf, newTestTree(t), &RenderOptions{Dataset: []FeatureVector{brokenFeatureVector{}}}))
+}
+
+// brokenFeatureVector returns an error for any feature.
+type brokenFeatureVector struct{}
+
+func (brokenFeatureVector) Dim() int {
+	return 0
+}
+
+func (brokenFeatureVector) Get(id FeatureID) (float32, error) {
+	return 0.0, fmt.Errorf("broken feature vector")
+}
+
+func TestForestTree(t *testing.T) {
+	tree := newTestTree(t)
+	forest := goassert.New(t).SucceedNew(NewForestFromTrees(tree)).(*Forest)
+	tree.SetLeft(goassert.New(t).SucceedNew(NewTerminalLeaf(float32(0.0))).(*Leaf))
+	tree0 := goassert.New(t).SucceedNew(forest.Tree(0)).(*Leaf)
+	goassert.New(t, newTestTree(t).String()).Equal(tree0.String())
+	var buf bytes.Buffer
+	goassert.New(t).SucceedWithoutError(WriteASCII(&buf, tree0, nil))
+	goassert.New(t, "tree index 1 is out of range [0, 1)").ExpectError(forest.Tree(1))
+	goassert.New(t, "tree index -1 is out of range [0, 1)").ExpectError(forest.Tree(-1))
+}
@@ -0,0 +1,239 @@
+package confeito
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// sklearnTreeJSON is the JSON schema of the tree_ arrays of a scikit-learn tree.
+type sklearnTreeJSON struct {
+	ChildrenLeft  []int         `json:"children_left"`
+	ChildrenRight []int         `json:"children_right"`
+	Feature       []int         `json:"feature"`
+	Threshold     []float64     `json:"threshold"`
+	Value         [][][]float64 `json:"value"`
+}
+
+// sklearnJSON is the JSON schema of a scikit-learn model.
+type sklearnJSON struct {
+	Model        string          `json:"model"`
+	LearningRate float64         `json:"learning_rate"`
+	Init         []float64       `json:"init"`
+	Estimators   json.RawMessage `json:"estimators"`
+}
+
+// float32Floor returns the largest float32 value not greater than v.
+// scikit-learn compares float32 feature values with float64 thresholds, so x <= v is equivalent to x <= float32Floor(v) for every float32 x.
+func float32Floor(v float64) float32 {
+	f := float32(v)
+	if float64(f) > v {
+		f = math.Nextafter32(f, float32(math.Inf(-1)))
+	}
+	return f
+}
+
+// sklearnValueFunc is the type of the function returning the value of a terminal leaf from the value array of the node.
+type sklearnValueFunc func(value [][]float64) (float32, error)
+
+// sklearnRegressorValue returns the value of the node of a regression tree.
+func sklearnRegressorValue(value [][]float64) (float32, error) {
+	if len(value) != 1 || len(value[0]) != 1 {
+		return 0.0, fmt.Errorf("value of regressor must have shape [1][1]")
+	}
+	return float32(value[0][0]), nil
+}
+
+// sklearnClassifierValue returns the function returning the probability of class at the node of a classification tree.
+// The value array has either the weighted class counts or the class fractions, so it is normalized.
+func sklearnClassifierValue(class int) sklearnValueFunc {
+	return func(value [][]float64) (float32, error) {
+		if len(value) != 1 {
+			return 0.0, fmt.Errorf("multi-output model is not supported")
+		}
+		if !(0 <= class && class < len(value[0])) {
+			return 0.0, fmt.Errorf("class %d is out of range [0, %d)", class, len(value[0]))
+		}
+		sum := 0.0
+		for _, v := range value[0] {
+			sum += v
+		}
+		if !(sum > 0.0) {
+			return 0.0, fmt.Errorf("sum of value must be positive")
+		}
+		return float32(value[0][class] / sum), nil
+	}
+}
+
+// newLeafFromSklearnTree returns a new tree of the scikit-learn tree, whose terminal leaves have the values returned by valueFunc.
+//
+// This function returns an error if the tree is malformed, or valueFunc returns an error.
+func newLeafFromSklearnTree(tree *sklearnTreeJSON, valueFunc sklearnValueFunc) (*Leaf, error) {
+	n := len(tree.ChildrenLeft)
+	if n == 0 {
+		return nil, fmt.Errorf("tree must not be empty")
+	}
+	if len(tree.ChildrenRight) != n || len(tree.Feature) != n || len(tree.Threshold) != n || len(tree.Value) != n {
+		return nil, fmt.Errorf("children_left, children_right, feature, threshold and value must have the same length")
+	}
+	visited := make([]bool, n)
+	var build func(node int) (*Leaf, error)
+	build = func(node int) (*Leaf, error) {
+		if !(0 <= node && node < n) {
+			return nil, fmt.Errorf("node %d is out of range [0, %d)", node, n)
+		}
+		if visited[node] {
+			return nil, fmt.Errorf("node %d is visited twice", node)
+		}
+		visited[node] = true
+		left, right := tree.ChildrenLeft[node], tree.ChildrenRight[node]
+		// scikit-learn uses TREE_LEAF = -1 as the children of terminal leaves.
+		if left == -1 || right == -1 {
+			if left != right {
+				return nil, fmt.Errorf("node %d: terminal leaf must have both children -1", node)
+			}
+			value, err := valueFunc(tree.Value[node])
+			if err != nil {
+				return nil, fmt.Errorf("node %d: %s", node, err)
+			}
+			return NewTerminalLeaf(value)
+		}
+		featureID := FeatureID(tree.Feature[node])
+		if tree.Feature[node] < 0 || featureID == _FEATURE_ID_ILLEGAL {
+			return nil, fmt.Errorf("node %d: illegal feature %d", node, tree.Feature[node])
+		}
+		leftLeaf, err := build(left)
+		if err != nil {
+			return nil, err
+		}
+		rightLeaf, err := build(right)
+		if err != nil {
+			return nil, err
+		}
+		return &Leaf{
+			featureID: featureID,
+			threshold: float32Floor(tree.Threshold[node]),
+			left:      leftLeaf,
+			right:     rightLeaf,
+		}, nil
+	}
+	return build(0)
+}
+
+// UnmarshalSklearnJSON returns a new Ensemble represented by JSON data exported from a scikit-learn model.
+// The schema is {"model": model, "learning_rate": learningRate, "init": [init, ...], "estimators": estimators}, where
+//
+//   - model is the class name, which is one of DecisionTree{Regressor,Classifier}, ExtraTree{Regressor,Classifier}, RandomForest{Regressor,Classifier}, ExtraTrees{Regressor,Classifier} and GradientBoosting{Regressor,Classifier},
+//   - learningRate and init are only for GradientBoosting, where init[k] is the raw prediction of init_ for the k-th column of estimators_ (model._raw_predict_init(X[:1])[0]),
+//   - estimators is the tree for a single tree model, the list of trees for estimators_ of RandomForest and ExtraTrees, or the list of the lists of trees for estimators_ of GradientBoosting,
+//   - each tree is {"children_left": [...], "children_right": [...], "feature": [...], "threshold": [...], "value": [...]} of the tree_ attribute.
+//
+// The model is converted into a weighted sum of trees as follows:
+//
+//   - Regressors predict the value of the regression.
+//   - DecisionTree and ExtraTree classifiers predict the probability of class.
+//   - RandomForest and ExtraTrees average their trees, so they predict the mean value or the mean probability of class.
+//   - GradientBoosting has the constant tree of init and the trees scaled by the learning rate, so it predicts the raw score (the decision function) of class.
+//     The binary classifier has the trees only for class 1, so the score of class 0 is the negated one.
+//
+// class is ignored for regressors.
+// The splits "x <= threshold" are exact, because the float64 thresholds are rounded down to float32 as scikit-learn compares float32 feature values.
+// However, the values and the summation are in float32, so the predictions may differ by the rounding errors.
+//
+// This function returns an error if data is malformed, model is not supported, or class is out of range.
+func UnmarshalSklearnJSON(data []byte, class int) (*Ensemble, error) {
+	var sj sklearnJSON
+	if err := json.Unmarshal(data, &sj); err != nil {
+		return nil, err
+	}
+	if sj.Estimators == nil {
+		return nil, fmt.Errorf("estimators must be given")
+	}
+	model := &Ensemble{}
+	addTree := func(name string, tree *sklearnTreeJSON, valueFunc sklearnValueFunc, weight float32) error {
+		if tree == nil {
+			return fmt.Errorf("%s: tree must not be null", name)
+		}
+		leaf, err := newLeafFromSklearnTree(tree, valueFunc)
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+		model.Trees, model.Weights = append(model.Trees, leaf), append(model.Weights, weight)
+		return nil
+	}
+	switch sj.Model {
+	case "DecisionTreeRegressor", "ExtraTreeRegressor", "DecisionTreeClassifier", "ExtraTreeClassifier":
+		var tree sklearnTreeJSON
+		if err := json.Unmarshal(sj.Estimators, &tree); err != nil {
+			return nil, err
+		}
+		valueFunc := sklearnRegressorValue
+		if sj.Model == "DecisionTreeClassifier" || sj.Model == "ExtraTreeClassifier" {
+			valueFunc = sklearnClassifierValue(class)
+		}
+		if err := addTree("estimator", &tree, valueFunc, 1.0); err != nil {
+			return nil, err
+		}
+	case "RandomForestRegressor", "ExtraTreesRegressor", "RandomForestClassifier", "ExtraTreesClassifier":
+		var trees []*sklearnTreeJSON
+		if err := json.Unmarshal(sj.Estimators, &trees); err != nil {
+			return nil, err
+		}
+		if len(trees) == 0 {
+			return nil, fmt.Errorf("estimators must not be empty")
+		}
+		valueFunc := sklearnRegressorValue
+		if sj.Model == "RandomForestClassifier" || sj.Model == "ExtraTreesClassifier" {
+			valueFunc = sklearnClassifierValue(class)
+		}
+		for e, tree := range trees {
+			if err := addTree(fmt.Sprintf("estimator %d", e), tree, valueFunc, float32(1.0/float64(len(trees)))); err != nil {
+				return nil, err
+			}
+		}
+	case "GradientBoostingRegressor", "GradientBoostingClassifier":
+		var stages [][]*sklearnTreeJSON
+		if err := json.Unmarshal(sj.Estimators, &stages); err != nil {
+			return nil, err
+		}
+		ncolumns := len(sj.Init)
+		if ncolumns == 0 {
+			return nil, fmt.Errorf("init must not be empty")
+		}
+		column, sign := 0, 1.0
+		if sj.Model == "GradientBoostingClassifier" {
+			if ncolumns == 1 {
+				// The binary classifier has the trees of the log odds of class 1.
+				if !(0 <= class && class < 2) {
+					return nil, fmt.Errorf("class %d is out of range [0, 2)", class)
+				}
+				if class == 0 {
+					sign = -1.0
+				}
+			} else {
+				if !(0 <= class && class < ncolumns) {
+					return nil, fmt.Errorf("class %d is out of range [0, %d)", class, ncolumns)
+				}
+				column = class
+			}
+		} else if ncolumns != 1 {
+			return nil, fmt.Errorf("init of regressor must have length 1")
+		}
+		initLeaf, err := NewTerminalLeaf(float32(sign * sj.Init[column]))
+		if err != nil {
+			return nil, err
+		}
+		model.Trees, model.Weights = append(model.Trees, initLeaf), append(model.Weights, 1.0)
+		for s, stage := range stages {
+			if len(stage) != ncolumns {
+				return nil, fmt.Errorf("stage %d must have %d trees", s, ncolumns)
+			}
+			if err := addTree(fmt.Sprintf("estimator %d", s), stage[column], sklearnRegressorValue, float32(sign*sj.LearningRate)); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported model: %q", sj.Model)
+	}
+	return model, nil
+}
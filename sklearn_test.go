@@ -0,0 +1,82 @@
+package confeito
+
+import (
+	"math"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+const (
+	sklearnRegressorTree  = `{"children_left":[1,-1,3,-1,-1],"children_right":[2,-1,4,-1,-1],"feature":[0,-2,1,-2,-2],"threshold":[0.5,-2,0.1,-2,-2],"value":[[[0]],[[1]],[[0]],[[2]],[[3]]]}`
+	sklearnRegressorStump = `{"children_left":[1,-1,-1],"children_right":[2,-1,-1],"feature":[1,-2,-2],"threshold":[0,-2,-2],"value":[[[0]],[[-1]],[[1]]]}`
+	sklearnClassifierTree = `{"children_left":[1,-1,-1],"children_right":[2,-1,-1],"feature":[0,-2,-2],"threshold":[0,-2,-2],"value":[[[4,4]],[[3,1]],[[1,3]]]}`
+)
+
+func TestFloat32Floor(t *testing.T) {
+	goassert.New(t, float32(0.5)).Equal(float32Floor(0.5))
+	goassert.New(t, math.Nextafter32(float32(0.1), 0.0)).Equal(float32Floor(0.1))
+	goassert.New(t, float32(-0.1)).Equal(float32Floor(-0.1))
+}
+
+func TestUnmarshalSklearnJSON(t *testing.T) {
+	// float32(0.1) > 0.1, so it goes to the right as scikit-learn does.
+	x1, x2, x3 := DenseFeatureVector{0.0, -1.0}, DenseFeatureVector{1.0, float32(0.1)}, DenseFeatureVector{1.0, -1.0}
+	model := goassert.New(t).SucceedNew(UnmarshalSklearnJSON([]byte(`{"model":"DecisionTreeRegressor","estimators":`+sklearnRegressorTree+`}`), 0)).(*Ensemble)
+	goassert.New(t, []float32{1.0}).Equal(model.Weights)
+	goassert.New(t, "(feature[0] <= 0.5 ? 1 : (feature[1] <= 0.099999994 ? 2 : 3))").Equal(model.Trees[0].String())
+	goassert.New(t, float32(1.0)).EqualWithoutError(model.PredictSum(x1))
+	goassert.New(t, float32(3.0)).EqualWithoutError(model.PredictSum(x2))
+	goassert.New(t, float32(2.0)).EqualWithoutError(model.PredictSum(x3))
+
+	model = goassert.New(t).SucceedNew(UnmarshalSklearnJSON([]byte(`{"model":"DecisionTreeClassifier","estimators":`+sklearnClassifierTree+`}`), 1)).(*Ensemble)
+	goassert.New(t, float32(0.25)).EqualWithoutError(model.PredictSum(x1))
+	goassert.New(t, float32(0.75)).EqualWithoutError(model.PredictSum(x2))
+
+	model = goassert.New(t).SucceedNew(UnmarshalSklearnJSON([]byte(`{"model":"RandomForestRegressor","estimators":[`+sklearnRegressorTree+`,`+sklearnRegressorStump+`]}`), 0)).(*Ensemble)
+	goassert.New(t, []float32{0.5, 0.5}).Equal(model.Weights)
+	goassert.New(t, float32(0.0)).EqualWithoutError(model.PredictSum(x1))
+	goassert.New(t, float32(2.0)).EqualWithoutError(model.PredictSum(x2))
+
+	model = goassert.New(t).SucceedNew(UnmarshalSklearnJSON([]byte(`{"model":"ExtraTreesClassifier","estimators":[`+sklearnClassifierTree+`,`+sklearnClassifierTree+`]}`), 0)).(*Ensemble)
+	goassert.New(t, float32(0.75)).EqualWithoutError(model.PredictSum(x1))
+
+	model = goassert.New(t).SucceedNew(UnmarshalSklearnJSON([]byte(`{"model":"GradientBoostingRegressor","learning_rate":0.5,"init":[10],"estimators":[[`+sklearnRegressorTree+`],[`+sklearnRegressorStump+`]]}`), 0)).(*Ensemble)
+	goassert.New(t, []float32{1.0, 0.5, 0.5}).Equal(model.Weights)
+	goassert.New(t, float32(10.0)).EqualWithoutError(model.PredictSum(x1))
+	goassert.New(t, float32(12.0)).EqualWithoutError(model.PredictSum(x2))
+	forest := goassert.New(t).SucceedNew(model.Forest()).(*Forest)
+	for _, x := range []FeatureVector{x1, x2, x3} {
+		expected := goassert.New(t).SucceedNew(model.PredictSum(x)).(float32)
+		goassert.New(t, expected).EqualWithoutError(forest.PredictSum(x))
+	}
+
+	binary := []byte(`{"model":"GradientBoostingClassifier","learning_rate":0.5,"init":[1],"estimators":[[` + sklearnRegressorTree + `]]}`)
+	model = goassert.New(t).SucceedNew(UnmarshalSklearnJSON(binary, 1)).(*Ensemble)
+	goassert.New(t, float32(2.5)).EqualWithoutError(model.PredictSum(x2))
+	model = goassert.New(t).SucceedNew(UnmarshalSklearnJSON(binary, 0)).(*Ensemble)
+	goassert.New(t, []float32{1.0, -0.5}).Equal(model.Weights)
+	goassert.New(t, float32(-2.5)).EqualWithoutError(model.PredictSum(x2))
+	goassert.New(t, "class 2 is out of range [0, 2)").ExpectError(UnmarshalSklearnJSON(binary, 2))
+
+	multiclass := []byte(`{"model":"GradientBoostingClassifier","learning_rate":1,"init":[1,2,3],"estimators":[[` + sklearnRegressorTree + `,` + sklearnRegressorStump + `,` + sklearnRegressorTree + `]]}`)
+	model = goassert.New(t).SucceedNew(UnmarshalSklearnJSON(multiclass, 1)).(*Ensemble)
+	goassert.New(t, float32(1.0)).EqualWithoutError(model.PredictSum(x1))
+	goassert.New(t, float32(3.0)).EqualWithoutError(model.PredictSum(x2))
+	goassert.New(t, "class 3 is out of range [0, 3)").ExpectError(UnmarshalSklearnJSON(multiclass, 3))
+}
+
+func TestUnmarshalSklearnJSONError(t *testing.T) {
+	goassert.New(t, "unsupported model: \"SVC\"").ExpectError(UnmarshalSklearnJSON([]byte(`{"model":"SVC","estimators":[]}`), 0))
+	goassert.New(t, "estimators must be given").ExpectError(UnmarshalSklearnJSON([]byte(`{"model":"DecisionTreeRegressor"}`), 0))
+	goassert.New(t, "estimators must not be empty").ExpectError(UnmarshalSklearnJSON([]byte(`{"model":"RandomForestRegressor","estimators":[]}`), 0))
+	goassert.New(t, "estimator 1: tree must not be null").ExpectError(UnmarshalSklearnJSON([]byte(`{"model":"RandomForestRegressor","estimators":[`+sklearnRegressorStump+`,null]}`), 0))
+	goassert.New(t, "estimator: node 1: class 2 is out of range [0, 2)").ExpectError(UnmarshalSklearnJSON([]byte(`{"model":"DecisionTreeClassifier","estimators":`+sklearnClassifierTree+`}`), 2))
+	goassert.New(t, "estimator: node 1: value of regressor must have shape [1][1]").ExpectError(UnmarshalSklearnJSON([]byte(`{"model":"DecisionTreeRegressor","estimators":`+sklearnClassifierTree+`}`), 0))
+	goassert.New(t, "estimator: children_left, children_right, feature, threshold and value must have the same length").ExpectError(UnmarshalSklearnJSON([]byte(`{"model":"DecisionTreeRegressor","estimators":{"children_left":[-1],"children_right":[],"feature":[-2],"threshold":[-2],"value":[[[0]]]}}`), 0))
+	goassert.New(t, "estimator: node 0 is visited twice").ExpectError(UnmarshalSklearnJSON([]byte(`{"model":"DecisionTreeRegressor","estimators":{"children_left":[0],"children_right":[0],"feature":[0],"threshold":[0],"value":[[[0]]]}}`), 0))
+	goassert.New(t, "estimator: node 0: illegal feature -2").ExpectError(UnmarshalSklearnJSON([]byte(`{"model":"DecisionTreeRegressor","estimators":{"children_left":[1,-1,-1],"children_right":[2,-1,-1],"feature":[-2,-2,-2],"threshold":[0,0,0],"value":[[[0]],[[0]],[[0]]]}}`), 0))
+	goassert.New(t, "init must not be empty").ExpectError(UnmarshalSklearnJSON([]byte(`{"model":"GradientBoostingRegressor","estimators":[]}`), 0))
+	goassert.New(t, "stage 0 must have 1 trees").ExpectError(UnmarshalSklearnJSON([]byte(`{"model":"GradientBoostingRegressor","init":[0],"estimators":[[]]}`), 0))
+	goassert.New(t, "unexpected end of JSON input").ExpectError(UnmarshalSklearnJSON([]byte(`[`), 0))
+}
@@ -0,0 +1,137 @@
+package confeito
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// XGBoostOptions is the options of importing XGBoost models by UnmarshalXGBoostJSON.
+// The JSON dump has neither the base score nor the number of classes, so they are given by the options.
+type XGBoostOptions struct {
+	// BaseScore is the margin added to the sum of the trees, which is imported as a tree of depth 0 if it is not zero.
+	BaseScore float32
+	// NumClasses is the number of classes of the multi-class model, whose trees of the classes are interleaved.
+	// If it is at most 1, then every tree is imported.
+	NumClasses int
+	// Class is the class whose score is imported, which is ignored if NumClasses is at most 1.
+	Class int
+	// StrictMissingValues rejects the splits sending missing (NaN) feature values to the right if true.
+	// Leaf takes the left leaf for missing feature values, so such splits are imported as they are by default, and the predictions for NaN differ from XGBoost.
+	StrictMissingValues bool
+}
+
+// xgboostNodeJSON is the JSON schema of a node of a XGBoost tree dumped with dump_format "json".
+// The terminal leaves have leaf.
+type xgboostNodeJSON struct {
+	NodeID         int                `json:"nodeid"`
+	Split          string             `json:"split"`
+	SplitCondition float64            `json:"split_condition"`
+	Yes            int                `json:"yes"`
+	No             int                `json:"no"`
+	Missing        int                `json:"missing"`
+	Leaf           *float64           `json:"leaf"`
+	Children       []*xgboostNodeJSON `json:"children"`
+}
+
+// leaf returns a new Leaf of the tree whose root is node.
+// The split "x < split_condition" takes the child yes, so it is the split with operator Less.
+//
+// This function returns an error if node is malformed, the feature is not "f<index>", or missing values go to the right with StrictMissingValues.
+func (node *xgboostNodeJSON) leaf(options *XGBoostOptions) (*Leaf, error) {
+	if node.Leaf != nil {
+		return NewTerminalLeaf(float32(*node.Leaf))
+	}
+	if !strings.HasPrefix(node.Split, "f") {
+		return nil, fmt.Errorf("node %d: feature must be f<index>: %q", node.NodeID, node.Split)
+	}
+	featureID, err := strconv.ParseUint(node.Split[1:], 10, 32)
+	if err != nil || FeatureID(featureID) == _FEATURE_ID_ILLEGAL {
+		return nil, fmt.Errorf("node %d: feature must be f<index>: %q", node.NodeID, node.Split)
+	}
+	// XGBoost has the float32 thresholds.
+	threshold := float32(node.SplitCondition)
+	if math.IsNaN(float64(threshold)) || math.IsInf(float64(threshold), 0) {
+		return nil, fmt.Errorf("node %d: threshold must be finite: %g", node.NodeID, node.SplitCondition)
+	}
+	if node.Missing != node.Yes && node.Missing != node.No {
+		return nil, fmt.Errorf("node %d: missing must be yes or no", node.NodeID)
+	}
+	if node.Missing != node.Yes && options.StrictMissingValues {
+		return nil, fmt.Errorf("node %d: missing values must go to the left leaf, but they go to no", node.NodeID)
+	}
+	var yes, no *xgboostNodeJSON
+	for _, child := range node.Children {
+		if child == nil {
+			continue
+		}
+		switch child.NodeID {
+		case node.Yes:
+			yes = child
+		case node.No:
+			no = child
+		}
+	}
+	if len(node.Children) != 2 || yes == nil || no == nil {
+		return nil, fmt.Errorf("node %d: children must be yes and no", node.NodeID)
+	}
+	left, err := yes.leaf(options)
+	if err != nil {
+		return nil, err
+	}
+	right, err := no.leaf(options)
+	if err != nil {
+		return nil, err
+	}
+	return &Leaf{featureID: FeatureID(featureID), threshold: threshold, operator: Less, left: left, right: right}, nil
+}
+
+// UnmarshalXGBoostJSON returns a new Ensemble represented by JSON data of a XGBoost model dumped with dump_format "json" (Booster.dump_model or Booster.get_dump), which is the array of the trees.
+//
+// Each tree has the nested nodes {"nodeid": id, "split": "f<index>", "split_condition": threshold, "yes": id, "no": id, "missing": id, "children": [...]}, and the terminal leaves {"nodeid": id, "leaf": value}.
+// The trees have weight 1, because the leaf values are already scaled by the learning rate.
+// The dump has the feature names if the model has them, but only the default names "f<index>" are supported.
+// The splits are exact, because XGBoost has the float32 thresholds, but the summation is in float32, so the predictions may differ by the rounding errors.
+//
+// Missing (NaN) values go to the left like Leaf, so the splits sending them to the child no are lossy (see XGBoostOptions).
+//
+// This function returns an error if data is malformed, a split is not supported, or options.Class is out of range.
+func UnmarshalXGBoostJSON(data []byte, options *XGBoostOptions) (*Ensemble, error) {
+	if options == nil {
+		options = &XGBoostOptions{}
+	}
+	var trees []*xgboostNodeJSON
+	if err := json.Unmarshal(data, &trees); err != nil {
+		return nil, err
+	}
+	nclasses, class := options.NumClasses, options.Class
+	if nclasses <= 1 {
+		nclasses, class = 1, 0
+	} else if !(0 <= class && class < nclasses) {
+		return nil, fmt.Errorf("class %d is out of range [0, %d)", class, nclasses)
+	}
+	if len(trees)%nclasses != 0 {
+		return nil, fmt.Errorf("the number of trees must be a multiple of the number of classes")
+	}
+	model := &Ensemble{}
+	if options.BaseScore != 0.0 {
+		baseLeaf, err := NewTerminalLeaf(options.BaseScore)
+		if err != nil {
+			return nil, err
+		}
+		model.Trees, model.Weights = append(model.Trees, baseLeaf), append(model.Weights, 1.0)
+	}
+	for t := class; t < len(trees); t += nclasses {
+		if trees[t] == nil {
+			return nil, fmt.Errorf("tree %d: tree must not be null", t)
+		}
+		leaf, err := trees[t].leaf(options)
+		if err != nil {
+			return nil, fmt.Errorf("tree %d: %s", t, err)
+		}
+		model.Trees, model.Weights = append(model.Trees, leaf), append(model.Weights, 1.0)
+	}
+	return model, nil
+}
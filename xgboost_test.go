@@ -0,0 +1,46 @@
+package confeito
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/hiro4bbh/go-assert"
+)
+
+// xgboostTestModel is a XGBoost model dumped with dump_format "json" of a tree with the splits "f0 < 0.5" (missing to yes) and "f1 < 0.1" (missing to no), and a tree of a single leaf.
+const xgboostTestModel = `[
+  { "nodeid": 0, "depth": 0, "split": "f0", "split_condition": 0.5, "yes": 1, "no": 2, "missing": 1, "children": [
+    { "nodeid": 1, "leaf": 1 },
+    { "nodeid": 2, "depth": 1, "split": "f1", "split_condition": 0.100000001, "yes": 3, "no": 4, "missing": 4, "children": [
+      { "nodeid": 3, "leaf": 2 },
+      { "nodeid": 4, "leaf": 3 }
+    ]}
+  ]},
+  { "nodeid": 0, "leaf": 0.5 }
+]`
+
+func TestUnmarshalXGBoostJSON(t *testing.T) {
+	model := goassert.New(t).SucceedNew(UnmarshalXGBoostJSON([]byte(xgboostTestModel), nil)).(*Ensemble)
+	goassert.New(t, []float32{1.0, 1.0}).Equal(model.Weights)
+	goassert.New(t, "(feature[0] < 0.5 ? 1 : (feature[1] < 0.1 ? 2 : 3))").Equal(model.Trees[0].String())
+	goassert.New(t, float32(1.5)).EqualWithoutError(model.PredictSum(DenseFeatureVector{0.0, 0.0}))
+	goassert.New(t, float32(3.5)).EqualWithoutError(model.PredictSum(DenseFeatureVector{1.0, float32(0.1)}))
+	goassert.New(t, float32(1.5)).EqualWithoutError(model.PredictSum(DenseFeatureVector{float32(math.NaN()), 0.0}))
+	model = goassert.New(t).SucceedNew(UnmarshalXGBoostJSON([]byte(xgboostTestModel), &XGBoostOptions{BaseScore: 0.25})).(*Ensemble)
+	goassert.New(t, "0.25").Equal(model.Trees[0].String())
+	goassert.New(t, float32(1.75)).EqualWithoutError(model.PredictSum(DenseFeatureVector{0.0, 0.0}))
+	// The trees of the classes are interleaved.
+	model = goassert.New(t).SucceedNew(UnmarshalXGBoostJSON([]byte(xgboostTestModel), &XGBoostOptions{NumClasses: 2, Class: 1})).(*Ensemble)
+	goassert.New(t, 1).Equal(len(model.Trees))
+	goassert.New(t, "0.5").Equal(model.Trees[0].String())
+
+	goassert.New(t, "class 2 is out of range [0, 2)").ExpectError(UnmarshalXGBoostJSON([]byte(xgboostTestModel), &XGBoostOptions{NumClasses: 2, Class: 2}))
+	goassert.New(t, "the number of trees must be a multiple of the number of classes").ExpectError(UnmarshalXGBoostJSON([]byte(xgboostTestModel), &XGBoostOptions{NumClasses: 3}))
+	goassert.New(t, "tree 0: node 2: missing values must go to the left leaf, but they go to no").ExpectError(UnmarshalXGBoostJSON([]byte(xgboostTestModel), &XGBoostOptions{StrictMissingValues: true}))
+	goassert.New(t, "tree 0: node 0: feature must be f<index>: \"age\"").ExpectError(UnmarshalXGBoostJSON([]byte(strings.Replace(xgboostTestModel, `"f0"`, `"age"`, 1)), nil))
+	goassert.New(t, "tree 0: node 2: missing must be yes or no").ExpectError(UnmarshalXGBoostJSON([]byte(strings.Replace(xgboostTestModel, `"missing": 4`, `"missing": 5`, 1)), nil))
+	goassert.New(t, "tree 0: node 0: children must be yes and no").ExpectError(UnmarshalXGBoostJSON([]byte(strings.Replace(xgboostTestModel, `"yes": 1, "no": 2, "missing": 1`, `"yes": 5, "no": 2, "missing": 5`, 1)), nil))
+	goassert.New(t, "tree 1: tree must not be null").ExpectError(UnmarshalXGBoostJSON([]byte(`[{"nodeid":0,"leaf":1},null]`), nil))
+	goassert.New(t).ExpectError(UnmarshalXGBoostJSON([]byte(`{}`), nil))
+}